@@ -4,13 +4,10 @@ import (
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/internal/sources"
 	"github.com/rumanzo/bt2qbt/internal/transfer"
-	"github.com/rumanzo/bt2qbt/pkg/helpers"
-	"github.com/rumanzo/bt2qbt/pkg/utorrentStructs"
-	"github.com/zeebo/bencode"
 	"log"
 	"os"
-	"path"
 	"runtime"
 	"time"
 )
@@ -26,43 +23,82 @@ func main() {
 		os.Exit(0)
 	}
 
-	resumeFilePath := path.Join(opts.BitDir, "resume.dat")
-	if _, err := os.Stat(resumeFilePath); os.IsNotExist(err) {
-		log.Println("Can't find uTorrent\\Bittorrent resume file")
-		time.Sleep(30 * time.Second)
-		os.Exit(1)
+	if opts.Undo {
+		restored, err := transfer.Undo(opts.GetQBitDataDir())
+		if err != nil {
+			log.Printf("Can't undo last run. Restored %v files. Err: %v\n", restored, err)
+			options.Exit(opts, options.ExitUndoFailure)
+		}
+		log.Printf("Restored %v files\n", restored)
+		os.Exit(options.ExitSuccess)
 	}
-	resumeFile := map[string]interface{}{}
-	err := helpers.DecodeTorrentFile(resumeFilePath, resumeFile)
-	if err != nil {
-		log.Println("Can't decode uTorrent\\Bittorrent resume file")
-		time.Sleep(30 * time.Second)
-		os.Exit(1)
+
+	// banners and prompts go to stderr, so stdout can be piped
+	color.Output = color.Error
+
+	if opts.Relocate {
+		color.Green("It will be performed relocation of fastresume files in directory %v\n", opts.QBitDir)
+		color.HiRed("Check that the qBittorrent is turned off and the directory %v is backed up.\n\n", opts.QBitDir)
+		if opts.DryRun {
+			color.Green("Dry run: nothing will be written\n\n")
+		}
+		waitStart(opts)
+		exit(opts, transfer.HandleRelocation(opts))
+	}
+
+	if opts.Export {
+		color.Green("It will be performed export from directory %v to directory %v\n", opts.QBitDir, opts.BitDir)
+		color.HiRed("Check that the uTorrent/Bittorrent is turned off and the directory %v is backed up.\n\n", opts.BitDir)
+		if opts.DryRun {
+			color.Green("Dry run: nothing will be written\n\n")
+		}
+		waitStart(opts)
+		exit(opts, transfer.HandleExport(opts))
 	}
-	// hate utorrent for heterogeneous resume.dat scheme
-	delete(resumeFile, ".fileguard")
-	delete(resumeFile, "rec")
-	b, _ := bencode.EncodeBytes(resumeFile)
-	resumeItems := map[string]*utorrentStructs.ResumeItem{}
-	err = bencode.DecodeBytes(b, &resumeItems)
+
+	torrents, err := sources.NewSource(opts).ReadTorrents()
 	if err != nil {
-		log.Printf("Can't convert resume.dat. Err: %v\n", err)
-		time.Sleep(30 * time.Second)
-		os.Exit(1)
+		log.Println(err)
+		options.Exit(opts, options.ExitResumeUnreadable)
 	}
 
-	color.Green("It will be performed processing from directory %v to directory %v\n", opts.BitDir, opts.QBitDir)
-	color.HiRed("Check that the qBittorrent is turned off and the directory %v and %v is backed up.\n",
-		opts.QBitDir, opts.Categories)
+	if opts.WebUI != "" {
+		color.Green("It will be performed processing from directory %v to qBittorrent WebUI %v\n", opts.BitDir, opts.WebUI)
+	} else {
+		color.Green("It will be performed processing from directory %v to directory %v\n", opts.BitDir, opts.QBitDir)
+		color.HiRed("Check that the qBittorrent is turned off and the directory %v and %v is backed up.\n",
+			opts.QBitDir, opts.Categories)
+	}
 	color.HiRed("Check that you previously disable option \"Append .!ut/.!bt to incomplete files\" in preferences of uTorrent/Bittorrent \n")
-	color.HiRed("Close uTorrent/Bittorrent and qBittorrent previously\n\n")
-	fmt.Println("Press Enter to start")
-	fmt.Scanln()
-	log.Println("Started")
+	if opts.WebUI != "" {
+		color.HiRed("Close uTorrent/Bittorrent previously\n\n")
+	} else {
+		color.HiRed("Close uTorrent/Bittorrent and qBittorrent previously\n\n")
+	}
+	if opts.DryRun {
+		color.Green("Dry run: nothing will be written, migration plan will be printed at the end\n\n")
+	}
+	waitStart(opts)
 
-	transfer.HandleResumeItems(opts, resumeItems)
+	wasErrors := transfer.HandleTorrents(opts, torrents)
+	exit(opts, wasErrors)
+}
 
-	fmt.Println("\nPress Enter to exit")
-	fmt.Scanln()
+func waitStart(opts *options.Opts) {
+	if opts.IsInteractive() {
+		fmt.Fprintln(os.Stderr, "Press Enter to start")
+		fmt.Scanln()
+	}
+	log.Println("Started")
+}
 
+func exit(opts *options.Opts, wasErrors bool) {
+	if opts.IsInteractive() {
+		fmt.Fprintln(os.Stderr, "\nPress Enter to exit")
+		fmt.Scanln()
+	}
+	if wasErrors {
+		os.Exit(options.ExitPartialFailure)
+	}
+	os.Exit(options.ExitSuccess)
 }
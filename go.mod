@@ -10,4 +10,5 @@ require (
 	github.com/r3labs/diff/v2 v2.15.0
 	github.com/stretchr/testify v1.7.0 // indirect
 	github.com/zeebo/bencode v1.0.0
+	modernc.org/sqlite v1.17.3
 )
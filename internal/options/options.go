@@ -5,6 +5,7 @@ import (
 	"github.com/jessevdk/go-flags"
 	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
 	"log"
+	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -14,15 +15,66 @@ import (
 )
 
 type Opts struct {
-	BitDir        string   `short:"s" long:"source" description:"Source directory that contains resume.dat and torrents files"`
-	QBitDir       string   `short:"d" long:"destination" description:"Destination directory BT_backup (as default)"`
-	Categories    string   `short:"c" long:"categories" description:"Path to qBittorrent categories.json file (for write tags)"`
-	WithoutLabels bool     `long:"without-labels" description:"Do not export/import labels"`
-	WithoutTags   bool     `long:"without-tags" description:"Do not export/import tags"`
-	SearchPaths   []string `short:"t" long:"search" description:"Additional search path for torrents files\n	Example: --search='/mnt/olddisk/savedtorrents' --search='/mnt/olddisk/workstorrents'"`
-	Replaces      []string `short:"r" long:"replace" description:"Replace save paths. Important: you have to use single slashes in paths\n	Delimiter for from/to is comma - ,\n	Example: -r \"D:/films,/home/user/films\" -r \"D:/music,/home/user/music\"\n"`
-	PathSeparator string   `long:"sep" description:"Default path separator that will use in all paths. You may need use this flag if you migrating from windows to linux in some cases"`
-	Version       bool     `short:"v" long:"version" description:"Show version"`
+	BitDir         string   `short:"s" long:"source" description:"Source directory that contains resume.dat and torrents files"`
+	SourceType     string   `long:"source-type" choice:"utorrent" choice:"deluge" choice:"transmission" choice:"rtorrent" choice:"biglybt" description:"Type of source client. Default is utorrent\n	deluge - source directory is Deluge config directory with state directory and label.conf\n	transmission - source directory is Transmission config directory with resume and torrents directories\n	rtorrent - source directory is rTorrent session directory\n	biglybt - source directory is BiglyBT/Vuze config directory with downloads.config and active directory"`
+	QBitDir        string   `short:"d" long:"destination" description:"Destination directory BT_backup (as default) or qBittorrent 4.5+ database torrents.db\n	Database is used if path has .db extension. Set Session\\ResumeDataStorageType=SQLite in qBittorrent config to use it"`
+	Categories     string   `short:"c" long:"categories" description:"Path to qBittorrent categories.json file (for write tags)"`
+	WithoutLabels  bool     `long:"without-labels" description:"Do not export/import labels"`
+	WithoutTags    bool     `long:"without-tags" description:"Do not export/import tags"`
+	SearchPaths    []string `short:"t" long:"search" description:"Additional search path for torrents files\n	Example: --search='/mnt/olddisk/savedtorrents' --search='/mnt/olddisk/workstorrents'"`
+	Replaces       []string `short:"r" long:"replace" description:"Replace save paths. Important: you have to use single slashes in paths\n	Delimiter for from/to is comma - ,\n	Example: -r \"D:/films,/home/user/films\" -r \"D:/music,/home/user/music\"\n"`
+	PathSeparator  string   `long:"sep" description:"Default path separator that will use in all paths. You may need use this flag if you migrating from windows to linux in some cases"`
+	Verify         bool     `long:"verify" description:"Compute downloaded pieces by hashing files on disk. It takes time, but torrents don't need force recheck"`
+	VerifyThreads  int      `long:"verify-threads" description:"Number of parallel piece reads while verifying. Default is number of CPUs"`
+	DryRun         bool     `long:"dry-run" description:"Process all torrents, but write nothing. Migration plan will be printed to stdout, progress to stderr"`
+	Plan           string   `long:"plan" description:"Save migration plan of dry run to json file\n	Example: --dry-run --plan=plan.json"`
+	Report         string   `long:"report" description:"Save results of all torrents to json or csv file\n	Example: --report=report.json"`
+	OnConflict     string   `long:"on-conflict" choice:"skip" choice:"overwrite" choice:"backup" choice:"merge" description:"What to do if fastresume or torrent file already exists in destination directory. Default is overwrite\n	skip - keep existing files\n	backup - save existing files with .bak suffix and overwrite\n	merge - keep keys of existing fastresume that bt2qbt doesn't write"`
+	Relocate       bool     `long:"relocate" description:"Rewrite save paths of existing fastresume files in destination directory with --replace and --sep. Torrent files aren't changed"`
+	Salvage        bool     `long:"salvage" description:"Recover torrents from damaged resume.dat that can't be decoded and has no valid backup. Damaged torrents are skipped and listed"`
+	Export         bool     `long:"export" description:"Export torrents from destination directory BT_backup to resume.dat and torrent files in source directory of uTorrent/BitTorrent"`
+	WebUI          string   `long:"webui" description:"Add torrents through qBittorrent WebUI API instead of writing files into destination directory. qBittorrent may be running\n	Example: --webui=http://localhost:8080 --webui-username=admin"`
+	WebUIUsername  string   `long:"webui-username" description:"Username of qBittorrent WebUI"`
+	WebUIPassword  string   `long:"webui-password" env:"BT2QBT_WEBUI_PASSWORD" description:"Password of qBittorrent WebUI. It can be set with environment variable BT2QBT_WEBUI_PASSWORD"`
+	Undo           bool     `long:"undo" description:"Restore files that were changed by the last run in destination directory and categories file"`
+	Yes            bool     `short:"y" long:"yes" description:"Don't ask for confirmation and don't wait before exit"`
+	NonInteractive bool     `long:"non-interactive" description:"The same as --yes"`
+	Version        bool     `short:"v" long:"version" description:"Show version"`
+}
+
+// Exit codes of application
+const (
+	ExitSuccess          = 0
+	ExitBadOptions       = 64
+	ExitResumeUnreadable = 3
+	ExitPartialFailure   = 4
+	ExitUndoFailure      = 5
+)
+
+// IsInteractive report if application may wait for user
+func (opts *Opts) IsInteractive() bool {
+	return !opts.Yes && !opts.NonInteractive
+}
+
+// IsTorrentsDB report if destination is SQLite resume storage of qBittorrent instead of BT_backup directory
+func (opts *Opts) IsTorrentsDB() bool {
+	return strings.EqualFold(filepath.Ext(opts.QBitDir), ".db")
+}
+
+// GetQBitDataDir return directory that contains destination, so journal can be kept near database
+func (opts *Opts) GetQBitDataDir() string {
+	if opts.IsTorrentsDB() {
+		return filepath.Dir(opts.QBitDir)
+	}
+	return opts.QBitDir
+}
+
+// Exit terminate application with code. In interactive mode it waits, so user can read message before console closes
+func Exit(opts *Opts, code int) {
+	if opts.IsInteractive() {
+		time.Sleep(30 * time.Second)
+	}
+	os.Exit(code)
 }
 
 func PrepareOpts() *Opts {
@@ -58,8 +110,13 @@ func ParseOpts(opts *Opts) *Opts {
 			os.Exit(0)
 		} else {
 			log.Println(err)
-			time.Sleep(30 * time.Second)
-			os.Exit(1)
+			// options after wrong one aren't parsed, so look for non-interactive flags in arguments directly
+			for _, arg := range os.Args[1:] {
+				if arg == "-y" || arg == "--yes" || arg == "--non-interactive" {
+					opts.Yes = true
+				}
+			}
+			Exit(opts, ExitBadOptions)
 		}
 	}
 	return opts
@@ -70,8 +127,12 @@ func HandleOpts(opts *Opts) {
 	opts.SearchPaths = append(opts.SearchPaths, opts.BitDir)
 
 	qbtDir := fileHelpers.Normalize(opts.QBitDir, `/`)
-	if strings.Contains(qbtDir, `profile/qBittorrent/data/BT_backup`) {
-		qbtRootDir, _ := strings.CutSuffix(qbtDir, `data/BT_backup`)
+	qbtDataDir := `data/BT_backup`
+	if opts.IsTorrentsDB() {
+		qbtDataDir = `data/` + fileHelpers.Base(qbtDir)
+	}
+	if strings.Contains(qbtDir, `profile/qBittorrent/`+qbtDataDir) {
+		qbtRootDir, _ := strings.CutSuffix(qbtDir, qbtDataDir)
 
 		// check that user not define categories
 		refOpts := PrepareOpts()
@@ -91,14 +152,52 @@ func OptsCheck(opts *Opts) error {
 		}
 	}
 
-	if _, err := os.Stat(opts.BitDir); os.IsNotExist(err) {
-		return fmt.Errorf("can't find uTorrent\\Bittorrent folder")
+	if opts.Plan != "" && !opts.DryRun {
+		return fmt.Errorf("plan can be saved only with dry run")
 	}
 
-	if _, err := os.Stat(opts.QBitDir); os.IsNotExist(err) {
+	if opts.Report != "" {
+		if ext := strings.ToLower(filepath.Ext(opts.Report)); ext != ".json" && ext != ".csv" {
+			return fmt.Errorf("report must be json or csv file")
+		}
+	}
+
+	if opts.Export && (opts.Relocate || (opts.SourceType != "" && opts.SourceType != "utorrent")) {
+		return fmt.Errorf("export is possible only to uTorrent\\Bittorrent folder")
+	}
+
+	if opts.Salvage && (opts.Relocate || opts.Export || (opts.SourceType != "" && opts.SourceType != "utorrent")) {
+		return fmt.Errorf("salvage is possible only for import from uTorrent\\Bittorrent")
+	}
+
+	if _, err := os.Stat(opts.BitDir); os.IsNotExist(err) && !opts.Relocate && !opts.Undo {
+		return fmt.Errorf("can't find source folder %v", opts.BitDir)
+	}
+
+	if opts.WebUI != "" {
+		if opts.Relocate || opts.Export || opts.Undo {
+			return fmt.Errorf("relocation, export and undo aren't possible with WebUI")
+		}
+		if webUI, err := url.Parse(opts.WebUI); err != nil || (webUI.Scheme != "http" && webUI.Scheme != "https") {
+			return fmt.Errorf("WebUI must be http or https url")
+		}
+		// destination directory isn't used
+		return checkSearchPaths(opts)
+	}
+
+	if opts.IsTorrentsDB() && (opts.Relocate || opts.Export) {
+		return fmt.Errorf("relocation and export are possible only with BT_backup folder")
+	}
+
+	// database is created if it doesn't exist
+	if _, err := os.Stat(opts.GetQBitDataDir()); os.IsNotExist(err) {
 		return fmt.Errorf("can't find qBittorrent folder")
 	}
 
+	return checkSearchPaths(opts)
+}
+
+func checkSearchPaths(opts *Opts) error {
 	if runtime.GOOS == "linux" {
 		if opts.SearchPaths == nil {
 			return fmt.Errorf("on linux systems you must define search path for torrents")
@@ -114,8 +213,7 @@ func MakeOpts() *Opts {
 	err := OptsCheck(opts)
 	if err != nil {
 		log.Println(err)
-		time.Sleep(time.Duration(30) * time.Second)
-		os.Exit(1)
+		Exit(opts, ExitBadOptions)
 	}
 	return opts
 }
@@ -72,6 +72,24 @@ func TestOptionsArgs(t *testing.T) {
 				WithoutTags:   true,
 			},
 		},
+		{
+			name: "Parse non-interactive args test",
+			args: []string{
+				"--source", "/dir",
+				"--destination", "/dir",
+				"--categories", "/dir/q.json",
+				"--sep", "/",
+				"-y", "--non-interactive"},
+			mustFail: false,
+			expected: &Opts{
+				BitDir:         "/dir",
+				QBitDir:        "/dir",
+				Categories:     "/dir/q.json",
+				PathSeparator:  "/",
+				Yes:            true,
+				NonInteractive: true,
+			},
+		},
 	}
 
 	for _, testCase := range cases {
@@ -228,6 +246,64 @@ func TestOptionsChecks(t *testing.T) {
 			},
 			mustFail: true,
 		},
+		{
+			name: "005 Check not existing database in exists folder",
+			opts: &Opts{
+				BitDir:      "../../test/data",
+				QBitDir:     "../../test/data/torrents.db",
+				SearchPaths: []string{},
+			},
+			mustFail: false,
+		},
+		{
+			name: "006 Must fail relocate with database",
+			opts: &Opts{
+				QBitDir:     "../../test/data/torrents.db",
+				Relocate:    true,
+				SearchPaths: []string{},
+			},
+			mustFail: true,
+		},
+		{
+			name: "007 Check WebUI without destination folder",
+			opts: &Opts{
+				BitDir:      "../../test/data",
+				QBitDir:     "/dir",
+				WebUI:       "http://localhost:8080",
+				SearchPaths: []string{},
+			},
+			mustFail: false,
+		},
+		{
+			name: "008 Must fail WebUI without scheme",
+			opts: &Opts{
+				BitDir:      "../../test/data",
+				WebUI:       "localhost:8080",
+				SearchPaths: []string{},
+			},
+			mustFail: true,
+		},
+		{
+			name: "009 Must fail salvage with export",
+			opts: &Opts{
+				BitDir:      "../../test/data",
+				QBitDir:     "../../test/data",
+				Salvage:     true,
+				Export:      true,
+				SearchPaths: []string{},
+			},
+			mustFail: true,
+		},
+		{
+			name: "010 Check undo without source folder",
+			opts: &Opts{
+				BitDir:      "/dir",
+				QBitDir:     "../../test/data",
+				Undo:        true,
+				SearchPaths: []string{},
+			},
+			mustFail: false,
+		},
 	}
 
 	for _, testCase := range cases {
@@ -239,3 +315,12 @@ func TestOptionsChecks(t *testing.T) {
 		})
 	}
 }
+
+func TestOptsIsInteractive(t *testing.T) {
+	if !(&Opts{}).IsInteractive() {
+		t.Fatalf("Opts without flags must be interactive")
+	}
+	if (&Opts{Yes: true}).IsInteractive() || (&Opts{NonInteractive: true}).IsInteractive() {
+		t.Fatalf("Opts with --yes or --non-interactive mustn't be interactive")
+	}
+}
@@ -0,0 +1,256 @@
+package sources
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+)
+
+const (
+	BiglyDownloadsFile = "downloads.config"
+	BiglyActiveDir     = "active"
+	BiglyTagsFile      = "tag.config"
+)
+
+// biglyStateStopped download state that is saved for stopped downloads, other downloads are saved as queued
+const biglyStateStopped = 70
+
+// biglyPieceDone piece state in resume data
+const biglyPieceDone = 1
+
+// biglyDownload download entry of downloads.config
+type biglyDownload struct {
+	Category           string  `bencode:"category"`
+	Completed          int64   `bencode:"completed"` // per mille
+	CreationTime       int64   `bencode:"creationTime"`
+	Downloaded         int64   `bencode:"downloaded"`
+	FilePriorities     []int64 `bencode:"file_priorities"` // 0 skipped, -1 normal, greater than 0 high
+	SaveDir            string  `bencode:"save_dir"`
+	SaveFile           string  `bencode:"save_file"`
+	SecondsDownloading int64   `bencode:"secondsDownloading"`
+	SecondsOnlySeeding int64   `bencode:"secondsOnlySeeding"`
+	State              int64   `bencode:"state"`
+	Torrent            string  `bencode:"torrent"`
+	TorrentHash        string  `bencode:"torrent_hash"`
+	Uploaded           int64   `bencode:"uploaded"`
+}
+
+// biglyDownloadState active/<hash>.dat file. It's torrent file with download state
+type biglyDownloadState struct {
+	Attributes map[string]interface{} `bencode:"attributes"`
+	Resume     map[string]interface{} `bencode:"resume"`
+}
+
+/*
+ReadBiglyBT read downloads.config and active/<hash>.dat files from BiglyBT/Vuze config directory
+and convert them to torrents. Keys are paths to torrent files.
+*/
+func ReadBiglyBT(dir string) (map[string]*Torrent, error) {
+	config := struct {
+		Downloads []biglyDownload `bencode:"downloads"`
+	}{}
+	if err := helpers.DecodeTorrentFile(filepath.Join(dir, BiglyDownloadsFile), &config); err != nil {
+		return nil, fmt.Errorf("can't decode BiglyBT/Vuze downloads: %v", err)
+	}
+	tags, err := readBiglyTags(filepath.Join(dir, BiglyTagsFile))
+	if err != nil {
+		return nil, err
+	}
+	torrents := map[string]*Torrent{}
+	for _, download := range config.Downloads {
+		key, torrent := convertBiglyDownload(dir, &download, tags)
+		torrents[key] = torrent
+	}
+	return torrents, nil
+}
+
+func convertBiglyDownload(dir string, download *biglyDownload, tags map[string][]string) (string, *Torrent) {
+	hashHex := strings.ToLower(hex.EncodeToString([]byte(download.TorrentHash)))
+	torrent := &Torrent{
+		ActiveTime:  download.SecondsDownloading + download.SecondsOnlySeeding,
+		AddedOn:     download.CreationTime / 1000, // milliseconds
+		Category:    download.Category,
+		Downloaded:  download.Downloaded,
+		InfoHash:    download.TorrentHash,
+		SeedingTime: download.SecondsOnlySeeding,
+		Started:     download.State != biglyStateStopped,
+		Tags:        tags[hashHex],
+		Uploaded:    download.Uploaded,
+	}
+
+	for _, priority := range download.FilePriorities {
+		switch {
+		case priority == 0:
+			torrent.FilePriorities = append(torrent.FilePriorities, PrioritySkip)
+		case priority > 0:
+			torrent.FilePriorities = append(torrent.FilePriorities, PriorityHigh)
+		default:
+			torrent.FilePriorities = append(torrent.FilePriorities, PriorityNormal)
+		}
+	}
+
+	// state file is torrent file too, so it's used if original torrent file is missing
+	statePath := ""
+	for _, name := range []string{strings.ToUpper(hashHex), hashHex} {
+		if _, err := os.Stat(filepath.Join(dir, BiglyActiveDir, name+".dat")); err == nil {
+			statePath = filepath.Join(dir, BiglyActiveDir, name+".dat")
+			break
+		}
+	}
+	key := download.Torrent
+	if _, err := os.Stat(download.Torrent); err != nil && statePath != "" {
+		key = filepath.ToSlash(filepath.Join(BiglyActiveDir, filepath.Base(statePath)))
+	}
+
+	state := &biglyDownloadState{}
+	if statePath != "" {
+		// broken state only lose resume data
+		_ = helpers.DecodeTorrentFile(statePath, state)
+	}
+	if category, ok := state.Attributes["category"].(string); ok && category != "" {
+		torrent.Category = category
+	}
+	if displayName, ok := state.Attributes["displayname"].(string); ok {
+		torrent.Name = displayName
+	}
+	if completedTime, ok := state.Attributes["complt"].(int64); ok {
+		torrent.CompletedOn = completedTime / 1000 // milliseconds
+	}
+	if torrent.CompletedOn == 0 && download.Completed == 1000 {
+		// completion time is unknown
+		torrent.CompletedOn = torrent.AddedOn
+	}
+
+	torrent.Path = download.SaveDir
+	var torrentName string
+	var pieceLength int64
+	if torrentFile, err := loadTorrentFile(statePath); err == nil {
+		torrentName = torrentFile.GetTorrentName()
+		pieceLength = torrentFile.Info.PieceLength
+	} else if torrentFile, err := loadTorrentFile(download.Torrent); err == nil {
+		torrentName = torrentFile.GetTorrentName()
+		pieceLength = torrentFile.Info.PieceLength
+	}
+	if download.SaveFile != "" {
+		torrent.Path = fileHelpers.Join([]string{download.SaveDir, download.SaveFile}, `/`)
+	} else if torrentName != "" {
+		torrent.Path = fileHelpers.Join([]string{download.SaveDir, torrentName}, `/`)
+	}
+
+	torrent.Pieces, torrent.Unfinished = getBiglyPieces(state.Resume, pieceLength)
+	return key, torrent
+}
+
+/*
+getBiglyPieces convert resume data (byte per piece with piece state and lists of downloaded blocks of started pieces)
+to bitfield of completed pieces and partially downloaded pieces
+*/
+func getBiglyPieces(resume map[string]interface{}, pieceLength int64) ([]byte, []UnfinishedPiece) {
+	if data, ok := resume["data"].(map[string]interface{}); ok {
+		resume = data
+	}
+	pieces, ok := resume["resume data"].(string)
+	if !ok || len(pieces) == 0 {
+		return nil, nil
+	}
+	bitfield := make([]byte, (len(pieces)+7)/8)
+	for i := 0; i < len(pieces); i++ {
+		if pieces[i] == biglyPieceDone {
+			bitfield[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	startedPieces, _ := resume["blocks"].(map[string]interface{})
+	if len(startedPieces) == 0 || pieceLength <= 0 {
+		return bitfield, nil
+	}
+	blocksPerPiece := (pieceLength + blockSize - 1) / blockSize
+	var indexes []int
+	for index := range startedPieces {
+		if piece, err := strconv.Atoi(index); err == nil && piece < len(pieces) && pieces[piece] != biglyPieceDone {
+			indexes = append(indexes, piece)
+		}
+	}
+	sort.Ints(indexes)
+	var unfinished []UnfinishedPiece
+	for _, piece := range indexes {
+		var downloaded []int64
+		blocks, _ := startedPieces[strconv.Itoa(piece)].([]interface{})
+		for _, block := range blocks {
+			if index, ok := block.(int64); ok && index >= 0 && index < blocksPerPiece {
+				downloaded = append(downloaded, index)
+			}
+		}
+		if len(downloaded) != 0 {
+			sort.Slice(downloaded, func(i, j int) bool { return downloaded[i] < downloaded[j] })
+			unfinished = append(unfinished, UnfinishedPiece{Piece: int64(piece), Blocks: downloaded})
+		}
+	}
+	return bitfield, unfinished
+}
+
+/*
+readBiglyTags return tag names by lowercase hex info hash. Tag config format differs between versions,
+so any list of info hashes in tag entry is treated as tagged downloads
+*/
+func readBiglyTags(path string) (map[string][]string, error) {
+	tags := map[string][]string{}
+	config := map[string]interface{}{}
+	if err := helpers.DecodeTorrentFile(path, &config); os.IsNotExist(err) {
+		return tags, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("can't decode BiglyBT/Vuze tags: %v", err)
+	}
+	for _, tagType := range config {
+		tagEntries, ok := tagType.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, tagEntry := range tagEntries {
+			entry, ok := tagEntry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := entry["n"].(string)
+			if name == "" {
+				name, _ = entry["name"].(string)
+			}
+			if name == "" {
+				continue
+			}
+			for _, value := range entry {
+				list, _ := value.([]interface{})
+				for _, item := range list {
+					if hash := getBiglyHash(item); hash != "" {
+						tags[hash] = append(tags[hash], name)
+					}
+				}
+			}
+		}
+	}
+	for hash := range tags {
+		sort.Strings(tags[hash])
+	}
+	return tags, nil
+}
+
+// getBiglyHash return lowercase hex info hash of raw or hex encoded hash
+func getBiglyHash(value interface{}) string {
+	hash, ok := value.(string)
+	if !ok {
+		return ""
+	}
+	if len(hash) == 20 {
+		return hex.EncodeToString([]byte(hash))
+	}
+	if _, err := hex.DecodeString(hash); err == nil && len(hash) == 40 {
+		return strings.ToLower(hash)
+	}
+	return ""
+}
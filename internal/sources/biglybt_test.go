@@ -0,0 +1,135 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zeebo/bencode"
+)
+
+func TestReadBiglyBT(t *testing.T) {
+	dir := t.TempDir()
+	for _, subDir := range []string{BiglyActiveDir, "torrents"} {
+		if err := os.Mkdir(filepath.Join(dir, subDir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	multiHash := "\x34\x56\xba\xc1\x07\x63\x49\x70\xb0\x22\x67\x7c\x6b\xfa\xa5\x84\x06\x5e\x09\x17"
+	singleHash := "\xd9\x5d\x90\xa7\x2e\x0a\x53\xe8\x8e\x6f\x73\xa3\x90\x5c\xa4\xfb\x59\x73\x47\x2e"
+	singleTorrentPath := filepath.Join(dir, "torrents", "testfile1.txt.torrent")
+
+	// state file is torrent file with download state
+	torrent := map[string]interface{}{}
+	data, err := os.ReadFile("../../test/data/testfileset.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = bencode.DecodeBytes(data, &torrent); err != nil {
+		t.Fatal(err)
+	}
+	torrent["attributes"] = map[string]interface{}{
+		"category":    "movies",
+		"complt":      1648068600000,
+		"displayname": "renamed set",
+	}
+	torrent["resume"] = map[string]interface{}{
+		"data": map[string]interface{}{
+			"resume data": "\x00",
+			"blocks":      map[string]interface{}{"0": []int{0}},
+		},
+	}
+	if data, err = bencode.EncodeBytes(torrent); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, BiglyActiveDir, "3456BAC107634970B022677C6BFAA584065E0917.dat"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile("../../test/data/testfile1_single_v1.torrent"); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(singleTorrentPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	downloads := map[string]interface{}{
+		"downloads": []map[string]interface{}{
+			{
+				"category":           "films",
+				"completed":          500,
+				"creationTime":       1648068574000,
+				"downloaded":         297,
+				"file_priorities":    []int{-1, 0, 1, -1, -1, -1, -1, -1, -1},
+				"save_dir":           "/downloads",
+				"save_file":          "testdir",
+				"secondsDownloading": 100,
+				"secondsOnlySeeding": 900,
+				"state":              70,
+				"torrent":            "/missing/testfileset.torrent",
+				"torrent_hash":       multiHash,
+				"uploaded":           594,
+			},
+			{
+				"completed":    1000,
+				"creationTime": 1648068574000,
+				"save_dir":     "/downloads",
+				"state":        75,
+				"torrent":      singleTorrentPath,
+				"torrent_hash": singleHash,
+			},
+		},
+	}
+	tags := map[string]interface{}{
+		"3": map[string]interface{}{
+			"1": map[string]interface{}{"n": "hd", "o": []string{"3456BAC107634970B022677C6BFAA584065E0917"}},
+			"2": map[string]interface{}{"n": "archive", "o": []string{multiHash, singleHash}},
+		},
+	}
+	for name, content := range map[string]interface{}{BiglyDownloadsFile: downloads, BiglyTagsFile: tags} {
+		if data, err = bencode.EncodeBytes(content); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	normal, skip, high := PriorityNormal, PrioritySkip, PriorityHigh
+	expected := map[string]*Torrent{
+		"active/3456BAC107634970B022677C6BFAA584065E0917.dat": {
+			ActiveTime:     1000,
+			AddedOn:        1648068574,
+			Category:       "movies",
+			CompletedOn:    1648068600,
+			Downloaded:     297,
+			FilePriorities: []Priority{normal, skip, high, normal, normal, normal, normal, normal, normal},
+			InfoHash:       multiHash,
+			Name:           "renamed set",
+			Path:           "/downloads/testdir",
+			Pieces:         []byte{0x00},
+			SeedingTime:    900,
+			Tags:           []string{"archive", "hd"},
+			Unfinished:     []UnfinishedPiece{{Piece: 0, Blocks: []int64{0}}},
+			Uploaded:       594,
+		},
+		singleTorrentPath: {
+			AddedOn:     1648068574,
+			CompletedOn: 1648068574,
+			InfoHash:    singleHash,
+			Path:        "/downloads/testfile1.txt",
+			Started:     true,
+			Tags:        []string{"archive"},
+		},
+	}
+	torrents, err := ReadBiglyBT(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(torrents, expected) {
+		for key, torrent := range torrents {
+			t.Logf("%v: %#v", key, torrent)
+		}
+		t.Fatalf("Unexpected torrents")
+	}
+}
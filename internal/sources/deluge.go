@@ -0,0 +1,304 @@
+package sources
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
+	"github.com/rumanzo/bt2qbt/pkg/pickle"
+	"github.com/zeebo/bencode"
+)
+
+const (
+	DelugeStateFile      = "torrents.state"
+	DelugeFastresumeFile = "torrents.fastresume"
+	DelugeLabelFile      = "label.conf"
+)
+
+// delugeFastresume libtorrent resume data that Deluge keeps in torrents.fastresume
+type delugeFastresume struct {
+	ActiveTime      int64    `bencode:"active_time"`
+	AddedTime       int64    `bencode:"added_time"`
+	CompletedTime   int64    `bencode:"completed_time"`
+	MappedFiles     []string `bencode:"mapped_files"`
+	Pieces          []byte   `bencode:"pieces"`
+	SeedingTime     int64    `bencode:"seeding_time"`
+	TotalDownloaded int64    `bencode:"total_downloaded"`
+	TotalUploaded   int64    `bencode:"total_uploaded"`
+}
+
+/*
+ReadDeluge read pickled torrents.state, torrents.fastresume and labels of Label plugin from Deluge config directory
+and convert them to torrents.
+Keys are paths to torrent files relative to dir or magnet links for torrents without metadata.
+*/
+func ReadDeluge(dir string) (map[string]*Torrent, error) {
+	// dir can be Deluge config directory or its state directory
+	stateDir := "state"
+	if _, err := os.Stat(filepath.Join(dir, stateDir, DelugeStateFile)); os.IsNotExist(err) {
+		stateDir = ""
+	}
+	stateData, err := os.ReadFile(filepath.Join(dir, stateDir, DelugeStateFile))
+	if err != nil {
+		return nil, fmt.Errorf("can't read Deluge torrents state: %v", err)
+	}
+	state, err := pickle.Decode(stateData)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode Deluge torrents state: %v", err)
+	}
+	stateObject, ok := state.(*pickle.Object)
+	if !ok {
+		return nil, fmt.Errorf("unexpected Deluge torrents state %T", state)
+	}
+	torrentStates, _ := getAttr(stateObject, "torrents").([]interface{})
+
+	fastresumes, err := readDelugeFastresume(filepath.Join(dir, stateDir, DelugeFastresumeFile))
+	if err != nil {
+		return nil, err
+	}
+	labels, err := readDelugeLabels(dir, stateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	torrents := map[string]*Torrent{}
+	for _, state := range torrentStates {
+		torrentState, ok := state.(*pickle.Object)
+		if !ok {
+			continue
+		}
+		key, torrent := convertDelugeTorrent(dir, stateDir, torrentState, fastresumes, labels)
+		torrents[key] = torrent
+	}
+	return torrents, nil
+}
+
+func convertDelugeTorrent(dir string, stateDir string, torrentState *pickle.Object,
+	fastresumes map[string]string, labels map[string]string) (string, *Torrent) {
+	torrentID := getString(torrentState, "torrent_id")
+	savePath := getString(torrentState, "save_path")
+	torrent := &Torrent{
+		Category: labels[torrentID],
+		Started:  !getBool(torrentState, "paused"),
+	}
+	if infoHash, err := hex.DecodeString(torrentID); err == nil {
+		torrent.InfoHash = string(infoHash)
+	}
+
+	// libtorrent priorities are 0-7
+	for _, prio := range getList(torrentState, "file_priorities") {
+		switch p := toInt(prio); {
+		case p <= 0:
+			torrent.FilePriorities = append(torrent.FilePriorities, PrioritySkip)
+		case p <= 4:
+			torrent.FilePriorities = append(torrent.FilePriorities, PriorityNormal)
+		default:
+			torrent.FilePriorities = append(torrent.FilePriorities, PriorityHigh)
+		}
+	}
+
+	torrent.Trackers = getDelugeTrackers(torrentState)
+
+	if speed := toFloat(getAttr(torrentState, "max_upload_speed")); speed > 0 {
+		torrent.UploadLimit = int64(speed * 1024) // KiB/s
+	}
+	if getBool(torrentState, "stop_at_ratio") {
+		torrent.SeedLimits = &SeedLimits{Ratio: toFloat(getAttr(torrentState, "stop_ratio"))}
+	}
+
+	fastresume := &delugeFastresume{}
+	if data, ok := fastresumes[torrentID]; ok {
+		// broken resume data only lose statistics
+		_ = bencode.DecodeString(data, fastresume)
+	}
+	torrent.AddedOn = fastresume.AddedTime
+	torrent.CompletedOn = fastresume.CompletedTime
+	torrent.ActiveTime = fastresume.ActiveTime
+	torrent.SeedingTime = fastresume.SeedingTime
+	torrent.Downloaded = fastresume.TotalDownloaded
+	torrent.Uploaded = fastresume.TotalUploaded
+	torrent.Pieces = getPieces(fastresume.Pieces)
+
+	key := path.Join(stateDir, torrentID+".torrent")
+	torrentFile, err := loadTorrentFile(filepath.Join(dir, stateDir, torrentID+".torrent"))
+	if err != nil {
+		torrent.Path = savePath
+		if magnet := getString(torrentState, "magnet"); magnet != "" {
+			// torrent without metadata
+			return magnet, torrent
+		}
+		// torrent file will be searched in search paths
+		return key, torrent
+	}
+
+	torrentName := torrentFile.GetTorrentName()
+	torrent.Path = fileHelpers.Join([]string{savePath, torrentName}, `/`)
+	if name := getString(torrentState, "name"); name != "" && name != torrentName {
+		torrent.Name = name
+	}
+	if torrentFile.IsSingle() {
+		if len(fastresume.MappedFiles) != 0 && fastresume.MappedFiles[0] != "" {
+			torrent.Path = fileHelpers.Join([]string{savePath, fastresume.MappedFiles[0]}, `/`)
+		}
+	} else {
+		// libtorrent mapped files are relative to save path
+		setRenamedFiles(torrent, torrentFile, torrentName, fastresume.MappedFiles)
+	}
+	return key, torrent
+}
+
+// getDelugeTrackers return tiers of tracker urls. Empty tiers are dropped
+func getDelugeTrackers(torrentState *pickle.Object) [][]string {
+	type tracker struct {
+		url  string
+		tier int64
+	}
+	var trackers []tracker
+	for _, t := range getList(torrentState, "trackers") {
+		if dict, ok := t.(map[interface{}]interface{}); ok {
+			url, _ := dict["url"].(string)
+			if url != "" {
+				trackers = append(trackers, tracker{url: url, tier: toInt(dict["tier"])})
+			}
+		}
+	}
+	sort.SliceStable(trackers, func(i, j int) bool {
+		return trackers[i].tier < trackers[j].tier
+	})
+	var tiers [][]string
+	for i, t := range trackers {
+		if i == 0 || t.tier != trackers[i-1].tier {
+			tiers = append(tiers, nil)
+		}
+		tiers[len(tiers)-1] = append(tiers[len(tiers)-1], t.url)
+	}
+	return tiers
+}
+
+// getPieces convert libtorrent pieces (byte per piece, the lowest bit is set for downloaded piece) to bitfield
+func getPieces(pieces []byte) []byte {
+	if len(pieces) == 0 {
+		return nil
+	}
+	bitfield := make([]byte, (len(pieces)+7)/8)
+	for i, piece := range pieces {
+		if piece&1 != 0 {
+			bitfield[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return bitfield
+}
+
+// readDelugeFastresume return libtorrent resume data by torrent id
+func readDelugeFastresume(path string) (map[string]string, error) {
+	fastresumes := map[string]string{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fastresumes, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("can't read Deluge fastresume: %v", err)
+	}
+	if err = bencode.DecodeBytes(data, &fastresumes); err != nil {
+		return nil, fmt.Errorf("can't decode Deluge fastresume: %v", err)
+	}
+	return fastresumes, nil
+}
+
+/*
+readDelugeLabels return labels of torrents from Label plugin config. Deluge config file contains two json objects:
+version of format and config itself
+*/
+func readDelugeLabels(dir string, stateDir string) (map[string]string, error) {
+	labelsPath := filepath.Join(dir, DelugeLabelFile)
+	if stateDir == "" {
+		labelsPath = filepath.Join(dir, "..", DelugeLabelFile)
+	}
+	file, err := os.Open(labelsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("can't read Deluge labels: %v", err)
+	}
+	defer file.Close()
+
+	var config struct {
+		TorrentLabels map[string]string `json:"torrent_labels"`
+	}
+	decoder := json.NewDecoder(file)
+	for {
+		var object json.RawMessage
+		if err = decoder.Decode(&object); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("can't decode Deluge labels: %v", err)
+		}
+		if err = json.Unmarshal(object, &config); err != nil {
+			return nil, fmt.Errorf("can't decode Deluge labels: %v", err)
+		}
+	}
+	if config.TorrentLabels == nil {
+		return map[string]string{}, nil
+	}
+	return config.TorrentLabels, nil
+}
+
+func getAttr(object *pickle.Object, name string) interface{} {
+	value, _ := object.Get(name)
+	return value
+}
+
+func getString(object *pickle.Object, name string) string {
+	switch value := getAttr(object, name).(type) {
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	}
+	return ""
+}
+
+func getBool(object *pickle.Object, name string) bool {
+	switch value := getAttr(object, name).(type) {
+	case bool:
+		return value
+	case int64:
+		return value != 0
+	}
+	return false
+}
+
+func getList(object *pickle.Object, name string) []interface{} {
+	value, _ := getAttr(object, name).([]interface{})
+	return value
+}
+
+func toInt(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case bool:
+		if v {
+			return 1
+		}
+	}
+	return 0
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,143 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rumanzo/bt2qbt/pkg/pickle"
+	"github.com/zeebo/bencode"
+)
+
+// python 3 pickle.dumps with protocol 2 of Deluge state with torrent and magnet link without metadata
+const delugeState = "\x80\x02cdeluge.core.torrentmanager\x0aTorrentManagerState\x0aq\x00)\x81q\x01}q\x02X\x08\x00\x00\x00torrentsq\x03]q\x04(cdeluge.core.torrentmanager\x0aTorrentState\x0aq\x05)\x81q\x06}q\x07(X\x0a\x00\x00\x00torrent_idq\x08X(\x00\x00\x003456bac107634970b022677c6bfaa584065e0917q\x09X\x04\x00\x00\x00nameq\x0aX\x0b\x00\x00\x00renamed setq\x0bX\x09\x00\x00\x00save_pathq\x0cX\x0a\x00\x00\x00/mnt/filmsq\x0dX\x06\x00\x00\x00pausedq\x0e\x88X\x0f\x00\x00\x00file_prioritiesq\x0f]q\x10(K\x01K\x00K\x07K\x04K\x01K\x01K\x01K\x01K\x01eX\x08\x00\x00\x00trackersq\x11]q\x12(}q\x13(X\x03\x00\x00\x00urlq\x14X\x15\x00\x00\x00http://tier1/announceq\x15X\x04\x00\x00\x00tierq\x16K\x01u}q\x17(h\x14X\x15\x00\x00\x00http://tier0/announceq\x18h\x16K\x00ueX\x10\x00\x00\x00max_upload_speedq\x19G@Y\x00\x00\x00\x00\x00\x00X\x0d\x00\x00\x00stop_at_ratioq\x1a\x88X\x0a\x00\x00\x00stop_ratioq\x1bG?\xf8\x00\x00\x00\x00\x00\x00X\x06\x00\x00\x00magnetq\x1cNubh\x05)\x81q\x1d}q\x1e(h\x08X(\x00\x00\x000000000000000000000000000000000000000001q\x1fh\x0aX\x00\x00\x00\x00q h\x0cX\x0a\x00\x00\x00/mnt/musicq!h\x0e\x89h\x0f]q\x22h\x11]q#h\x19G\xbf\xf0\x00\x00\x00\x00\x00\x00h\x1a\x89h\x1bG@\x00\x00\x00\x00\x00\x00\x00h\x1cX<\x00\x00\x00magnet:?xt=urn:btih:0000000000000000000000000000000000000001q$ubesb."
+
+func TestReadDeluge(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state")
+	if err := os.Mkdir(stateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, DelugeStateFile), []byte(delugeState), 0644); err != nil {
+		t.Fatal(err)
+	}
+	torrent, err := os.ReadFile("../../test/data/testfileset.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(stateDir, "3456bac107634970b022677c6bfaa584065e0917.torrent"), torrent, 0644); err != nil {
+		t.Fatal(err)
+	}
+	resumeData, err := bencode.EncodeString(map[string]interface{}{
+		"active_time":      1000,
+		"added_time":       1648068574,
+		"completed_time":   1648068600,
+		"total_downloaded": 297,
+		"total_uploaded":   594,
+		"pieces":           "\x01",
+		"mapped_files": []string{
+			"testdir/testfile1.txt",
+			"testdir/renamed.txt",
+			"testdir/testfile3.txt",
+			"testdir/dir1/testfile1.txt",
+			"testdir/dir2/testfile1.txt",
+			"testdir/dir2/testfile2.txt",
+			"testdir/dir3/testfile1.txt",
+			"testdir/dir3/testfile2.txt",
+			"testdir/dir3/testfile3.txt",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fastresume, err := bencode.EncodeBytes(map[string]string{"3456bac107634970b022677c6bfaa584065e0917": resumeData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(stateDir, DelugeFastresumeFile), fastresume, 0644); err != nil {
+		t.Fatal(err)
+	}
+	labels := "{\"file\": 1, \"format\": 1}{\"labels\": {\"films\": {}}, \"torrent_labels\": {\"3456bac107634970b022677c6bfaa584065e0917\": \"films\"}}"
+	if err = os.WriteFile(filepath.Join(dir, DelugeLabelFile), []byte(labels), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	normal, skip, high := PriorityNormal, PrioritySkip, PriorityHigh
+	expected := map[string]*Torrent{
+		"state/3456bac107634970b022677c6bfaa584065e0917.torrent": {
+			ActiveTime:     1000,
+			AddedOn:        1648068574,
+			Category:       "films",
+			CompletedOn:    1648068600,
+			Downloaded:     297,
+			FilePriorities: []Priority{normal, skip, high, normal, normal, normal, normal, normal, normal},
+			InfoHash:       "\x34\x56\xba\xc1\x07\x63\x49\x70\xb0\x22\x67\x7c\x6b\xfa\xa5\x84\x06\x5e\x09\x17",
+			Name:           "renamed set",
+			Path:           "/mnt/films/testdir",
+			Pieces:         []byte{0x80},
+			RenamedFiles:   []RenamedFile{{Index: 1, Path: "renamed.txt"}},
+			SeedLimits:     &SeedLimits{Ratio: 1.5},
+			Trackers:       [][]string{{"http://tier0/announce"}, {"http://tier1/announce"}},
+			UploadLimit:    102400,
+			Uploaded:       594,
+		},
+		"magnet:?xt=urn:btih:0000000000000000000000000000000000000001": {
+			InfoHash: "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01",
+			Path:     "/mnt/music",
+			Started:  true,
+		},
+	}
+	torrents, err := ReadDeluge(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(torrents, expected) {
+		for key, torrent := range torrents {
+			t.Logf("%v: %#v", key, torrent)
+		}
+		t.Fatalf("Unexpected torrents")
+	}
+
+	// keys are relative to passed directory, so torrent files are found in state directory itself
+	torrents, err = ReadDeluge(stateDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if torrent, ok := torrents["3456bac107634970b022677c6bfaa584065e0917.torrent"]; !ok || torrent.Category != "films" {
+		t.Fatalf("Unexpected torrents from state directory: %v", torrents)
+	}
+}
+
+func TestGetDelugeTrackers(t *testing.T) {
+	tracker := func(url string, tier int64) map[interface{}]interface{} {
+		return map[interface{}]interface{}{"url": url, "tier": tier}
+	}
+	torrentState := &pickle.Object{State: map[interface{}]interface{}{
+		"trackers": []interface{}{
+			tracker("http://tier2/announce", 2),
+			tracker("http://tier0/announce", 0),
+			tracker("", 1),
+			tracker("http://tier0b/announce", 0),
+			tracker("udp://tier2b/announce", 2),
+		},
+	}}
+	expected := [][]string{
+		{"http://tier0/announce", "http://tier0b/announce"},
+		{"http://tier2/announce", "udp://tier2b/announce"},
+	}
+	if trackers := getDelugeTrackers(torrentState); !reflect.DeepEqual(trackers, expected) {
+		t.Fatalf("Unexpected trackers:\n Got: %v\n Expect %v\n", trackers, expected)
+	}
+}
+
+func TestGetPieces(t *testing.T) {
+	pieces := []byte{1, 0, 1, 1, 0, 0, 0, 0, 1}
+	expected := []byte{0xb0, 0x80}
+	if bitfield := getPieces(pieces); !reflect.DeepEqual(bitfield, expected) {
+		t.Fatalf("Unexpected bitfield %x, expected %x", bitfield, expected)
+	}
+	if bitfield := getPieces(nil); bitfield != nil {
+		t.Fatalf("Unexpected bitfield %x for empty pieces", bitfield)
+	}
+}
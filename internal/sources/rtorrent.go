@@ -0,0 +1,188 @@
+package sources
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+)
+
+// rTorrentState bencoded <hash>.torrent.rtorrent file of rTorrent session directory
+type rTorrentState struct {
+	Custom            map[string]string `bencode:"custom"`    // ruTorrent keeps addtime and seedingtime here
+	Custom1           string            `bencode:"custom1"`   // ruTorrent label, url encoded
+	Directory         string            `bencode:"directory"` // directory of files for multi file torrents, parent directory for single file torrents
+	State             int64             `bencode:"state"`     // 1 started, 0 stopped
+	TiedToFile        string            `bencode:"tied_to_file"`
+	TimestampFinished int64             `bencode:"timestamp.finished"`
+	TimestampStarted  int64             `bencode:"timestamp.started"`
+	TotalDownloaded   int64             `bencode:"total_downloaded"`
+	TotalUploaded     int64             `bencode:"total_uploaded"`
+}
+
+// rTorrentResume bencoded <hash>.torrent.libtorrent_resume file of rTorrent session directory
+type rTorrentResume struct {
+	Bitfield interface{}                      `bencode:"bitfield"` // bitfield string or number of completed chunks if all or none are completed
+	Files    []rTorrentResumeFile             `bencode:"files"`
+	Trackers map[string]rTorrentResumeTracker `bencode:"trackers"`
+}
+
+type rTorrentResumeFile struct {
+	Priority int64 `bencode:"priority"` // 0 off, 1 normal, 2 high
+}
+
+type rTorrentResumeTracker struct {
+	Enabled int64 `bencode:"enabled"`
+}
+
+// rTorrentAnnounce trackers of torrent file in session directory
+type rTorrentAnnounce struct {
+	Announce     string     `bencode:"announce"`
+	AnnounceList [][]string `bencode:"announce-list"`
+}
+
+/*
+ReadRTorrent read rTorrent session directory with <hash>.torrent, <hash>.torrent.rtorrent
+and <hash>.torrent.libtorrent_resume files and convert them to torrents.
+Keys are names of torrent files in session directory, files that torrents are tied to or magnet links.
+*/
+func ReadRTorrent(dir string) (map[string]*Torrent, error) {
+	stateFiles, err := filepath.Glob(filepath.Join(dir, "*.torrent.rtorrent"))
+	if err != nil {
+		return nil, err
+	}
+	if len(stateFiles) == 0 {
+		return nil, fmt.Errorf("can't find rTorrent session files in %v", dir)
+	}
+	sort.Strings(stateFiles)
+	torrents := map[string]*Torrent{}
+	for _, stateFile := range stateFiles {
+		state := &rTorrentState{}
+		if err = helpers.DecodeTorrentFile(stateFile, state); err != nil {
+			return nil, fmt.Errorf("can't decode rTorrent session file %v: %v", stateFile, err)
+		}
+		torrentName := strings.TrimSuffix(filepath.Base(stateFile), ".rtorrent")
+		resume := &rTorrentResume{}
+		if err = helpers.DecodeTorrentFile(filepath.Join(dir, torrentName+".libtorrent_resume"), resume); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("can't decode rTorrent resume file of %v: %v", torrentName, err)
+		}
+		key, torrent := convertRTorrentState(dir, torrentName, state, resume)
+		torrents[key] = torrent
+	}
+	return torrents, nil
+}
+
+func convertRTorrentState(dir string, torrentName string, state *rTorrentState, resume *rTorrentResume) (string, *Torrent) {
+	torrent := &Torrent{
+		AddedOn:     state.TimestampStarted,
+		CompletedOn: state.TimestampFinished,
+		Downloaded:  state.TotalDownloaded,
+		Path:        state.Directory,
+		Started:     state.State != 0,
+		Uploaded:    state.TotalUploaded,
+	}
+	if addTime, err := strconv.ParseInt(strings.TrimSpace(state.Custom["addtime"]), 10, 64); err == nil {
+		torrent.AddedOn = addTime
+	}
+	if label, err := url.PathUnescape(state.Custom1); err == nil {
+		torrent.Category = label
+	} else {
+		torrent.Category = state.Custom1
+	}
+	if infoHash, err := hex.DecodeString(strings.TrimSuffix(torrentName, ".torrent")); err == nil {
+		torrent.InfoHash = string(infoHash)
+	}
+
+	for _, file := range resume.Files {
+		switch file.Priority {
+		case 0:
+			torrent.FilePriorities = append(torrent.FilePriorities, PrioritySkip)
+		case 1:
+			torrent.FilePriorities = append(torrent.FilePriorities, PriorityNormal)
+		default:
+			torrent.FilePriorities = append(torrent.FilePriorities, PriorityHigh)
+		}
+	}
+
+	torrent.Trackers = getRTorrentTrackers(filepath.Join(dir, torrentName), resume)
+
+	key := torrentName
+	torrentFile, err := loadTorrentFile(filepath.Join(dir, torrentName))
+	if err != nil || len(torrentFile.Info.Pieces) == 0 {
+		// rTorrent keeps magnet link in torrent file until metadata is downloaded
+		magnet := struct {
+			MagnetURI string `bencode:"magnet-uri"`
+		}{}
+		if err := helpers.DecodeTorrentFile(filepath.Join(dir, torrentName), &magnet); err == nil && magnet.MagnetURI != "" {
+			return magnet.MagnetURI, torrent
+		}
+		if err != nil && state.TiedToFile != "" {
+			// torrent file that torrent was loaded from
+			key = state.TiedToFile
+		}
+		return key, torrent
+	}
+	if torrentFile.IsSingle() {
+		torrent.Path = fileHelpers.Join([]string{state.Directory, torrentFile.GetTorrentName()}, `/`)
+	}
+
+	numPieces := int64(len(torrentFile.Info.Pieces)) / 20
+	switch bitfield := resume.Bitfield.(type) {
+	case string:
+		torrent.Pieces = []byte(bitfield)
+	case int64:
+		if bitfield == numPieces {
+			torrent.Pieces = getFullPieces(numPieces)
+		}
+	}
+	return key, torrent
+}
+
+/*
+getRTorrentTrackers return tiers of enabled trackers in order of rTorrent session: tiers of torrent file, then tier of
+trackers that were added in client. Resume file keeps trackers in dictionary without order, so added trackers are
+sorted by url
+*/
+func getRTorrentTrackers(torrentPath string, resume *rTorrentResume) [][]string {
+	announce := &rTorrentAnnounce{}
+	// torrent file of magnet link doesn't have trackers
+	_ = helpers.DecodeTorrentFile(torrentPath, announce)
+	tiers := announce.AnnounceList
+	if len(tiers) == 0 && announce.Announce != "" {
+		tiers = [][]string{{announce.Announce}}
+	}
+
+	var trackers [][]string
+	seen := map[string]bool{}
+	for _, tier := range tiers {
+		var enabled []string
+		for _, tracker := range tier {
+			if settings, ok := resume.Trackers[tracker]; tracker == "" || seen[tracker] || ok && settings.Enabled == 0 {
+				continue
+			}
+			seen[tracker] = true
+			enabled = append(enabled, tracker)
+		}
+		if len(enabled) != 0 {
+			trackers = append(trackers, enabled)
+		}
+	}
+	var added []string
+	for tracker, settings := range resume.Trackers {
+		if !seen[tracker] && settings.Enabled != 0 && !strings.HasPrefix(tracker, "dht://") {
+			added = append(added, tracker)
+		}
+	}
+	if len(added) != 0 {
+		sort.Strings(added)
+		trackers = append(trackers, added)
+	}
+	return trackers
+}
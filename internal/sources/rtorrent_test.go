@@ -0,0 +1,162 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zeebo/bencode"
+)
+
+func TestReadRTorrent(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, content interface{}) {
+		var data []byte
+		var err error
+		switch value := content.(type) {
+		case []byte:
+			data = value
+		default:
+			if data, err = bencode.EncodeBytes(value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copyFile := func(src string, name string) {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(name, data)
+	}
+
+	// multi file torrent
+	copyFile("../../test/data/testfileset.torrent", "3456BAC107634970B022677C6BFAA584065E0917.torrent")
+	writeFile("3456BAC107634970B022677C6BFAA584065E0917.torrent.rtorrent", map[string]interface{}{
+		"custom":             map[string]string{"addtime": "1648068574\n", "seedingtime": "1648068600"},
+		"custom1":            "Films%20HD",
+		"directory":          "/downloads/testdir",
+		"state":              0,
+		"tied_to_file":       "/watch/testfileset.torrent",
+		"timestamp.finished": 1648068600,
+		"timestamp.started":  1648068500,
+		"total_uploaded":     594,
+	})
+	writeFile("3456BAC107634970B022677C6BFAA584065E0917.torrent.libtorrent_resume", map[string]interface{}{
+		"bitfield": 1,
+		"files": []map[string]interface{}{
+			{"priority": 1}, {"priority": 0}, {"priority": 2}, {"priority": 1}, {"priority": 1},
+			{"priority": 1}, {"priority": 1}, {"priority": 1}, {"priority": 1},
+		},
+		"trackers": map[string]interface{}{
+			"dht://":                   map[string]interface{}{"enabled": 1},
+			"http://tier1/announce":    map[string]interface{}{"enabled": 1},
+			"http://disabled/announce": map[string]interface{}{"enabled": 0},
+			"http://tier0/announce":    map[string]interface{}{"enabled": 1},
+		},
+	})
+
+	// single file torrent with bitfield
+	copyFile("../../test/data/testfile1_single_v1.torrent", "D95D90A72E0A53E88E6F73A3905CA4FB5973472E.torrent")
+	writeFile("D95D90A72E0A53E88E6F73A3905CA4FB5973472E.torrent.rtorrent", map[string]interface{}{
+		"directory": "/downloads",
+		"state":     1,
+	})
+	writeFile("D95D90A72E0A53E88E6F73A3905CA4FB5973472E.torrent.libtorrent_resume", map[string]interface{}{
+		"bitfield": "\x80",
+	})
+
+	// magnet link without metadata
+	writeFile("0000000000000000000000000000000000000001.torrent", map[string]interface{}{
+		"magnet-uri": "magnet:?xt=urn:btih:0000000000000000000000000000000000000001",
+	})
+	writeFile("0000000000000000000000000000000000000001.torrent.rtorrent", map[string]interface{}{
+		"directory": "/downloads",
+		"state":     1,
+	})
+
+	// torrent file is missing in session directory
+	writeFile("0000000000000000000000000000000000000002.torrent.rtorrent", map[string]interface{}{
+		"directory":    "/downloads",
+		"state":        1,
+		"tied_to_file": "/watch/missing.torrent",
+	})
+
+	normal, skip, high := PriorityNormal, PrioritySkip, PriorityHigh
+	expected := map[string]*Torrent{
+		"3456BAC107634970B022677C6BFAA584065E0917.torrent": {
+			AddedOn:        1648068574,
+			Category:       "Films HD",
+			CompletedOn:    1648068600,
+			FilePriorities: []Priority{normal, skip, high, normal, normal, normal, normal, normal, normal},
+			InfoHash:       "\x34\x56\xba\xc1\x07\x63\x49\x70\xb0\x22\x67\x7c\x6b\xfa\xa5\x84\x06\x5e\x09\x17",
+			Path:           "/downloads/testdir",
+			Pieces:         []byte{0x80},
+			Trackers:       [][]string{{"http://tier0/announce", "http://tier1/announce"}},
+			Uploaded:       594,
+		},
+		"D95D90A72E0A53E88E6F73A3905CA4FB5973472E.torrent": {
+			InfoHash: "\xd9\x5d\x90\xa7\x2e\x0a\x53\xe8\x8e\x6f\x73\xa3\x90\x5c\xa4\xfb\x59\x73\x47\x2e",
+			Path:     "/downloads/testfile1.txt",
+			Pieces:   []byte{0x80},
+			Started:  true,
+		},
+		"magnet:?xt=urn:btih:0000000000000000000000000000000000000001": {
+			InfoHash: "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01",
+			Path:     "/downloads",
+			Started:  true,
+		},
+		"/watch/missing.torrent": {
+			InfoHash: "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02",
+			Path:     "/downloads",
+			Started:  true,
+		},
+	}
+	torrents, err := ReadRTorrent(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(torrents, expected) {
+		for key, torrent := range torrents {
+			t.Logf("%v: %#v", key, torrent)
+		}
+		t.Fatalf("Unexpected torrents")
+	}
+}
+
+func TestGetRTorrentTrackers(t *testing.T) {
+	torrentPath := filepath.Join(t.TempDir(), "3456BAC107634970B022677C6BFAA584065E0917.torrent")
+	data, err := bencode.EncodeBytes(map[string]interface{}{
+		"announce": "http://tier1/announce",
+		"announce-list": [][]string{
+			{"http://tier1/announce", "http://disabled/announce"},
+			{"udp://tier0/announce"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(torrentPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	resume := &rTorrentResume{Trackers: map[string]rTorrentResumeTracker{
+		"dht://":                   {Enabled: 1},
+		"http://added2/announce":   {Enabled: 1},
+		"http://added1/announce":   {Enabled: 1},
+		"http://disabled/announce": {Enabled: 0},
+		"http://tier1/announce":    {Enabled: 1},
+		"udp://tier0/announce":     {Enabled: 1},
+	}}
+	expected := [][]string{
+		{"http://tier1/announce"},
+		{"udp://tier0/announce"},
+		{"http://added1/announce", "http://added2/announce"},
+	}
+	if trackers := getRTorrentTrackers(torrentPath, resume); !reflect.DeepEqual(trackers, expected) {
+		t.Fatalf("Unexpected trackers:\n Got: %v\n Expect %v\n", trackers, expected)
+	}
+}
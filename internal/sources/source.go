@@ -0,0 +1,105 @@
+package sources
+
+import (
+	"github.com/rumanzo/bt2qbt/internal/options"
+)
+
+// Source read torrents of some client and convert them to records that don't depend on format of client, so all
+// clients are processed in the same way. Keys are paths to torrent files or magnet links for torrents without metadata
+type Source interface {
+	ReadTorrents() (map[string]*Torrent, error)
+}
+
+// Priority of file download
+type Priority int
+
+const (
+	PrioritySkip Priority = iota // file isn't downloaded
+	PriorityNormal
+	PriorityHigh
+)
+
+// Torrent state of torrent in source client
+type Torrent struct {
+	InfoHash         string            // raw v1 info hash, empty if it's unknown
+	Name             string            // name of torrent if it was renamed in client
+	Path             string            // path of torrent directory for multi file torrents or path of file for single file torrents
+	RenamedFiles     []RenamedFile     // files that were renamed or moved in client, ordered by index
+	FilePriorities   []Priority        // by index of file in torrent file
+	Pieces           []byte            // bitfield of completed pieces, the high bit in the first byte corresponds to piece 0. nil if it's unknown
+	Unfinished       []UnfinishedPiece // partially downloaded pieces, ordered by index
+	Started          bool
+	QueuePosition    *int64 // position in download queue from 0, nil if torrent isn't queued
+	AddedOn          int64  // unix time
+	CompletedOn      int64  // unix time, 0 if torrent isn't completed
+	LastActive       int64  // unix time of last upload or download
+	LastSeenComplete int64  // unix time
+	ActiveTime       int64  // seconds of activity
+	SeedingTime      int64  // seconds of seeding
+	Downloaded       int64
+	Uploaded         int64
+	Category         string
+	Tags             []string
+	Trackers         [][]string // tiers of tracker urls in order of client
+	UploadLimit      int64      // bytes per second, 0 is unlimited
+	DownloadLimit    int64      // bytes per second, 0 is unlimited
+	UploadSlots      int64      // 0 is default of client
+	SuperSeeding     bool
+	DisableDHT       bool
+	DisablePEX       bool
+	SeedLimits       *SeedLimits // nil if global seeding limits of client are used
+	Warnings         []string    // settings of client that can't be transferred
+}
+
+// RenamedFile file that was renamed or moved in client
+type RenamedFile struct {
+	Index int64  // index of file in torrent file
+	Path  string // absolute path or path relative to torrent directory
+}
+
+// UnfinishedPiece piece that is downloaded partially
+type UnfinishedPiece struct {
+	Piece  int64
+	Blocks []int64 // indexes of downloaded 16KiB blocks of piece
+}
+
+// SeedLimits limits of seeding that torrent overrides
+type SeedLimits struct {
+	Ratio       float64 // 0 is unlimited
+	SeedingTime int64   // seconds, 0 is unlimited
+}
+
+// dirSource read torrents of client from its directory
+type dirSource struct {
+	dir  string
+	read func(dir string) (map[string]*Torrent, error)
+}
+
+func (source *dirSource) ReadTorrents() (map[string]*Torrent, error) {
+	return source.read(source.dir)
+}
+
+// NewSource return source of client type (value of --source-type) that reads source directory. uTorrent is default
+func NewSource(opts *options.Opts) Source {
+	dir := opts.BitDir
+	switch opts.SourceType {
+	case "deluge":
+		return &dirSource{dir: dir, read: ReadDeluge}
+	case "transmission":
+		return &dirSource{dir: dir, read: ReadTransmission}
+	case "rtorrent":
+		return &dirSource{dir: dir, read: ReadRTorrent}
+	case "biglybt":
+		return &dirSource{dir: dir, read: ReadBiglyBT}
+	}
+	// torrent files are needed to convert priorities of v2 and hybrid torrents
+	searchPaths := opts.SearchPaths
+	if opts.Salvage {
+		return &dirSource{dir: dir, read: func(dir string) (map[string]*Torrent, error) {
+			return readUTorrentWithSalvage(dir, searchPaths)
+		}}
+	}
+	return &dirSource{dir: dir, read: func(dir string) (map[string]*Torrent, error) {
+		return ReadUTorrent(dir, searchPaths)
+	}}
+}
@@ -0,0 +1,97 @@
+package sources
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+	"github.com/rumanzo/bt2qbt/pkg/torrentStructures"
+)
+
+// blockSize clients download pieces by 16KiB blocks
+const blockSize = 16 * 1024
+
+// loadTorrentFile decode torrent file that clients keep in their directories
+func loadTorrentFile(path string) (*torrentStructures.Torrent, error) {
+	torrentFile := &torrentStructures.Torrent{}
+	if err := helpers.DecodeTorrentFile(path, torrentFile); err != nil {
+		return nil, err
+	}
+	if torrentFile.Info == nil {
+		return nil, fmt.Errorf("torrent file %v doesn't contain info", path)
+	}
+	return torrentFile, nil
+}
+
+// getTotalLength return size of all files of v1 torrent including pad files
+func getTotalLength(torrentFile *torrentStructures.Torrent) int64 {
+	if torrentFile.Info.Files == nil {
+		return torrentFile.Info.Length
+	}
+	var length int64
+	for _, file := range torrentFile.Info.Files {
+		length += file.Length
+	}
+	return length
+}
+
+/*
+setRenamedFiles add paths of files in torrent directory (that can be renamed) to renamed files of torrent,
+relative to torrent directory. Paths that are the same as in torrent file are skipped
+*/
+func setRenamedFiles(torrent *Torrent, torrentFile *torrentStructures.Torrent, torrentDir string, files []string) {
+	if torrentFile.IsSingle() {
+		return
+	}
+	fileList, _ := torrentFile.GetFileList()
+	for index, file := range files {
+		file = fileHelpers.Normalize(file, `/`)
+		relativePath := strings.TrimPrefix(file, torrentDir+`/`)
+		if file == "" || relativePath == file ||
+			(index < len(fileList) && relativePath == fileHelpers.Normalize(fileList[index], `/`)) {
+			continue
+		}
+		torrent.RenamedFiles = append(torrent.RenamedFiles, RenamedFile{Index: int64(index), Path: relativePath})
+	}
+}
+
+// getFullPieces return bitfield with all pieces
+func getFullPieces(numPieces int64) []byte {
+	pieces := make([]byte, (numPieces+7)/8)
+	for piece := int64(0); piece < numPieces; piece++ {
+		pieces[piece/8] |= 0x80 >> uint(piece%8)
+	}
+	return pieces
+}
+
+/*
+blocksToPieces convert bitfield of downloaded blocks (the high bit in the first byte corresponds to block index 0)
+to bitfield of completed pieces and partially downloaded pieces
+*/
+func blocksToPieces(blocks []byte, pieceLength int64, totalLength int64) ([]byte, []UnfinishedPiece) {
+	if pieceLength <= 0 || pieceLength%blockSize != 0 || totalLength <= 0 {
+		return nil, nil
+	}
+	blocksPerPiece := pieceLength / blockSize
+	totalBlocks := (totalLength + blockSize - 1) / blockSize
+	numPieces := (totalLength + pieceLength - 1) / pieceLength
+	pieces := make([]byte, (numPieces+7)/8)
+	var unfinished []UnfinishedPiece
+	for piece := int64(0); piece < numPieces; piece++ {
+		var downloaded []int64
+		var count int64
+		for block := piece * blocksPerPiece; block < (piece+1)*blocksPerPiece && block < totalBlocks; block++ {
+			count++
+			if block/8 < int64(len(blocks)) && blocks[block/8]&(0x80>>uint(block%8)) != 0 {
+				downloaded = append(downloaded, block-piece*blocksPerPiece)
+			}
+		}
+		if int64(len(downloaded)) == count {
+			pieces[piece/8] |= 0x80 >> uint(piece%8)
+		} else if len(downloaded) != 0 {
+			unfinished = append(unfinished, UnfinishedPiece{Piece: piece, Blocks: downloaded})
+		}
+	}
+	return pieces, unfinished
+}
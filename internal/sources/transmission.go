@@ -0,0 +1,163 @@
+package sources
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+)
+
+const (
+	TransmissionResumeDir   = "resume"
+	TransmissionTorrentsDir = "torrents"
+)
+
+// transmissionResume bencoded resume file of Transmission
+type transmissionResume struct {
+	AddedDate       int64                  `bencode:"added-date"`
+	Destination     string                 `bencode:"destination"`
+	Dnd             []int64                `bencode:"dnd"`
+	DoneDate        int64                  `bencode:"done-date"`
+	Downloaded      int64                  `bencode:"downloaded"`
+	DownloadingTime int64                  `bencode:"downloading-time-seconds"`
+	Files           []string               `bencode:"files"` // names of files including torrent directory, saved since files were renamed
+	Labels          []string               `bencode:"labels"`
+	Name            string                 `bencode:"name"`
+	Paused          int64                  `bencode:"paused"`
+	Priority        []int64                `bencode:"priority"` // -1 low, 0 normal, 1 high
+	Progress        transmissionProgress   `bencode:"progress"`
+	RatioLimit      transmissionRatioLimit `bencode:"ratio-limit"`
+	SeedingTime     int64                  `bencode:"seeding-time-seconds"`
+	SpeedLimitUp    transmissionSpeedLimit `bencode:"speed-limit-up"`
+	Uploaded        int64                  `bencode:"uploaded"`
+}
+
+type transmissionProgress struct {
+	Bitfield []byte `bencode:"bitfield"` // pieces bitfield of old versions
+	Blocks   []byte `bencode:"blocks"`   // blocks bitfield, "all" or "none"
+	Have     string `bencode:"have"`     // "all" for completed torrents
+}
+
+type transmissionRatioLimit struct {
+	RatioLimit interface{} `bencode:"ratio-limit"` // real number is saved as string
+	RatioMode  int64       `bencode:"ratio-mode"`  // 0 global, 1 single, 2 unlimited
+}
+
+type transmissionSpeedLimit struct {
+	SpeedBps      int64 `bencode:"speed-Bps"`
+	UseSpeedLimit int64 `bencode:"use-speed-limit"`
+}
+
+/*
+ReadTransmission read resume files from Transmission config directory and convert them to torrents.
+Resume files are paired with torrent files by name. Keys are paths to torrent files relative to dir
+or magnet links for torrents without metadata.
+*/
+func ReadTransmission(dir string) (map[string]*Torrent, error) {
+	resumeFiles, err := filepath.Glob(filepath.Join(dir, TransmissionResumeDir, "*.resume"))
+	if err != nil {
+		return nil, err
+	}
+	if len(resumeFiles) == 0 {
+		return nil, fmt.Errorf("can't find Transmission resume files in %v", filepath.Join(dir, TransmissionResumeDir))
+	}
+	sort.Strings(resumeFiles)
+	torrents := map[string]*Torrent{}
+	for _, resumeFile := range resumeFiles {
+		resume := &transmissionResume{}
+		if err = helpers.DecodeTorrentFile(resumeFile, resume); err != nil {
+			return nil, fmt.Errorf("can't decode Transmission resume file %v: %v", resumeFile, err)
+		}
+		baseName := strings.TrimSuffix(filepath.Base(resumeFile), ".resume")
+		key, torrent := convertTransmissionResume(dir, baseName, resume)
+		torrents[key] = torrent
+	}
+	return torrents, nil
+}
+
+func convertTransmissionResume(dir string, baseName string, resume *transmissionResume) (string, *Torrent) {
+	torrent := &Torrent{
+		ActiveTime:  resume.DownloadingTime + resume.SeedingTime,
+		AddedOn:     resume.AddedDate,
+		CompletedOn: resume.DoneDate,
+		Downloaded:  resume.Downloaded,
+		SeedingTime: resume.SeedingTime,
+		Started:     resume.Paused == 0,
+		Tags:        resume.Labels,
+		Uploaded:    resume.Uploaded,
+	}
+
+	numFiles := len(resume.Priority)
+	if len(resume.Dnd) > numFiles {
+		numFiles = len(resume.Dnd)
+	}
+	for i := 0; i < numFiles; i++ {
+		switch {
+		case i < len(resume.Dnd) && resume.Dnd[i] != 0:
+			torrent.FilePriorities = append(torrent.FilePriorities, PrioritySkip)
+		case i < len(resume.Priority) && resume.Priority[i] > 0:
+			torrent.FilePriorities = append(torrent.FilePriorities, PriorityHigh)
+		default:
+			torrent.FilePriorities = append(torrent.FilePriorities, PriorityNormal)
+		}
+	}
+
+	if resume.SpeedLimitUp.UseSpeedLimit != 0 && resume.SpeedLimitUp.SpeedBps > 0 {
+		torrent.UploadLimit = resume.SpeedLimitUp.SpeedBps
+	}
+	if resume.RatioLimit.RatioMode == 1 {
+		var ratio float64
+		switch value := resume.RatioLimit.RatioLimit.(type) {
+		case string:
+			ratio, _ = strconv.ParseFloat(value, 64)
+		case int64:
+			ratio = float64(value)
+		}
+		torrent.SeedLimits = &SeedLimits{Ratio: ratio}
+	}
+
+	key := path.Join(TransmissionTorrentsDir, baseName+".torrent")
+	torrentFile, err := loadTorrentFile(filepath.Join(dir, TransmissionTorrentsDir, baseName+".torrent"))
+	if err != nil {
+		torrent.Path = resume.Destination
+		// new versions save magnet links of torrents without metadata to .magnet files
+		if magnet, err := os.ReadFile(filepath.Join(dir, TransmissionTorrentsDir, baseName+".magnet")); err == nil {
+			return strings.TrimSpace(string(magnet)), torrent
+		}
+		// torrent file will be searched in search paths
+		return key, torrent
+	}
+
+	// name is renamed torrent directory or single file
+	torrentName := torrentFile.GetTorrentName()
+	name := resume.Name
+	if name == "" {
+		name = torrentName
+	}
+	torrent.Path = fileHelpers.Join([]string{resume.Destination, name}, `/`)
+	setRenamedFiles(torrent, torrentFile, name, resume.Files)
+
+	numPieces := int64(len(torrentFile.Info.Pieces)) / 20
+	switch progress := resume.Progress; {
+	case progress.Have == "all" || string(progress.Blocks) == "all":
+		torrent.Pieces = getFullPieces(numPieces)
+	case string(progress.Blocks) == "none" || progress.Have == "none":
+		// nothing is downloaded, it mustn't be guessed from priorities
+		torrent.Pieces = make([]byte, (numPieces+7)/8)
+	case len(progress.Bitfield) != 0:
+		torrent.Pieces = progress.Bitfield
+	case len(progress.Blocks) != 0:
+		pieces, unfinished := blocksToPieces(progress.Blocks, torrentFile.Info.PieceLength, getTotalLength(torrentFile))
+		if int64(len(pieces)) == (numPieces+7)/8 {
+			torrent.Pieces = pieces
+			torrent.Unfinished = unfinished
+		}
+	}
+	return key, torrent
+}
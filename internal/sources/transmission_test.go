@@ -0,0 +1,151 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zeebo/bencode"
+)
+
+func TestReadTransmission(t *testing.T) {
+	dir := t.TempDir()
+	for _, subDir := range []string{TransmissionResumeDir, TransmissionTorrentsDir} {
+		if err := os.Mkdir(filepath.Join(dir, subDir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	torrent, err := os.ReadFile("../../test/data/testfileset.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, TransmissionTorrentsDir, "testdir.3456bac107634970.torrent"), torrent, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// single file torrent that isn't downloaded at all
+	single, err := os.ReadFile("../../test/data/testfile1_single_v1.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, TransmissionTorrentsDir, "testfile1.txt.d95d90a72e0a53e8.torrent"), single, 0644); err != nil {
+		t.Fatal(err)
+	}
+	resumes := map[string]map[string]interface{}{
+		"testdir.3456bac107634970": {
+			"added-date":               1648068574,
+			"destination":              "/mnt/films",
+			"dnd":                      []int{0, 1, 0, 0, 0, 0, 0, 0, 0},
+			"done-date":                1648068600,
+			"downloaded":               297,
+			"downloading-time-seconds": 100,
+			"files": []string{
+				"renamed/testfile1.txt",
+				"renamed/testfile2.txt",
+				"renamed/testfile3_renamed.txt",
+				"renamed/dir1/testfile1.txt",
+				"renamed/dir2/testfile1.txt",
+				"renamed/dir2/testfile2.txt",
+				"renamed/dir3/testfile1.txt",
+				"renamed/dir3/testfile2.txt",
+				"renamed/dir3/testfile3.txt",
+			},
+			"labels":               []string{"films", "hd"},
+			"name":                 "renamed",
+			"paused":               1,
+			"priority":             []int{0, 0, 1, -1, 0, 0, 0, 0, 0},
+			"progress":             map[string]interface{}{"have": "all", "blocks": "all", "time-checked": 1648068600},
+			"ratio-limit":          map[string]interface{}{"ratio-limit": "1.500000", "ratio-mode": 1},
+			"seeding-time-seconds": 900,
+			"speed-limit-up":       map[string]interface{}{"speed-Bps": 102400, "use-speed-limit": 1, "use-global-speed-limit": 1},
+			"uploaded":             594,
+		},
+		"testfile1.txt.d95d90a72e0a53e8": {
+			"added-date":  1648068574,
+			"destination": "/downloads",
+			"progress":    map[string]interface{}{"blocks": "none"},
+		},
+		"0000000000000000000000000000000000000001": {
+			"added-date":  1648068574,
+			"destination": "/mnt/music",
+			"paused":      0,
+			"progress":    map[string]interface{}{"blocks": "none"},
+			"ratio-limit": map[string]interface{}{"ratio-limit": "2.000000", "ratio-mode": 0},
+		},
+	}
+	for name, resume := range resumes {
+		data, err := bencode.EncodeBytes(resume)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(dir, TransmissionResumeDir, name+".resume"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	magnet := "magnet:?xt=urn:btih:0000000000000000000000000000000000000001"
+	if err = os.WriteFile(filepath.Join(dir, TransmissionTorrentsDir, "0000000000000000000000000000000000000001.magnet"), []byte(magnet+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	normal, skip, high := PriorityNormal, PrioritySkip, PriorityHigh
+	expected := map[string]*Torrent{
+		"torrents/testdir.3456bac107634970.torrent": {
+			ActiveTime:     1000,
+			AddedOn:        1648068574,
+			CompletedOn:    1648068600,
+			Downloaded:     297,
+			FilePriorities: []Priority{normal, skip, high, normal, normal, normal, normal, normal, normal},
+			Path:           "/mnt/films/renamed",
+			Pieces:         []byte{0x80},
+			RenamedFiles:   []RenamedFile{{Index: 2, Path: "testfile3_renamed.txt"}},
+			SeedLimits:     &SeedLimits{Ratio: 1.5},
+			SeedingTime:    900,
+			Tags:           []string{"films", "hd"},
+			UploadLimit:    102400,
+			Uploaded:       594,
+		},
+		"torrents/testfile1.txt.d95d90a72e0a53e8.torrent": {
+			AddedOn: 1648068574,
+			Path:    "/downloads/testfile1.txt",
+			Pieces:  []byte{0x00},
+			Started: true,
+		},
+		magnet: {
+			AddedOn: 1648068574,
+			Path:    "/mnt/music",
+			Started: true,
+		},
+	}
+	torrents, err := ReadTransmission(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(torrents, expected) {
+		for key, torrent := range torrents {
+			t.Logf("%v: %#v", key, torrent)
+		}
+		t.Fatalf("Unexpected torrents")
+	}
+
+	if _, err = ReadTransmission(t.TempDir()); err == nil {
+		t.Fatalf("Expected error for directory without resume files")
+	}
+}
+
+func TestBlocksToPieces(t *testing.T) {
+	// 3 pieces of 4 blocks, the last piece has 2 blocks
+	pieceLength := int64(4 * blockSize)
+	totalLength := int64(10 * blockSize)
+	blocks := []byte{0xf6, 0xc0} // 1111 0110 11
+	pieces, unfinished := blocksToPieces(blocks, pieceLength, totalLength)
+	if !reflect.DeepEqual(pieces, []byte{0xa0}) {
+		t.Fatalf("Unexpected pieces %08b", pieces)
+	}
+	// piece 1 with blocks 1 and 2
+	if expected := []UnfinishedPiece{{Piece: 1, Blocks: []int64{1, 2}}}; !reflect.DeepEqual(unfinished, expected) {
+		t.Fatalf("Unexpected unfinished %v", unfinished)
+	}
+	if pieces, _ = blocksToPieces(blocks, 1000, totalLength); pieces != nil {
+		t.Fatalf("Unexpected pieces %08b for piece length that isn't multiple of block size", pieces)
+	}
+}
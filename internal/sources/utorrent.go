@@ -0,0 +1,438 @@
+package sources
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rumanzo/bt2qbt/pkg/bencodeScanner"
+	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+	"github.com/rumanzo/bt2qbt/pkg/torrentStructures"
+	"github.com/rumanzo/bt2qbt/pkg/utorrentStructs"
+	"github.com/zeebo/bencode"
+)
+
+const UTorrentResumeFile = "resume.dat"
+
+// resumeFileCheck result of choosing resume file, it is reported on every run
+type resumeFileCheck struct {
+	Used        string // path of resume file that is used
+	Damaged     string // path of damaged resume.dat. It's empty if resume.dat is valid
+	Reason      error  // why resume.dat is damaged
+	Differences int    // number of torrents that differ between used and damaged resume files. -1 if damaged file can't be decoded
+}
+
+func (check *resumeFileCheck) String() string {
+	if check.Damaged == "" {
+		return fmt.Sprintf("%v is used", check.Used)
+	}
+	if check.Used == check.Damaged {
+		return fmt.Sprintf("%v is damaged (%v) and there is no valid backup, it's used as is", check.Damaged, check.Reason)
+	}
+	if check.Differences < 0 {
+		return fmt.Sprintf("%v is damaged (%v), %v is used instead", check.Damaged, check.Reason, check.Used)
+	}
+	return fmt.Sprintf("%v is damaged (%v), %v is used instead. %v torrents differ between them",
+		check.Damaged, check.Reason, check.Used, check.Differences)
+}
+
+// ReadUTorrent read resume.dat from uTorrent/BitTorrent directory. Keys are paths to torrent files or magnet links.
+// If resume.dat is damaged, resume.dat.old and other backups are used. Torrent files are searched in search paths too
+func ReadUTorrent(dir string, searchPaths []string) (map[string]*Torrent, error) {
+	resumeFile, check, err := readUTorrentResumeFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	log.Println(check)
+	resumeItems, err := convertUTorrentResumeFile(resumeFile)
+	if err != nil {
+		return nil, err
+	}
+	return convertUTorrentResumeItems(dir, searchPaths, resumeItems), nil
+}
+
+// convertUTorrentResumeItems convert resume items of resume.dat to torrents
+func convertUTorrentResumeItems(dir string, searchPaths []string, resumeItems map[string]*utorrentStructs.ResumeItem) map[string]*Torrent {
+	torrents := map[string]*Torrent{}
+	for key, resumeItem := range resumeItems {
+		torrents[key] = convertUTorrentResumeItem(dir, searchPaths, key, resumeItem)
+	}
+	return torrents
+}
+
+func convertUTorrentResumeItem(dir string, searchPaths []string, key string, resumeItem *utorrentStructs.ResumeItem) *Torrent {
+	torrent := &Torrent{
+		ActiveTime:       resumeItem.Runtime,
+		AddedOn:          resumeItem.AddedOn,
+		Category:         resumeItem.Label,
+		CompletedOn:      resumeItem.CompletedOn,
+		DisableDHT:       resumeItem.DHT != nil && *resumeItem.DHT == 0,
+		DisablePEX:       resumeItem.PEX != nil && *resumeItem.PEX == 0,
+		DownloadLimit:    resumeItem.DownSpeed,
+		Downloaded:       resumeItem.Downloaded,
+		InfoHash:         resumeItem.Info,
+		LastActive:       resumeItem.LastActive,
+		LastSeenComplete: resumeItem.LastSeenComplete,
+		Name:             resumeItem.Caption,
+		Path:             resumeItem.Path,
+		Pieces:           resumeItem.Have,
+		SeedingTime:      resumeItem.SeedTime,
+		Started:          resumeItem.Started != 0,
+		SuperSeeding:     resumeItem.SuperSeed != 0,
+		Tags:             resumeItem.Labels,
+		UploadLimit:      resumeItem.UpSpeed,
+		UploadSlots:      resumeItem.ULSlots,
+		Uploaded:         resumeItem.Uploaded,
+	}
+	if resumeItem.Trackers != nil {
+		// trackers may be nested lists, uTorrent doesn't keep tiers
+		torrent.Trackers = [][]string{helpers.GetStrings(resumeItem.Trackers)}
+	}
+	if resumeItem.TrackerMode != 0 {
+		torrent.Warnings = append(torrent.Warnings,
+			fmt.Sprintf("tracker mode %v of uTorrent has no equivalent in qBittorrent and wasn't transferred", resumeItem.TrackerMode))
+	}
+	if resumeItem.Order != nil && *resumeItem.Order >= 0 {
+		torrent.QueuePosition = resumeItem.Order
+	}
+	if resumeItem.OverrideSeedSettings != 0 {
+		// ratio is multiplied by 1000
+		torrent.SeedLimits = &SeedLimits{
+			Ratio:       float64(resumeItem.WantedRatio) / 1000,
+			SeedingTime: resumeItem.WantedSeedtime,
+		}
+	}
+	// layout of priorities and piece length of blocks depend on torrent file. Torrent file that isn't found here
+	// isn't found later too, so only magnet links are converted without it
+	var torrentFile *torrentStructures.Torrent
+	if !strings.HasPrefix(key, "magnet:?") {
+		torrentFile, _ = loadUTorrentTorrentFile(dir, searchPaths, key)
+	}
+	torrent.FilePriorities = getUTorrentPriorities(resumeItem.Prio, torrentFile != nil && torrentFile.IsV2OrHybryd())
+	torrent.RenamedFiles = getUTorrentRenamedFiles(resumeItem.Targets)
+	if blocks, ok := resumeItem.Blocks.(string); ok && blocks != "" && torrentFile != nil {
+		torrent.Unfinished = getUTorrentUnfinished(blocks, torrentFile.Info.PieceLength)
+	}
+	return torrent
+}
+
+/*
+loadUTorrentTorrentFile load torrent file of resume.dat key as transfer searches it: absolute path or path relative to
+uTorrent directory, then file with the same name in search paths
+*/
+func loadUTorrentTorrentFile(dir string, searchPaths []string, key string) (*torrentStructures.Torrent, error) {
+	key = helpers.HandleCesu8(key)
+	paths := []string{key}
+	if !fileHelpers.IsAbs(key) && !strings.HasPrefix(key, "/") {
+		paths = []string{filepath.Join(dir, key)}
+	}
+	for _, searchPath := range searchPaths {
+		paths = append(paths, filepath.Join(searchPath, fileHelpers.Base(key)))
+	}
+	var err error
+	for _, path := range paths {
+		var torrentFile *torrentStructures.Torrent
+		if torrentFile, err = loadTorrentFile(path); err == nil {
+			return torrentFile, nil
+		}
+	}
+	return nil, err
+}
+
+/*
+getUTorrentPriorities convert uTorrent priorities: 0 and 128 are skipped files, 1-8 low and normal, 9-15 high.
+uTorrent keeps two priorities per file of v2 and hybrid torrents, so only the first of them is used
+*/
+func getUTorrentPriorities(prio []byte, hybrid bool) []Priority {
+	if hybrid {
+		trimmed := make([]byte, 0, (len(prio)+1)/2)
+		for i := 0; i < len(prio); i += 2 {
+			trimmed = append(trimmed, prio[i])
+		}
+		prio = trimmed
+	}
+	var priorities []Priority
+	for _, c := range prio {
+		switch i := int(c); {
+		case i >= 1 && i <= 8:
+			priorities = append(priorities, PriorityNormal)
+		case i > 8 && i <= 15:
+			priorities = append(priorities, PriorityHigh)
+		default:
+			priorities = append(priorities, PrioritySkip)
+		}
+	}
+	return priorities
+}
+
+// getUTorrentRenamedFiles convert uTorrent targets. Target is list of file index and absolute path or parts of path
+// relative to torrent directory
+func getUTorrentRenamedFiles(targets [][]interface{}) []RenamedFile {
+	var renamedFiles []RenamedFile
+	for _, target := range targets {
+		if len(target) < 2 {
+			continue
+		}
+		index, ok := target[0].(int64)
+		if !ok {
+			continue
+		}
+		var parts []string
+		for _, part := range target[1:] {
+			if part, ok := part.(string); ok {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		renamedFile := RenamedFile{Index: index, Path: strings.Join(parts, `/`)}
+		if fileHelpers.IsAbs(parts[0]) {
+			renamedFile.Path = parts[0]
+		}
+		renamedFiles = append(renamedFiles, renamedFile)
+	}
+	sort.SliceStable(renamedFiles, func(i, j int) bool {
+		return renamedFiles[i].Index < renamedFiles[j].Index
+	})
+	return renamedFiles
+}
+
+/*
+getUTorrentUnfinished convert uTorrent blocks to partially downloaded pieces.
+uTorrent blocks is string of records: little endian uint32 piece index and bitmask of downloaded 16KiB blocks
+(one bit per block, least significant bit first)
+*/
+func getUTorrentUnfinished(blocks string, pieceLength int64) []UnfinishedPiece {
+	if pieceLength <= 0 {
+		return nil
+	}
+	blocksPerPiece := (pieceLength + blockSize - 1) / blockSize
+	recordLength := 4 + int((blocksPerPiece+7)/8)
+	if len(blocks)%recordLength != 0 {
+		return nil
+	}
+	var unfinished []UnfinishedPiece
+	for i := 0; i < len(blocks); i += recordLength {
+		piece := int64(binary.LittleEndian.Uint32([]byte(blocks[i : i+4])))
+		var downloaded []int64
+		for block := int64(0); block < blocksPerPiece; block++ {
+			if blocks[i+4+int(block/8)]&(1<<uint(block%8)) != 0 {
+				downloaded = append(downloaded, block)
+			}
+		}
+		if len(downloaded) != 0 {
+			unfinished = append(unfinished, UnfinishedPiece{Piece: piece, Blocks: downloaded})
+		}
+	}
+	return unfinished
+}
+
+// convertUTorrentResumeFile decode raw torrents of resume.dat to resume items
+func convertUTorrentResumeFile(resumeFile map[string]bencode.RawMessage) (map[string]*utorrentStructs.ResumeItem, error) {
+	// hate utorrent for heterogeneous resume.dat scheme
+	torrents := map[string]bencode.RawMessage{}
+	for key, value := range resumeFile {
+		if key != ".fileguard" && key != "rec" {
+			torrents[key] = value
+		}
+	}
+	b, _ := bencode.EncodeBytes(torrents)
+	resumeItems := map[string]*utorrentStructs.ResumeItem{}
+	err := bencode.DecodeBytes(b, &resumeItems)
+	if err != nil {
+		return nil, fmt.Errorf("can't convert resume.dat. Err: %v", err)
+	}
+	return resumeItems, nil
+}
+
+/*
+readUTorrentResumeFile return raw values of the first valid resume file. uTorrent often leaves half-written resume.dat
+after crash, so .fileguard (uppercase hex SHA-1 of resume file without it) is checked,
+and resume.dat.old and timestamped backups (newest first) are tried if resume.dat is damaged.
+If there is no valid backup, resume.dat with wrong .fileguard is used as is
+*/
+func readUTorrentResumeFile(dir string) (map[string]bencode.RawMessage, *resumeFileCheck, error) {
+	resumeFilePath := filepath.Join(dir, UTorrentResumeFile)
+	if _, err := os.Stat(resumeFilePath); os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("can't find uTorrent\\Bittorrent resume file")
+	}
+	resumeFile, err := loadResumeFile(resumeFilePath)
+	if err == nil {
+		return resumeFile, &resumeFileCheck{Used: resumeFilePath}, nil
+	}
+	check := &resumeFileCheck{Damaged: resumeFilePath, Reason: err, Differences: -1}
+	damaged := resumeFile
+
+	for _, backupPath := range getResumeFileBackups(dir) {
+		backup, err := loadResumeFile(backupPath)
+		if err != nil {
+			continue
+		}
+		check.Used = backupPath
+		if damaged != nil {
+			check.Differences = countDifferences(damaged, backup)
+		}
+		return backup, check, nil
+	}
+	if damaged != nil {
+		// resume.dat was decoded, only its .fileguard is wrong
+		check.Used = resumeFilePath
+		return damaged, check, nil
+	}
+	return nil, check, fmt.Errorf("can't decode uTorrent\\Bittorrent resume file and there is no valid backup: %v", check.Reason)
+}
+
+// loadResumeFile decode resume file and check its .fileguard. Decoded values are returned even if .fileguard is wrong
+func loadResumeFile(path string) (map[string]bencode.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	resumeFile := map[string]bencode.RawMessage{}
+	if err = bencode.DecodeBytes(data, &resumeFile); err != nil {
+		return nil, fmt.Errorf("can't decode: %v", err)
+	}
+	rawFileguard, ok := resumeFile[".fileguard"]
+	if !ok {
+		// resume files of old versions don't have .fileguard
+		return resumeFile, nil
+	}
+	var fileguard string
+	if err = bencode.DecodeBytes(rawFileguard, &fileguard); err != nil {
+		return resumeFile, fmt.Errorf("can't decode .fileguard: %v", err)
+	}
+	if hash := getFileguard(resumeFile); !strings.EqualFold(fileguard, hash) {
+		return resumeFile, errors.New(".fileguard doesn't match content")
+	}
+	return resumeFile, nil
+}
+
+// getFileguard return uppercase hex SHA-1 of resume file without .fileguard
+func getFileguard(resumeFile map[string]bencode.RawMessage) string {
+	withoutFileguard := map[string]bencode.RawMessage{}
+	for key, value := range resumeFile {
+		if key != ".fileguard" {
+			withoutFileguard[key] = value
+		}
+	}
+	data, _ := bencode.EncodeBytes(withoutFileguard)
+	hash := sha1.Sum(data)
+	return strings.ToUpper(hex.EncodeToString(hash[:]))
+}
+
+// getResumeFileBackups return resume.dat.old, then other backups of resume.dat from newest to oldest
+func getResumeFileBackups(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, UTorrentResumeFile+".*"))
+	modTimes := map[string]int64{}
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			modTimes[match] = info.ModTime().UnixNano()
+		}
+	}
+	var backups []string
+	for backup := range modTimes {
+		backups = append(backups, backup)
+	}
+	oldPath := filepath.Join(dir, UTorrentResumeFile+".old")
+	sort.Slice(backups, func(i, j int) bool {
+		if (backups[i] == oldPath) != (backups[j] == oldPath) {
+			return backups[i] == oldPath
+		}
+		if modTimes[backups[i]] != modTimes[backups[j]] {
+			return modTimes[backups[i]] > modTimes[backups[j]]
+		}
+		return backups[i] > backups[j]
+	})
+	return backups
+}
+
+// countDifferences return number of torrents that exist only in one resume file or have different values
+func countDifferences(first map[string]bencode.RawMessage, second map[string]bencode.RawMessage) int {
+	var differences int
+	for key, value := range first {
+		if key == ".fileguard" || key == "rec" {
+			continue
+		}
+		if otherValue, ok := second[key]; !ok || !bytes.Equal(value, otherValue) {
+			differences++
+		}
+	}
+	for key := range second {
+		if key == ".fileguard" || key == "rec" {
+			continue
+		}
+		if _, ok := first[key]; !ok {
+			differences++
+		}
+	}
+	return differences
+}
+
+/*
+SalvageUTorrent recover torrents from damaged resume.dat that can't be decoded. Top-level dictionary is walked entry by
+entry, so every well-formed torrent before corruption point is recovered and damaged values are skipped.
+Keys of torrents that can't be recovered are returned too
+*/
+func SalvageUTorrent(dir string, searchPaths []string) (map[string]*Torrent, []string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, UTorrentResumeFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, damaged, err := bencodeScanner.SalvageDictEntries(data, isResumeItem)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't salvage resume.dat: %v", err)
+	}
+	resumeItems := map[string]*utorrentStructs.ResumeItem{}
+	for _, entry := range entries {
+		if entry.Key == ".fileguard" || entry.Key == "rec" {
+			continue
+		}
+		resumeItem := &utorrentStructs.ResumeItem{}
+		if err = bencode.DecodeBytes(data[entry.ValueStart:entry.End], resumeItem); err != nil {
+			damaged = append(damaged, entry.Key)
+			continue
+		}
+		resumeItems[entry.Key] = resumeItem
+	}
+	if len(resumeItems) == 0 {
+		return nil, damaged, fmt.Errorf("can't salvage any torrent from resume.dat")
+	}
+	sort.Strings(damaged)
+	return convertUTorrentResumeItems(dir, searchPaths, resumeItems), damaged, nil
+}
+
+// isResumeItem report if key with value may be torrent of resume.dat. It's used to find torrents after damaged data
+func isResumeItem(key string, value []byte) bool {
+	if len(value) == 0 || value[0] != 'd' {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(key), ".torrent") || strings.HasPrefix(key, "magnet:?")
+}
+
+// readUTorrentWithSalvage read resume.dat as ReadUTorrent and salvage it if it can't be read and has no valid backup
+func readUTorrentWithSalvage(dir string, searchPaths []string) (map[string]*Torrent, error) {
+	torrents, err := ReadUTorrent(dir, searchPaths)
+	if err == nil {
+		return torrents, nil
+	}
+	log.Printf("%v. Try to salvage torrents\n", err)
+	torrents, damaged, err := SalvageUTorrent(dir, searchPaths)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Salvaged %v torrents from resume.dat, %v torrents can't be recovered\n", len(torrents), len(damaged))
+	for _, key := range damaged {
+		log.Printf("Can't recover torrent %v\n", key)
+	}
+	return torrents, nil
+}
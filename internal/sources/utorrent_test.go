@@ -0,0 +1,277 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rumanzo/bt2qbt/pkg/utorrentStructs"
+	"github.com/zeebo/bencode"
+)
+
+func TestReadUTorrentResumeFile(t *testing.T) {
+	// encodeResumeFile return bencoded resume file with valid or wrong .fileguard
+	encodeResumeFile := func(torrents map[string]string, validFileguard bool) string {
+		resumeFile := map[string]bencode.RawMessage{"rec": bencode.RawMessage("i1e")}
+		for key, path := range torrents {
+			data, _ := bencode.EncodeString(map[string]string{"path": path})
+			resumeFile[key] = bencode.RawMessage(data)
+		}
+		fileguard := "0000000000000000000000000000000000000000"
+		if validFileguard {
+			fileguard = getFileguard(resumeFile)
+		}
+		data, _ := bencode.EncodeString(fileguard)
+		resumeFile[".fileguard"] = bencode.RawMessage(data)
+		encoded, _ := bencode.EncodeString(resumeFile)
+		return encoded
+	}
+	valid := encodeResumeFile(map[string]string{"1.torrent": "/1", "2.torrent": "/2"}, true)
+
+	type ResumeFileCase struct {
+		name                string
+		files               map[string]string // name of file in source directory and its content. The first is the oldest
+		order               []string
+		mustFail            bool
+		expectedUsed        string
+		expectedDamaged     bool
+		expectedDifferences int
+	}
+	cases := []ResumeFileCase{
+		{
+			name:         "001 valid resume.dat",
+			files:        map[string]string{"resume.dat": valid, "resume.dat.old": valid},
+			order:        []string{"resume.dat.old", "resume.dat"},
+			expectedUsed: "resume.dat",
+		},
+		{
+			name: "002 wrong fileguard, resume.dat.old is used",
+			files: map[string]string{
+				"resume.dat":     encodeResumeFile(map[string]string{"1.torrent": "/new", "3.torrent": "/3"}, false),
+				"resume.dat.old": valid,
+			},
+			order:               []string{"resume.dat.old", "resume.dat"},
+			expectedUsed:        "resume.dat.old",
+			expectedDamaged:     true,
+			expectedDifferences: 3,
+		},
+		{
+			name: "003 truncated resume.dat, damaged resume.dat.old, the newest backup is used",
+			files: map[string]string{
+				"resume.dat":                valid[:len(valid)/2],
+				"resume.dat.old":            encodeResumeFile(map[string]string{"1.torrent": "/1"}, false),
+				"resume.dat.1600000000.bak": encodeResumeFile(map[string]string{"1.torrent": "/1"}, true),
+				"resume.dat.1700000000.bak": valid,
+			},
+			order:               []string{"resume.dat.1600000000.bak", "resume.dat.1700000000.bak", "resume.dat.old", "resume.dat"},
+			expectedUsed:        "resume.dat.1700000000.bak",
+			expectedDamaged:     true,
+			expectedDifferences: -1,
+		},
+		{
+			name: "004 wrong fileguard without valid backup, resume.dat is used",
+			files: map[string]string{
+				"resume.dat":     encodeResumeFile(map[string]string{"1.torrent": "/1"}, false),
+				"resume.dat.old": valid[:len(valid)/2],
+			},
+			order:               []string{"resume.dat.old", "resume.dat"},
+			expectedUsed:        "resume.dat",
+			expectedDamaged:     true,
+			expectedDifferences: -1,
+		},
+		{
+			name:     "005 undecodable resume.dat without valid backup. mustFail",
+			files:    map[string]string{"resume.dat": valid[:len(valid)/2]},
+			order:    []string{"resume.dat"},
+			mustFail: true,
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dir := t.TempDir()
+			modTime := time.Now().Add(-time.Hour)
+			for _, name := range testCase.order {
+				path := filepath.Join(dir, name)
+				if err := os.WriteFile(path, []byte(testCase.files[name]), 0644); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				modTime = modTime.Add(time.Minute)
+				if err := os.Chtimes(path, modTime, modTime); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			resumeFile, check, err := readUTorrentResumeFile(dir)
+			if err != nil && !testCase.mustFail {
+				t.Fatalf("Unexpected error: %v", err)
+			} else if err == nil && testCase.mustFail {
+				t.Fatalf("Test must fail, but it doesn't")
+			}
+			if testCase.mustFail {
+				return
+			}
+			if check.Used != filepath.Join(dir, testCase.expectedUsed) || (check.Damaged != "") != testCase.expectedDamaged {
+				t.Fatalf("Unexpected check: %v", check)
+			}
+			if testCase.expectedDamaged && check.Differences != testCase.expectedDifferences {
+				t.Fatalf("Unexpected number of differences: %v", check.Differences)
+			}
+			resumeItems, err := convertUTorrentResumeFile(resumeFile)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(resumeItems) == 0 || resumeItems["1.torrent"] == nil || resumeItems["1.torrent"].Path != "/1" {
+				t.Fatalf("Unexpected resume items: %v", resumeItems)
+			}
+		})
+	}
+}
+
+func TestSalvageUTorrent(t *testing.T) {
+	dir := t.TempDir()
+	data := "d10:.fileguard40:0000000000000000000000000000000000000000" +
+		"9:1.torrentd4:path2:/1e" +
+		"9:2.torrentd4:pathi2ee" +
+		"9:3.torrentd4:path2:/3x" +
+		"9:4.torrentd4:path2:/4e" +
+		"9:5.torrentd4:path"
+	if err := os.WriteFile(filepath.Join(dir, UTorrentResumeFile), []byte(data), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := ReadUTorrent(dir, nil); err == nil {
+		t.Fatalf("Damaged resume.dat must not be read without salvage")
+	}
+	resumeItems, damaged, err := SalvageUTorrent(dir, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(resumeItems) != 2 || resumeItems["1.torrent"].Path != "/1" || resumeItems["4.torrent"].Path != "/4" {
+		t.Fatalf("Unexpected salvaged torrents: %v", resumeItems)
+	}
+	if expected := []string{"2.torrent", "3.torrent", "5.torrent"}; !reflect.DeepEqual(damaged, expected) {
+		t.Fatalf("Unexpected damaged torrents:\n Got: %v\n Expect %v\n", damaged, expected)
+	}
+}
+
+func TestConvertUTorrentResumeItem(t *testing.T) {
+	dht, pex, order := int64(0), int64(1), int64(3)
+	normal, skip, high := PriorityNormal, PrioritySkip, PriorityHigh
+	type ConvertCase struct {
+		name       string
+		resumeItem *utorrentStructs.ResumeItem
+		expected   *Torrent
+	}
+	cases := []ConvertCase{
+		{
+			name: "001 priorities, trackers, targets and flags",
+			resumeItem: &utorrentStructs.ResumeItem{
+				Caption:   "renamed",
+				DHT:       &dht,
+				Info:      "\x01",
+				Label:     "films",
+				Labels:    []string{"hd"},
+				Order:     &order,
+				PEX:       &pex,
+				Prio:      []byte{0, 128, 2, 5, 8, 9, 15, 127},
+				Started:   1,
+				SuperSeed: 1,
+				Targets: [][]interface{}{
+					{int64(3), "dir", "renamed.txt"},
+					{int64(1), "/mnt/other/file.txt"},
+				},
+				Trackers: []interface{}{
+					"http://tracker1/announce",
+					[]interface{}{"http://tracker2/announce", []interface{}{"udp://tracker3:80/announce"}},
+				},
+				UpSpeed: 102400,
+			},
+			expected: &Torrent{
+				DisableDHT:     true,
+				FilePriorities: []Priority{skip, skip, normal, normal, normal, high, high, skip},
+				InfoHash:       "\x01",
+				Category:       "films",
+				Name:           "renamed",
+				QueuePosition:  &order,
+				RenamedFiles: []RenamedFile{
+					{Index: 1, Path: "/mnt/other/file.txt"},
+					{Index: 3, Path: "dir/renamed.txt"},
+				},
+				Started:      true,
+				SuperSeeding: true,
+				Tags:         []string{"hd"},
+				Trackers: [][]string{{
+					"http://tracker1/announce",
+					"http://tracker2/announce",
+					"udp://tracker3:80/announce",
+				}},
+				UploadLimit: 102400,
+			},
+		},
+		{
+			name: "002 overridden seed settings",
+			resumeItem: &utorrentStructs.ResumeItem{
+				OverrideSeedSettings: 1,
+				WantedRatio:          1500,
+				WantedSeedtime:       3600,
+			},
+			expected: &Torrent{SeedLimits: &SeedLimits{Ratio: 1.5, SeedingTime: 3600}},
+		},
+		{
+			name: "003 seed settings without override are ignored",
+			resumeItem: &utorrentStructs.ResumeItem{
+				WantedRatio:    1500,
+				WantedSeedtime: 3600,
+			},
+			expected: &Torrent{},
+		},
+		{
+			name: "004 non-default tracker mode",
+			resumeItem: &utorrentStructs.ResumeItem{
+				TrackerMode: 1,
+			},
+			expected: &Torrent{
+				Warnings: []string{"tracker mode 1 of uTorrent has no equivalent in qBittorrent and wasn't transferred"},
+			},
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			torrent := convertUTorrentResumeItem(t.TempDir(), nil, "1.torrent", testCase.resumeItem)
+			if !reflect.DeepEqual(torrent, testCase.expected) {
+				t.Fatalf("Unexpected torrent:\n Got: %#v\n Expect %#v\n", torrent, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestGetUTorrentUnfinished(t *testing.T) {
+	// 4 blocks per piece, one byte of bitmask, the least significant bit first
+	pieceLength := int64(4 * blockSize)
+	blocks := "\x00\x00\x00\x00\x0f" + "\x02\x00\x00\x00\x05" + "\x03\x00\x00\x00\x00"
+	expected := []UnfinishedPiece{
+		{Piece: 0, Blocks: []int64{0, 1, 2, 3}},
+		{Piece: 2, Blocks: []int64{0, 2}},
+	}
+	if unfinished := getUTorrentUnfinished(blocks, pieceLength); !reflect.DeepEqual(unfinished, expected) {
+		t.Fatalf("Unexpected unfinished:\n Got: %v\n Expect %v\n", unfinished, expected)
+	}
+	if unfinished := getUTorrentUnfinished(blocks[:7], pieceLength); unfinished != nil {
+		t.Fatalf("Unexpected unfinished %v for blocks with wrong length", unfinished)
+	}
+}
+
+func TestConvertUTorrentResumeItemHybrid(t *testing.T) {
+	resumeItem := &utorrentStructs.ResumeItem{Prio: []byte{8, 0, 15, 0, 0, 0}}
+	normal, skip, high := PriorityNormal, PrioritySkip, PriorityHigh
+	// torrent file is found in search path
+	torrent := convertUTorrentResumeItem(t.TempDir(), []string{"../../test/data"}, "testdir_hybrid.torrent", resumeItem)
+	if expected := []Priority{normal, high, skip}; !reflect.DeepEqual(torrent.FilePriorities, expected) {
+		t.Fatalf("Unexpected priorities of hybrid torrent:\n Got: %v\n Expect %v\n", torrent.FilePriorities, expected)
+	}
+	torrent = convertUTorrentResumeItem("../../test/data", nil, "testdir_v1.torrent", resumeItem)
+	if expected := []Priority{normal, skip, high, skip, skip, skip}; !reflect.DeepEqual(torrent.FilePriorities, expected) {
+		t.Fatalf("Unexpected priorities of v1 torrent:\n Got: %v\n Expect %v\n", torrent.FilePriorities, expected)
+	}
+}
@@ -1,7 +1,7 @@
 package transfer
 
 type Channels struct {
-	ComChannel     chan string
-	ErrChannel     chan string
+	ResultChannel  chan *Result
+	PlanChannel    chan PlanItem
 	BoundedChannel chan bool
 }
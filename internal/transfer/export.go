@@ -0,0 +1,347 @@
+package transfer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+	"github.com/rumanzo/bt2qbt/pkg/qBittorrentStructures"
+	"github.com/rumanzo/bt2qbt/pkg/torrentStructures"
+	"github.com/rumanzo/bt2qbt/pkg/utorrentStructs"
+	"github.com/zeebo/bencode"
+)
+
+const ResumeFileName = "resume.dat"
+
+/*
+ExportResumeItem convert qBittorrent fastresume back to uTorrent resume item. Path of resume item is content path:
+save path with torrent directory for multi file torrents (unless files are saved without it) or path of file
+for single file torrents. Renamed files are saved as targets relative to content path or as absolute paths.
+*/
+func ExportResumeItem(fastresume *qBittorrentStructures.QBittorrentFastresume, torrentFile *torrentStructures.Torrent,
+	separator string) (*utorrentStructs.ResumeItem, error) {
+	if torrentFile.IsV2Only() {
+		return nil, fmt.Errorf("v2 only torrents aren't supported by uTorrent")
+	}
+	resumeItem := &utorrentStructs.ResumeItem{
+		AddedOn:     fastresume.AddedTime,
+		Caption:     fastresume.QbtName,
+		CompletedOn: fastresume.CompletedTime,
+		Downloaded:  fastresume.TotalDownloaded,
+		Info:        fastresume.InfoHash,
+		Label:       fastresume.QBtCategory,
+		Labels:      fastresume.QbtTags,
+		Runtime:     fastresume.ActiveTime,
+		SeedTime:    fastresume.SeedingTime,
+		Started:     1,
+		UpSpeed:     fastresume.UploadRateLimit,
+		Uploaded:    fastresume.TotalUploaded,
+	}
+	if fastresume.Paused != 0 {
+		resumeItem.Started = 0
+	}
+	if fastresume.LastUpload > 0 || fastresume.LastDownload > 0 {
+		resumeItem.LastActive = fastresume.LastUpload
+		if fastresume.LastDownload > resumeItem.LastActive {
+			resumeItem.LastActive = fastresume.LastDownload
+		}
+	}
+	if fastresume.CompletedTime == 0 {
+		resumeItem.LastSeenComplete = fastresume.LastSeenComplete
+	}
+	if fastresume.UploadRateLimit < 0 {
+		resumeItem.UpSpeed = 0
+	}
+	if fastresume.QbtRatioLimit >= 0 {
+		resumeItem.OverrideSeedSettings = 1
+		resumeItem.WantedRatio = fastresume.QbtRatioLimit
+	}
+
+	var trackers []interface{}
+	for _, tier := range fastresume.Trackers {
+		for _, tracker := range tier {
+			trackers = append(trackers, tracker)
+		}
+	}
+	if len(trackers) != 0 {
+		resumeItem.Trackers = trackers
+	}
+
+	resumeItem.Have = getHaveFromPieces(fastresume.Pieces)
+
+	savePath := fastresume.SavePath
+	if savePath == "" {
+		savePath = fastresume.QbtSavePath
+	}
+	savePath = fileHelpers.Normalize(savePath, "/")
+	torrentName := torrentFile.GetTorrentName()
+	mappedFiles := make([]string, len(fastresume.MappedFiles))
+	for index, mappedFile := range fastresume.MappedFiles {
+		if mappedFile != "" {
+			mappedFiles[index] = fileHelpers.Normalize(mappedFile, "/")
+		}
+	}
+
+	if torrentFile.IsSingle() {
+		fileName := torrentName
+		if len(mappedFiles) != 0 && mappedFiles[0] != "" {
+			fileName = mappedFiles[0]
+		}
+		resumeItem.Path = toContentPath(savePath, fileName, separator)
+		resumeItem.Prio = exportPriority(fastresume.FilePriority, 1, false)
+		return resumeItem, nil
+	}
+
+	fileList, _ := torrentFile.GetFileList()
+	// qBittorrent keeps content layout as mapped files, so files without torrent directory are all mapped without it
+	withoutSubfolder := fastresume.QBtContentLayout == "NoSubfolder"
+	if fastresume.QBtContentLayout == "" && len(mappedFiles) == len(fileList) {
+		withoutSubfolder = true
+		for _, mappedFile := range mappedFiles {
+			if mappedFile == "" || strings.HasPrefix(mappedFile, torrentName+"/") {
+				withoutSubfolder = false
+				break
+			}
+		}
+	}
+	contentPath := savePath
+	if !withoutSubfolder {
+		contentPath = fileHelpers.Join([]string{savePath, torrentName}, "/")
+	}
+	resumeItem.Path = fileHelpers.Normalize(contentPath, separator)
+
+	for index, mappedFile := range mappedFiles {
+		if mappedFile == "" || index >= len(fileList) {
+			continue
+		}
+		if !isAbsPath(mappedFile) {
+			mappedFile = fileHelpers.Join([]string{savePath, mappedFile}, "/")
+		}
+		relativePath := strings.TrimPrefix(mappedFile, contentPath+"/")
+		if relativePath == fileHelpers.Normalize(fileList[index], "/") {
+			continue
+		}
+		if relativePath == mappedFile {
+			// file is saved outside of torrent directory
+			resumeItem.Targets = append(resumeItem.Targets, []interface{}{int64(index), fileHelpers.Normalize(mappedFile, separator)})
+		} else {
+			resumeItem.Targets = append(resumeItem.Targets, []interface{}{int64(index), fileHelpers.Normalize(relativePath, separator)})
+		}
+	}
+	resumeItem.Prio = exportPriority(fastresume.FilePriority, len(fileList), torrentFile.IsV2OrHybryd())
+	return resumeItem, nil
+}
+
+func toContentPath(savePath string, name string, separator string) string {
+	if isAbsPath(name) {
+		return fileHelpers.Normalize(name, separator)
+	}
+	return fileHelpers.Join([]string{savePath, name}, separator)
+}
+
+/*
+exportPriority convert libtorrent file priorities to uTorrent priorities. Files without priority have default one.
+uTorrent keeps priorities of pad files of hybrid torrents, so each priority is repeated for following pad file
+*/
+func exportPriority(filePriority []int64, numFiles int, hybrid bool) []byte {
+	var prio []byte
+	for i := 0; i < numFiles; i++ {
+		var p byte = 8
+		if i < len(filePriority) {
+			switch {
+			case filePriority[i] == 0:
+				p = 0
+			case filePriority[i] > 4:
+				p = 15
+			}
+		}
+		prio = append(prio, p)
+		if hybrid && i != numFiles-1 {
+			prio = append(prio, p)
+		}
+	}
+	return prio
+}
+
+// getHaveFromPieces convert libtorrent pieces (byte per piece, the lowest bit is set for downloaded piece) to uTorrent bitfield
+func getHaveFromPieces(pieces []byte) []byte {
+	if len(pieces) == 0 {
+		return nil
+	}
+	have := make([]byte, (len(pieces)+7)/8)
+	for i, piece := range pieces {
+		if piece&1 != 0 {
+			have[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return have
+}
+
+/*
+EncodeResumeFile encode uTorrent resume.dat with .fileguard, that is uppercase hex SHA-1 of resume.dat without it.
+Values of existing resume.dat are kept if they aren't replaced
+*/
+func EncodeResumeFile(resumeItems map[string]*utorrentStructs.ResumeItem, existing map[string]bencode.RawMessage) ([]byte, error) {
+	resumeFile := map[string]bencode.RawMessage{}
+	for key, value := range existing {
+		if key != ".fileguard" {
+			resumeFile[key] = value
+		}
+	}
+	for key, resumeItem := range resumeItems {
+		raw, err := bencode.EncodeBytes(resumeItem)
+		if err != nil {
+			return nil, err
+		}
+		resumeFile[key] = raw
+	}
+	data, err := bencode.EncodeBytes(resumeFile)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha1.Sum(data)
+	resumeFile[".fileguard"], err = bencode.EncodeBytes(strings.ToUpper(hex.EncodeToString(hash[:])))
+	if err != nil {
+		return nil, err
+	}
+	return bencode.EncodeBytes(resumeFile)
+}
+
+// HandleExport convert all torrents from qBittorrent directory to resume.dat and torrent files in uTorrent directory
+func HandleExport(opts *options.Opts) bool {
+	fastresumePaths, err := filepath.Glob(filepath.Join(opts.QBitDir, "*.fastresume"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't list fastresume files with error:\n%v\n", err)
+		return true
+	}
+	sort.Strings(fastresumePaths)
+
+	writer := NewOutputWriter(opts.OnConflict)
+	if !opts.DryRun {
+		// journal is kept where --undo reads it
+		journal, err := OpenJournal(opts.GetQBitDataDir(), JournalModeExport, opts.QBitDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't open journal with error:\n%v\n", err)
+			return true
+		}
+		writer.Journal = journal
+	}
+
+	resumeItems := map[string]*utorrentStructs.ResumeItem{}
+	var results []*Result
+	for num, fastresumePath := range fastresumePaths {
+		startTime := time.Now()
+		hash := strings.TrimSuffix(filepath.Base(fastresumePath), ".fastresume")
+		torrentPath := filepath.Join(opts.QBitDir, hash+".torrent")
+		key := hash + ".torrent"
+		result := &Result{Key: key, Hash: hash, Status: StatusImported}
+		if opts.DryRun {
+			result.Status = StatusPlanned
+		}
+
+		fastresume := &qBittorrentStructures.QBittorrentFastresume{QbtRatioLimit: -2000}
+		torrentFile := &torrentStructures.Torrent{}
+		if err := helpers.DecodeTorrentFile(fastresumePath, fastresume); err != nil {
+			result.Fail(ErrorFastresumeParse, fmt.Sprintf("Can't decode fastresume file %v with error %v", fastresumePath, err))
+		} else if _, err = os.Stat(torrentPath); errors.Is(err, os.ErrNotExist) {
+			// torrents without metadata don't have torrent file, uTorrent can't add them from resume.dat
+			result.Status = StatusSkipped
+			result.Warnings = append(result.Warnings, "torrent has no metadata yet, add its magnet link to uTorrent manually")
+		} else if err = helpers.DecodeTorrentFile(torrentPath, torrentFile); err != nil || torrentFile.Info == nil {
+			result.Fail(ErrorTorrentParse, fmt.Sprintf("Can't decode torrent file %v with error %v", torrentPath, err))
+		} else if resumeItem, err := ExportResumeItem(fastresume, torrentFile, opts.PathSeparator); err != nil {
+			result.Fail(ErrorTorrentParse, fmt.Sprintf("Can't export torrent %v with error %v", torrentPath, err))
+		} else {
+			resumeItems[key] = resumeItem
+			if !opts.DryRun {
+				dst := filepath.Join(opts.BitDir, key)
+				if _, err = writer.CopyTorrent(torrentPath, dst); err != nil {
+					result.Fail(ErrorWrite, fmt.Sprintf("Can't copy torrent file %v to %v with error %v", torrentPath, dst, err))
+					delete(resumeItems, key)
+				} else {
+					result.OutputPaths = []string{dst}
+				}
+			}
+		}
+		result.Elapsed = time.Since(startTime).Seconds()
+		fmt.Fprintf(os.Stderr, "%v/%v %v \n", num+1, len(fastresumePaths), result)
+		results = append(results, result)
+	}
+
+	var resumeFileErr error
+	if !opts.DryRun {
+		// torrents of existing resume.dat are kept unless other policy is set explicitly
+		resumeWriter := &OutputWriter{Policy: ConflictMerge, Journal: writer.Journal}
+		if opts.OnConflict != "" {
+			resumeWriter.Policy = ConflictPolicy(opts.OnConflict)
+		}
+		resumePath := filepath.Join(opts.BitDir, ResumeFileName)
+		written, err := writeResumeFile(resumeWriter, resumePath, resumeItems)
+		if err != nil {
+			resumeFileErr = err
+			fmt.Fprintf(os.Stderr, "Can't write resume.dat with error:\n%v\n", err)
+		}
+		// torrents are exported only with resume.dat
+		for _, result := range results {
+			if result.Status != StatusImported {
+				continue
+			}
+			if err != nil {
+				result.Fail(ErrorWrite, fmt.Sprintf("Can't write %v with error %v", resumePath, err))
+			} else if !written {
+				result.Status = StatusSkipped
+			}
+		}
+		if err == nil && !written {
+			fmt.Fprintf(os.Stderr, "%v already exists and was skipped, exported torrents aren't added to it\n", resumePath)
+		}
+	}
+
+	summary := NewSummary(results)
+	wasErrors := summary.ByStatus[StatusFailed] != 0 || resumeFileErr != nil
+	if opts.Report != "" {
+		if err := WriteReport(opts.Report, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't write report with error:\n%v\n", err)
+			wasErrors = true
+		}
+	}
+	if writer.Journal != nil {
+		if err := writer.Journal.Finish(); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't finish journal with error:\n%v\n", err)
+			wasErrors = true
+		}
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, summary)
+	log.Println("Ended")
+	if wasErrors {
+		log.Println("Not all torrents was exported")
+	}
+	return wasErrors
+}
+
+// writeResumeFile write resume.dat with respect to conflict policy. On merge torrents of existing resume.dat are kept.
+// It returns false if existing resume.dat was skipped
+func writeResumeFile(writer *OutputWriter, path string, resumeItems map[string]*utorrentStructs.ResumeItem) (bool, error) {
+	data, err := EncodeResumeFile(resumeItems, nil)
+	if err != nil {
+		return false, err
+	}
+	return writer.write(path, data, func(existing []byte, _ []byte) ([]byte, error) {
+		existingMap := map[string]bencode.RawMessage{}
+		if err := bencode.DecodeBytes(existing, &existingMap); err != nil {
+			return nil, err
+		}
+		return EncodeResumeFile(resumeItems, existingMap)
+	})
+}
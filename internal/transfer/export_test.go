@@ -0,0 +1,255 @@
+package transfer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+	"github.com/rumanzo/bt2qbt/pkg/qBittorrentStructures"
+	"github.com/rumanzo/bt2qbt/pkg/torrentStructures"
+	"github.com/rumanzo/bt2qbt/pkg/utorrentStructs"
+	"github.com/zeebo/bencode"
+)
+
+func TestExportResumeItem(t *testing.T) {
+	type ExportCase struct {
+		name        string
+		fastresume  *qBittorrentStructures.QBittorrentFastresume
+		torrentPath string
+		separator   string
+		expected    *utorrentStructs.ResumeItem
+	}
+	cases := []ExportCase{
+		{
+			name:        "001 multi file torrent with original layout",
+			fastresume:  decodeFastresume(t, "../../test/data/testdir_v1.fastresume"),
+			torrentPath: "../../test/data/testdir_v1.torrent",
+			separator:   `\`,
+			expected: &utorrentStructs.ResumeItem{
+				AddedOn:     1650146608,
+				CompletedOn: 1650146608,
+				Have:        []byte{0x80},
+				Info:        "\x34\x56\xba\xc1\x07\x63\x49\x70\xb0\x22\x67\x7c\x6b\xfa\xa5\x84\x06\x5e\x09\x17",
+				Path:        `C:\Users\ruman\GolandProjects\bt2qbt\test\data\testdir`,
+				Prio:        []byte{8, 8, 8, 8, 8, 8, 8, 8, 8},
+				Started:     1,
+			},
+		},
+		{
+			name:        "002 paused single file torrent",
+			fastresume:  decodeFastresume(t, "../../test/data/testfile1_single_v1.fastresume"),
+			torrentPath: "../../test/data/testfile1_single_v1.torrent",
+			separator:   `\`,
+			expected: &utorrentStructs.ResumeItem{
+				AddedOn:     1650146407,
+				CompletedOn: 1650146407,
+				Have:        []byte{0x80},
+				Info:        "\xd9\x5d\x90\xa7\x2e\x0a\x53\xe8\x8e\x6f\x73\xa3\x90\x5c\xa4\xfb\x59\x73\x47\x2e",
+				Path:        `C:\Users\ruman\GolandProjects\bt2qbt\test\data\testdir\testfile1.txt`,
+				Prio:        []byte{8},
+				SeedTime:    1,
+				Started:     0,
+			},
+		},
+		{
+			name: "003 renamed files without subfolder",
+			fastresume: &qBittorrentStructures.QBittorrentFastresume{
+				AddedTime:        1650146608,
+				FilePriority:     []int64{1, 0, 6, 1, 1, 1, 1, 1, 1},
+				LastDownload:     1650149000,
+				LastSeenComplete: 1650148000,
+				LastUpload:       1650150000,
+				MappedFiles:      []string{"testfile1.txt", "testfile2.txt", "renamed.txt", "dir1/testfile1.txt", "dir2/testfile1.txt", "dir2/testfile2.txt", "dir3/testfile1.txt", "dir3/testfile2.txt", "/mnt/other/testfile3.txt"},
+				Paused:           1,
+				QBtCategory:      "films",
+				QbtName:          "renamed set",
+				QbtRatioLimit:    1500,
+				QbtTags:          []string{"hd"},
+				SavePath:         "/mnt/films",
+				SeedingTime:      900,
+				TotalDownloaded:  297,
+				TotalUploaded:    594,
+				Trackers:         [][]string{{"http://tier0/announce"}, {"http://tier1/announce", "http://tier1b/announce"}},
+				UploadRateLimit:  102400,
+			},
+			torrentPath: "../../test/data/testfileset.torrent",
+			separator:   "/",
+			expected: &utorrentStructs.ResumeItem{
+				AddedOn:              1650146608,
+				Caption:              "renamed set",
+				Downloaded:           297,
+				Label:                "films",
+				Labels:               []string{"hd"},
+				LastActive:           1650150000,
+				LastSeenComplete:     1650148000,
+				OverrideSeedSettings: 1,
+				Path:                 "/mnt/films",
+				Prio:                 []byte{8, 0, 15, 8, 8, 8, 8, 8, 8},
+				SeedTime:             900,
+				Started:              0,
+				Targets:              [][]interface{}{{int64(2), "renamed.txt"}, {int64(8), "/mnt/other/testfile3.txt"}},
+				Trackers:             []interface{}{"http://tier0/announce", "http://tier1/announce", "http://tier1b/announce"},
+				UpSpeed:              102400,
+				Uploaded:             594,
+				WantedRatio:          1500,
+			},
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			torrentFile := &torrentStructures.Torrent{}
+			if err := helpers.DecodeTorrentFile(testCase.torrentPath, torrentFile); err != nil {
+				t.Fatal(err)
+			}
+			resumeItem, err := ExportResumeItem(testCase.fastresume, torrentFile, testCase.separator)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(resumeItem, testCase.expected) {
+				t.Fatalf("Unexpected resume item:\n Got: %#v\n Expect %#v\n", resumeItem, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	qbtDir := t.TempDir()
+	utDir := t.TempDir()
+	hashes := map[string]string{
+		"testdir_v1":          "3456bac107634970b022677c6bfaa584065e0917",
+		"testfile1_single_v1": "d95d90a72e0a53e88e6f73a3905ca4fb5973472e",
+	}
+	for name, hash := range hashes {
+		for _, ext := range []string{".fastresume", ".torrent"} {
+			if err := helpers.CopyFile("../../test/data/"+name+ext, filepath.Join(qbtDir, hash+ext)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// fastresume without torrent file is torrent without metadata, it's skipped
+	if err := helpers.CopyFile("../../test/data/testdir_v2.fastresume", filepath.Join(qbtDir, "0000000000000000000000000000000000000001.fastresume")); err != nil {
+		t.Fatal(err)
+	}
+	opts := &options.Opts{QBitDir: qbtDir, BitDir: utDir, PathSeparator: `\`}
+
+	if wasErrors := HandleExport(opts); wasErrors {
+		t.Fatalf("Torrent without metadata mustn't fail export")
+	}
+	data, err := os.ReadFile(filepath.Join(utDir, ResumeFileName))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resumeFile := map[string]bencode.RawMessage{}
+	if err = bencode.DecodeBytes(data, &resumeFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var fileguard string
+	if err = bencode.DecodeBytes(resumeFile[".fileguard"], &fileguard); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	delete(resumeFile, ".fileguard")
+	withoutFileguard, err := bencode.EncodeBytes(resumeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash := sha1.Sum(withoutFileguard); fileguard != strings.ToUpper(hex.EncodeToString(hash[:])) {
+		t.Fatalf("Unexpected fileguard %v", fileguard)
+	}
+	for _, hash := range hashes {
+		if _, ok := resumeFile[hash+".torrent"]; !ok {
+			t.Fatalf("Torrent %v wasn't exported", hash)
+		}
+		if _, err = os.Stat(filepath.Join(utDir, hash+".torrent")); err != nil {
+			t.Fatalf("Torrent file %v wasn't copied", hash)
+		}
+	}
+	if len(resumeFile) != len(hashes) {
+		t.Fatalf("Unexpected torrents in resume.dat: %v", len(resumeFile))
+	}
+
+	// exported torrents are kept on merge
+	if err = os.Remove(filepath.Join(qbtDir, "3456bac107634970b022677c6bfaa584065e0917.fastresume")); err != nil {
+		t.Fatal(err)
+	}
+	opts.OnConflict = string(ConflictMerge)
+	HandleExport(opts)
+	resumeFile = map[string]bencode.RawMessage{}
+	if err = helpers.DecodeTorrentFile(filepath.Join(utDir, ResumeFileName), &resumeFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := resumeFile["3456bac107634970b022677c6bfaa584065e0917.torrent"]; !ok || len(resumeFile) != len(hashes)+1 {
+		t.Fatalf("Existing torrents weren't kept on merge")
+	}
+
+	// existing resume.dat is merged by default
+	resumeFile["utorrent.torrent"] = bencode.RawMessage("d4:path2:/1e")
+	data, err = bencode.EncodeBytes(resumeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(utDir, ResumeFileName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts.OnConflict = ""
+	HandleExport(opts)
+	resumeFile = map[string]bencode.RawMessage{}
+	if err = helpers.DecodeTorrentFile(filepath.Join(utDir, ResumeFileName), &resumeFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := resumeFile["utorrent.torrent"]; !ok {
+		t.Fatalf("Torrents of uTorrent were dropped from resume.dat")
+	}
+
+	// export is undone, journal is kept in qBittorrent directory
+	if _, err = os.Stat(filepath.Join(utDir, JournalFileName)); err == nil {
+		t.Fatalf("Journal mustn't be left in uTorrent directory")
+	}
+	if _, err = Undo(opts.GetQBitDataDir()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resumeData, err := os.ReadFile(filepath.Join(utDir, ResumeFileName)); err != nil || string(resumeData) != string(data) {
+		t.Fatalf("resume.dat wasn't restored by undo: %v", err)
+	}
+}
+
+func TestHandleExportSkip(t *testing.T) {
+	qbtDir := t.TempDir()
+	utDir := t.TempDir()
+	for _, ext := range []string{".fastresume", ".torrent"} {
+		if err := helpers.CopyFile("../../test/data/testfile1_single_v1"+ext, filepath.Join(qbtDir, "d95d90a72e0a53e88e6f73a3905ca4fb5973472e"+ext)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	existing := []byte("d16:utorrent.torrentd4:path2:/1ee")
+	if err := os.WriteFile(filepath.Join(utDir, ResumeFileName), existing, 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &options.Opts{QBitDir: qbtDir, BitDir: utDir, PathSeparator: `\`, OnConflict: string(ConflictSkip), Report: filepath.Join(t.TempDir(), "report.json")}
+	if wasErrors := HandleExport(opts); wasErrors {
+		t.Fatalf("Unexpected errors")
+	}
+	if data, _ := os.ReadFile(filepath.Join(utDir, ResumeFileName)); string(data) != string(existing) {
+		t.Fatalf("Existing resume.dat was overwritten with skip policy")
+	}
+	report, err := os.ReadFile(opts.Report)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(report), `"status": "skipped"`) {
+		t.Fatalf("Torrents must be reported as skipped: %s", report)
+	}
+}
+
+func decodeFastresume(t *testing.T, path string) *qBittorrentStructures.QBittorrentFastresume {
+	fastresume := &qBittorrentStructures.QBittorrentFastresume{}
+	if err := helpers.DecodeTorrentFile(path, fastresume); err != nil {
+		t.Fatal(err)
+	}
+	return fastresume
+}
@@ -2,36 +2,42 @@ package transfer
 
 import (
 	"github.com/rumanzo/bt2qbt/pkg/helpers"
-	"time"
+	"github.com/zeebo/bencode"
 )
 
 func (transfer *TransferStructure) HandleStructures() {
 
-	if ok := transfer.ResumeItem.Targets; ok != nil {
-		for _, entry := range transfer.ResumeItem.Targets {
-			transfer.Targets[entry[0].(int64)] = helpers.HandleCesu8(entry[1].(string))
-		}
+	for _, renamedFile := range transfer.Torrent.RenamedFiles {
+		transfer.Targets[renamedFile.Index] = helpers.HandleCesu8(renamedFile.Path)
 	}
 
 	// if torrent name was renamed, add modified name
 	transfer.HandleCaption()
-	transfer.Fastresume.ActiveTime = transfer.ResumeItem.Runtime
-	transfer.Fastresume.AddedTime = transfer.ResumeItem.AddedOn
-	transfer.Fastresume.CompletedTime = transfer.ResumeItem.CompletedOn
-	transfer.Fastresume.Info = transfer.TorrentFileRaw["info"]
-	transfer.Fastresume.InfoHash = transfer.ResumeItem.Info
-	transfer.Fastresume.SeedingTime = transfer.ResumeItem.Runtime
+	transfer.Fastresume.ActiveTime = transfer.Torrent.ActiveTime
+	transfer.Fastresume.AddedTime = transfer.Torrent.AddedOn
+	transfer.Fastresume.CompletedTime = transfer.Torrent.CompletedOn
+	if transfer.Magnet {
+		transfer.Fastresume.InfoHash = transfer.Torrent.InfoHash
+	} else {
+		transfer.Fastresume.Info = bencode.RawMessage(transfer.TorrentInfoRaw)
+		transfer.HandleInfoHash()
+	}
+	transfer.Fastresume.SeedingTime = transfer.Torrent.SeedingTime
 	transfer.HandlePriority() //  handle priorities before handling pieces and state
 	transfer.HandleState()
-	transfer.Fastresume.FinishedTime = int64(time.Since(time.Unix(transfer.ResumeItem.CompletedOn, 0)).Minutes())
+	transfer.HandleActivity()
 
 	transfer.HandleTotalDownloaded()
-	transfer.Fastresume.TotalUploaded = transfer.ResumeItem.Uploaded
-	transfer.Fastresume.UploadRateLimit = transfer.ResumeItem.UpSpeed
+	transfer.Fastresume.TotalUploaded = transfer.Torrent.Uploaded
+	transfer.HandleLimits()
+	transfer.HandleSeedSettings()
 	transfer.HandleTags()
 	transfer.HandleLabels()
 
 	transfer.HandleTrackers()
+	if transfer.Magnet {
+		transfer.HandleMagnet()
+	}
 
 	/*
 		pieces maps to a string whose length is a multiple of 20. It is to be subdivided into strings of length 20,
@@ -0,0 +1,268 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+)
+
+const (
+	JournalFileName      = "bt2qbt_journal.jsonl"
+	JournalBackupDirName = "bt2qbt_journal_backups"
+)
+
+// modes of runs that write journal. Interrupted run can be continued only by run of the same mode
+const (
+	JournalModeImport   = "import"
+	JournalModeRelocate = "relocate"
+	JournalModeExport   = "export"
+)
+
+const (
+	journalOpStart = "start" // run is started, the first entry with mode and source of run
+	journalOpFile  = "file"  // file will be created or overwritten
+	journalOpDone  = "done"  // torrent is processed
+	journalOpEnd   = "end"   // run is finished
+)
+
+type JournalEntry struct {
+	Op     string `json:"op"`
+	Path   string `json:"path,omitempty"`
+	Backup string `json:"backup,omitempty"` // copy of file before overwriting. Empty if file was created
+	Key    string `json:"key,omitempty"`
+	Hash   string `json:"hash,omitempty"` // torrent id in qBittorrent of imported torrent
+	Mode   string `json:"mode,omitempty"`
+	Source string `json:"source,omitempty"` // directory that run reads
+}
+
+// Journal record every file that migration creates or overwrites, so previous state can be restored.
+// Journal of interrupted run is continued, and already processed torrents aren't processed again
+type Journal struct {
+	Path        string
+	BackupDir   string
+	mu          sync.Mutex
+	file        *os.File
+	entries     int
+	interrupted bool
+	files       map[string]bool
+	completed   map[string]string // key -> hash of imported torrent
+}
+
+/*
+OpenJournal continue journal of interrupted run in dir or start new one. Interrupted run of other mode or other source
+must be continued or undone first, otherwise its undo data would be lost. Journal of finished run is replaced,
+so only the last run can be undone
+*/
+func OpenJournal(dir string, mode string, source string) (*Journal, error) {
+	journal := &Journal{
+		Path:      filepath.Join(dir, JournalFileName),
+		BackupDir: filepath.Join(dir, JournalBackupDirName),
+		files:     map[string]bool{},
+		completed: map[string]string{},
+	}
+	entries, err := readJournal(journal.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(entries) != 0 && entries[len(entries)-1].Op != journalOpEnd {
+		// journals without start entry were written only by imports
+		interruptedMode, interruptedSource := JournalModeImport, ""
+		if entries[0].Op == journalOpStart {
+			interruptedMode, interruptedSource = entries[0].Mode, entries[0].Source
+		}
+		if interruptedMode != mode || interruptedSource != "" && interruptedSource != source {
+			return nil, fmt.Errorf("%v contains interrupted %v run of %v. Repeat that run to finish it or run with --undo",
+				journal.Path, interruptedMode, interruptedSource)
+		}
+		for _, entry := range entries {
+			switch entry.Op {
+			case journalOpFile:
+				journal.files[entry.Path] = true
+			case journalOpDone:
+				journal.completed[entry.Key] = entry.Hash
+			}
+		}
+		journal.entries = len(entries)
+		journal.interrupted = true
+		// rewrite journal without broken last line, so new entries can be appended
+		var data []byte
+		for _, entry := range entries {
+			line, err := json.Marshal(entry)
+			if err != nil {
+				return nil, err
+			}
+			data = append(append(data, line...), '\n')
+		}
+		if err = helpers.WriteFileAtomic(journal.Path, data); err != nil {
+			return nil, err
+		}
+	} else {
+		// previous run was finished, so only new run can be undone
+		if len(entries) != 0 {
+			previousMode := JournalModeImport
+			if entries[0].Op == journalOpStart {
+				previousMode = entries[0].Mode
+			}
+			log.Printf("Previous %v run can't be undone anymore, undo data is replaced by this run\n", previousMode)
+		}
+		if err = os.RemoveAll(journal.BackupDir); err != nil {
+			return nil, err
+		}
+		if err = os.Remove(journal.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if err = os.MkdirAll(journal.BackupDir, 0755); err != nil {
+		return nil, err
+	}
+	journal.file, err = os.OpenFile(journal.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if !journal.interrupted {
+		if err = journal.write(JournalEntry{Op: journalOpStart, Mode: mode, Source: source}); err != nil {
+			journal.file.Close()
+			return nil, err
+		}
+	}
+	return journal, nil
+}
+
+// IsInterrupted report if journal continues interrupted run
+func (journal *Journal) IsInterrupted() bool {
+	return journal.interrupted
+}
+
+func (journal *Journal) IsCompleted(key string) bool {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	_, ok := journal.completed[key]
+	return ok
+}
+
+// GetCompletedHash return hash of torrent that was imported by interrupted run, so it still can be queued
+func (journal *Journal) GetCompletedHash(key string) string {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	return journal.completed[key]
+}
+
+// Record must be called before file is written. Existing file is copied to backup directory
+func (journal *Journal) Record(path string) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if journal.files[path] {
+		// the first record has original file
+		return nil
+	}
+	entry := JournalEntry{Op: journalOpFile, Path: path}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		entry.Backup = filepath.Join(journal.BackupDir, fmt.Sprintf("%v_%v", journal.entries, filepath.Base(path)))
+		if err = helpers.WriteFileAtomic(entry.Backup, data); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err = journal.write(entry); err != nil {
+		return err
+	}
+	journal.files[path] = true
+	return nil
+}
+
+// Complete mark torrent as processed. Hash is empty if torrent wasn't imported
+func (journal *Journal) Complete(key string, hash string) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if err := journal.write(JournalEntry{Op: journalOpDone, Key: key, Hash: hash}); err != nil {
+		return err
+	}
+	journal.completed[key] = hash
+	return nil
+}
+
+// Finish mark run as finished and close journal
+func (journal *Journal) Finish() error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if err := journal.write(JournalEntry{Op: journalOpEnd}); err != nil {
+		journal.file.Close()
+		return err
+	}
+	return journal.file.Close()
+}
+
+func (journal *Journal) write(entry JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err = journal.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	journal.entries++
+	return journal.file.Sync()
+}
+
+func readJournal(path string) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// last line can be broken if process was killed while writing
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Undo restore state of files before last run, then remove journal
+func Undo(dir string) (int, error) {
+	path := filepath.Join(dir, JournalFileName)
+	entries, err := readJournal(path)
+	if err != nil {
+		return 0, err
+	}
+	var restored int
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Op != journalOpFile {
+			continue
+		}
+		if entry.Backup == "" {
+			if err = os.Remove(entry.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return restored, err
+			}
+		} else {
+			data, err := os.ReadFile(entry.Backup)
+			if err != nil {
+				return restored, err
+			}
+			if err = helpers.WriteFileAtomic(entry.Path, data); err != nil {
+				return restored, err
+			}
+		}
+		restored++
+	}
+	if err = os.RemoveAll(filepath.Join(dir, JournalBackupDirName)); err != nil {
+		return restored, err
+	}
+	return restored, os.Remove(path)
+}
@@ -0,0 +1,157 @@
+package transfer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/internal/sources"
+)
+
+func TestJournalInterruptedAndUndo(t *testing.T) {
+	dir := t.TempDir()
+	existingPath := filepath.Join(dir, "existing.fastresume")
+	newPath := filepath.Join(dir, "new.fastresume")
+	if err := os.WriteFile(existingPath, []byte("original"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	journal, err := OpenJournal(dir, JournalModeImport, "source")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if journal.IsInterrupted() {
+		t.Fatalf("New journal mustn't be interrupted")
+	}
+	for _, path := range []string{existingPath, newPath} {
+		if err = journal.Record(path); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err = os.WriteFile(path, []byte("changed"), 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err = journal.Complete("1.torrent", "aa"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// emulate process that was killed while writing journal
+	journal.file.Write([]byte(`{"op":"do`))
+	journal.file.Close()
+
+	journal, err = OpenJournal(dir, JournalModeImport, "source")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !journal.IsInterrupted() || !journal.IsCompleted("1.torrent") || journal.IsCompleted("2.torrent") {
+		t.Fatalf("Interrupted journal wasn't continued")
+	}
+	if hash := journal.GetCompletedHash("1.torrent"); hash != "aa" {
+		t.Fatalf("Unexpected hash of completed torrent: %v", hash)
+	}
+	// file is already recorded, so original content stays in backup
+	if err = journal.Record(existingPath); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = journal.Complete("2.torrent", ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = journal.Finish(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	restored, err := Undo(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if restored != 2 {
+		t.Fatalf("Unexpected number of restored files: %v", restored)
+	}
+	if data, _ := os.ReadFile(existingPath); string(data) != "original" {
+		t.Fatalf("Existing file wasn't restored: %v", string(data))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Created files and journal must be removed, but found %v", entries)
+	}
+}
+
+func TestOpenJournalOtherMode(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, JournalModeImport, "source")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// interrupted import
+	journal.file.Close()
+
+	type OpenCase struct {
+		name     string
+		mode     string
+		source   string
+		mustFail bool
+	}
+	cases := []OpenCase{
+		{name: "001 relocate after interrupted import. mustFail", mode: JournalModeRelocate, source: "qbt", mustFail: true},
+		{name: "002 export after interrupted import. mustFail", mode: JournalModeExport, source: "qbt", mustFail: true},
+		{name: "003 import of other source. mustFail", mode: JournalModeImport, source: "other", mustFail: true},
+		{name: "004 import of the same source", mode: JournalModeImport, source: "source"},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			journal, err := OpenJournal(dir, testCase.mode, testCase.source)
+			if err != nil && !testCase.mustFail {
+				t.Fatalf("Unexpected error: %v", err)
+			} else if err == nil && testCase.mustFail {
+				t.Fatalf("Test must fail, but it doesn't")
+			}
+			if err == nil {
+				if !journal.IsInterrupted() {
+					t.Fatalf("Interrupted journal wasn't continued")
+				}
+				journal.file.Close()
+			}
+		})
+	}
+}
+
+func TestHandleTorrentsUndo(t *testing.T) {
+	qbtDir := t.TempDir()
+	categories := filepath.Join(qbtDir, "categories.json")
+	if err := os.WriteFile(categories, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	opts := &options.Opts{
+		BitDir:        "../../test/data",
+		QBitDir:       qbtDir,
+		Categories:    categories,
+		PathSeparator: `/`,
+		SearchPaths:   []string{"../../test/data"},
+	}
+	torrents := map[string]*sources.Torrent{
+		"testfileset.torrent": {Path: "/mnt/films/testfileset", Tags: []string{"label"}},
+	}
+	if wasErrors := HandleTorrents(opts, torrents); wasErrors {
+		t.Fatalf("Unexpected errors while processing")
+	}
+	if _, err := os.Stat(filepath.Join(qbtDir, "3456bac107634970b022677c6bfaa584065e0917.fastresume")); err != nil {
+		t.Fatalf("Fastresume wasn't written: %v", err)
+	}
+
+	if _, err := Undo(qbtDir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entries, err := os.ReadDir(qbtDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "categories.json" {
+		t.Fatalf("Unexpected files after undo: %v", entries)
+	}
+	if data, _ := os.ReadFile(categories); string(data) != "{}" {
+		t.Fatalf("Categories weren't restored: %v", string(data))
+	}
+}
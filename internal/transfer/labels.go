@@ -8,29 +8,50 @@ import (
 	"os"
 )
 
-func ProcessLabels(opts *options.Opts, newtags []string) error {
+// readCategories return categories from categories.json and flag if file doesn't exist yet
+func readCategories(path string) (map[string]map[string]string, bool, error) {
 	categories := map[string]map[string]string{}
 
 	// check if categories is new file. If it exists it must be unmarshaled. Default categories file contains only {}
-	var categoriesIsNew bool
-	_, err := os.Stat(opts.Categories)
+	_, err := os.Stat(path)
 	if errors.Is(err, os.ErrNotExist) {
-		categoriesIsNew = true
+		return categories, true, nil
 	} else if err != nil {
-		return errors.New(fmt.Sprintf("Unexpected error while open categories.json. Error:\n%v\n", err))
+		return nil, false, errors.New(fmt.Sprintf("Unexpected error while open categories.json. Error:\n%v\n", err))
 	}
 
-	if !categoriesIsNew {
-		dataRaw, err := os.ReadFile(opts.Categories)
-		if err != nil {
-			return errors.New(fmt.Sprintf("Unexpected error while read categories.json. Error:\n%v\n", err))
-		}
+	dataRaw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, errors.New(fmt.Sprintf("Unexpected error while read categories.json. Error:\n%v\n", err))
+	}
 
-		err = json.Unmarshal(dataRaw, &categories)
-		if err != nil {
-			return errors.New(fmt.Sprintf("Unexpected error while unmarshaling categories.json. Error:\n%v\n", err))
+	err = json.Unmarshal(dataRaw, &categories)
+	if err != nil {
+		return nil, false, errors.New(fmt.Sprintf("Unexpected error while unmarshaling categories.json. Error:\n%v\n", err))
+	}
+	return categories, false, nil
+}
+
+// PlanLabels return categories that ProcessLabels will add to categories.json
+func PlanLabels(opts *options.Opts, newtags []string) ([]string, error) {
+	categories, _, err := readCategories(opts.Categories)
+	if err != nil {
+		return nil, err
+	}
+	var planned []string
+	for _, tag := range newtags {
+		if _, ok := categories[tag]; !ok {
+			planned = append(planned, tag)
 		}
 	}
+	return planned, nil
+}
+
+func ProcessLabels(opts *options.Opts, newtags []string) error {
+	categories, categoriesIsNew, err := readCategories(opts.Categories)
+	if err != nil {
+		return err
+	}
 
 	for _, tag := range newtags {
 		if _, ok := categories[tag]; !ok { // append only if key doesn't already exist
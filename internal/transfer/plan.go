@@ -0,0 +1,95 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// PlanItem describe what will be done with torrent. It's filled on dry run instead of writing files
+type PlanItem struct {
+	Key             string   `json:"key"`
+	TorrentFilePath string   `json:"torrent_file_path,omitempty"`
+	Hash            string   `json:"hash"`
+	TargetFiles     []string `json:"target_files"`
+	ContentLayout   string   `json:"content_layout"`
+	SavePath        string   `json:"save_path"`
+	MappedFiles     []string `json:"mapped_files,omitempty"`
+	Category        string   `json:"category,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	Paused          bool     `json:"paused"`
+	Warnings        []string `json:"warnings,omitempty"`
+}
+
+type Plan struct {
+	Items         []PlanItem `json:"items"`
+	NewCategories []string   `json:"new_categories,omitempty"`
+}
+
+func (transfer *TransferStructure) GetPlanItem(key string, hash string) PlanItem {
+	item := PlanItem{
+		Key:           key,
+		Hash:          hash,
+		TargetFiles:   transfer.GetOutputPaths(hash),
+		ContentLayout: transfer.Fastresume.QBtContentLayout,
+		SavePath:      transfer.Fastresume.QbtSavePath,
+		MappedFiles:   transfer.Fastresume.MappedFiles,
+		Category:      transfer.Fastresume.QBtCategory,
+		Tags:          transfer.Fastresume.QbtTags,
+		Paused:        transfer.Fastresume.Paused == 1,
+		Warnings:      transfer.Warnings,
+	}
+	if !transfer.Magnet {
+		item.TorrentFilePath = transfer.TorrentFilePath
+	}
+	return item
+}
+
+// Sort make plan independent of processing order
+func (plan *Plan) Sort() {
+	sort.Slice(plan.Items, func(i, j int) bool {
+		return plan.Items[i].Key < plan.Items[j].Key
+	})
+	sort.Strings(plan.NewCategories)
+}
+
+func (plan *Plan) Print(w io.Writer) {
+	fmt.Fprintf(w, "\nMigration plan (%v torrents):\n", len(plan.Items))
+	for _, item := range plan.Items {
+		fmt.Fprintf(w, "\n%v\n", item.Key)
+		if item.TorrentFilePath != "" {
+			fmt.Fprintf(w, "\tTorrent file: %v\n", item.TorrentFilePath)
+		}
+		fmt.Fprintf(w, "\tHash: %v\n", item.Hash)
+		fmt.Fprintf(w, "\tTarget files: %v\n", strings.Join(item.TargetFiles, ", "))
+		fmt.Fprintf(w, "\tContent layout: %v\n", item.ContentLayout)
+		fmt.Fprintf(w, "\tSave path: %v\n", item.SavePath)
+		for _, mappedFile := range item.MappedFiles {
+			fmt.Fprintf(w, "\tMapped file: %v\n", mappedFile)
+		}
+		if item.Category != "" {
+			fmt.Fprintf(w, "\tCategory: %v\n", item.Category)
+		}
+		if len(item.Tags) != 0 {
+			fmt.Fprintf(w, "\tTags: %v\n", strings.Join(item.Tags, ", "))
+		}
+		fmt.Fprintf(w, "\tPaused: %v\n", item.Paused)
+		for _, warning := range item.Warnings {
+			fmt.Fprintf(w, "\tWarning: %v\n", warning)
+		}
+	}
+	if len(plan.NewCategories) != 0 {
+		fmt.Fprintf(w, "\nNew categories: %v\n", strings.Join(plan.NewCategories, ", "))
+	}
+}
+
+func (plan *Plan) Save(path string) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
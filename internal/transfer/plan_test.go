@@ -0,0 +1,91 @@
+package transfer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/internal/sources"
+)
+
+func TestHandleTorrentDryRun(t *testing.T) {
+	qbtDir := t.TempDir()
+	opts := &options.Opts{
+		BitDir:        "../../test/data",
+		QBitDir:       qbtDir,
+		PathSeparator: `/`,
+		DryRun:        true,
+		Replaces:      []string{"D:/films,/mnt/films"},
+	}
+	transferStruct := CreateEmptyNewTransferStructure()
+	transferStruct.Opts = opts
+	transferStruct.Replace = CreateReplaces(opts.Replaces)
+	transferStruct.Torrent = &sources.Torrent{
+		Path: "D:/films/testfileset",
+		Tags: []string{"label"},
+	}
+	chans := Channels{
+		ResultChannel:  make(chan *Result, 1),
+		PlanChannel:    make(chan PlanItem, 1),
+		BoundedChannel: make(chan bool, 1),
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	chans.BoundedChannel <- true
+	if err := HandleTorrent("testfileset.torrent", &transferStruct, &chans, &wg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result := <-chans.ResultChannel; result.Status != StatusPlanned || result.Hash == "" {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+	if len(chans.PlanChannel) != 1 {
+		t.Fatalf("Plan item wasn't sent")
+	}
+	item := <-chans.PlanChannel
+	if item.SavePath != "/mnt/films/testfileset" || item.TorrentFilePath != "../../test/data/testfileset.torrent" || !item.Paused {
+		t.Fatalf("Unexpected plan item: %+v", item)
+	}
+	if len(item.TargetFiles) != 2 || filepath.Dir(item.TargetFiles[0]) != qbtDir {
+		t.Fatalf("Unexpected target files: %v", item.TargetFiles)
+	}
+	entries, err := os.ReadDir(qbtDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Dry run mustn't write files, but found %v", entries)
+	}
+}
+
+func TestPlanSave(t *testing.T) {
+	plan := &Plan{
+		Items: []PlanItem{
+			{Key: "b.torrent", Hash: "bb", TargetFiles: []string{"bb.fastresume"}},
+			{Key: "a.torrent", Hash: "aa", TargetFiles: []string{"aa.fastresume"}, Paused: true},
+		},
+		NewCategories: []string{"z", "a"},
+	}
+	plan.Sort()
+	if plan.Items[0].Key != "a.torrent" || plan.NewCategories[0] != "a" {
+		t.Fatalf("Plan isn't sorted: %+v", plan)
+	}
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := plan.Save(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	saved := &Plan{}
+	if err = json.Unmarshal(data, saved); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(saved, plan) {
+		t.Fatalf("Unexpected saved plan:\n Got: %+v\n Expect %+v\n", saved, plan)
+	}
+}
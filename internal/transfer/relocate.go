@@ -0,0 +1,177 @@
+package transfer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/internal/replace"
+	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
+	"github.com/zeebo/bencode"
+)
+
+/*
+RelocateFastresume apply replace patterns and path separator to save paths and absolute mapped files of qBittorrent
+fastresume. Fastresume is handled as raw dictionary, so keys that QBittorrentFastresume doesn't know are kept as is.
+It returns false if nothing was changed
+*/
+func RelocateFastresume(data []byte, replaces []*replace.Replace, separator string) ([]byte, bool, error) {
+	fastresume := map[string]bencode.RawMessage{}
+	if err := bencode.DecodeBytes(data, &fastresume); err != nil {
+		return nil, false, err
+	}
+	var changed bool
+	// qBittorrent keeps own paths with / separator, libtorrent save path has separator of system
+	pathSeparators := map[string]string{
+		"save_path":        separator,
+		"qBt-savePath":     "/",
+		"qBt-downloadPath": "/",
+	}
+	for key, pathSeparator := range pathSeparators {
+		raw, ok := fastresume[key]
+		if !ok {
+			continue
+		}
+		var path string
+		if err := bencode.DecodeBytes(raw, &path); err != nil {
+			return nil, false, fmt.Errorf("can't decode %v: %v", key, err)
+		}
+		if path == "" {
+			continue
+		}
+		newPath := ReplacePaths(fileHelpers.Normalize(path, "/"), nil, replaces)
+		newPath = fileHelpers.Normalize(newPath, pathSeparator)
+		// paths of Original content layout end with separator
+		if last := path[len(path)-1]; fileHelpers.IsPathSeparator(last) && !strings.HasSuffix(newPath, pathSeparator) {
+			newPath += pathSeparator
+		}
+		if newPath != path {
+			raw, err := bencode.EncodeBytes(newPath)
+			if err != nil {
+				return nil, false, err
+			}
+			fastresume[key] = raw
+			changed = true
+		}
+	}
+
+	if raw, ok := fastresume["mapped_files"]; ok {
+		var mappedFiles []string
+		if err := bencode.DecodeBytes(raw, &mappedFiles); err != nil {
+			return nil, false, fmt.Errorf("can't decode mapped_files: %v", err)
+		}
+		newMappedFiles := make([]string, len(mappedFiles))
+		for index, mappedFile := range mappedFiles {
+			if mappedFile != "" {
+				newMappedFiles[index] = fileHelpers.Normalize(mappedFile, "/")
+			}
+		}
+		ReplacePaths("", newMappedFiles, replaces)
+		var mappedChanged bool
+		for index, mappedFile := range newMappedFiles {
+			if mappedFile != "" {
+				newMappedFiles[index] = fileHelpers.Normalize(mappedFile, separator)
+			}
+			if newMappedFiles[index] != mappedFiles[index] {
+				mappedChanged = true
+			}
+		}
+		if mappedChanged {
+			raw, err := bencode.EncodeBytes(newMappedFiles)
+			if err != nil {
+				return nil, false, err
+			}
+			fastresume["mapped_files"] = raw
+			changed = true
+		}
+	}
+
+	if !changed {
+		return data, false, nil
+	}
+	newData, err := bencode.EncodeBytes(fastresume)
+	if err != nil {
+		return nil, false, err
+	}
+	return newData, true, nil
+}
+
+// HandleRelocation rewrite save paths of all fastresume files in qBittorrent directory. Torrent files aren't changed
+func HandleRelocation(opts *options.Opts) bool {
+	fastresumePaths, err := filepath.Glob(filepath.Join(opts.QBitDir, "*.fastresume"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't list fastresume files with error:\n%v\n", err)
+		return true
+	}
+	sort.Strings(fastresumePaths)
+
+	replaces := CreateReplaces(opts.Replaces)
+	writer := NewOutputWriter(string(ConflictOverwrite))
+	if !opts.DryRun {
+		journal, err := OpenJournal(opts.QBitDir, JournalModeRelocate, opts.QBitDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't open journal with error:\n%v\n", err)
+			return true
+		}
+		writer.Journal = journal
+	}
+
+	var results []*Result
+	for num, fastresumePath := range fastresumePaths {
+		startTime := time.Now()
+		result := &Result{
+			Key:         filepath.Base(fastresumePath),
+			Hash:        strings.TrimSuffix(filepath.Base(fastresumePath), ".fastresume"),
+			Status:      StatusRelocated,
+			OutputPaths: []string{fastresumePath},
+		}
+		if writer.Journal != nil && writer.Journal.IsCompleted(result.Key) {
+			result.Status = StatusDone
+		} else if data, err := os.ReadFile(fastresumePath); err != nil {
+			result.Fail(ErrorFastresumeParse, fmt.Sprintf("Can't read fastresume file %v with error %v", fastresumePath, err))
+		} else if newData, changed, err := RelocateFastresume(data, replaces, opts.PathSeparator); err != nil {
+			result.Fail(ErrorFastresumeParse, fmt.Sprintf("Can't decode fastresume file %v with error %v", fastresumePath, err))
+		} else if !changed {
+			result.Status = StatusUnchanged
+		} else if opts.DryRun {
+			result.Status = StatusPlanned
+		} else if _, err = writer.write(fastresumePath, newData, nil); err != nil {
+			result.Fail(ErrorWrite, fmt.Sprintf("Can't write fastresume file %v with error %v", fastresumePath, err))
+		}
+		result.Elapsed = time.Since(startTime).Seconds()
+		fmt.Fprintf(os.Stderr, "%v/%v %v \n", num+1, len(fastresumePaths), result)
+		if writer.Journal != nil && (result.Status == StatusRelocated || result.Status == StatusUnchanged) {
+			if err := writer.Journal.Complete(result.Key, ""); err != nil {
+				fmt.Fprintf(os.Stderr, "Can't write journal with error:\n%v\n", err)
+			}
+		}
+		results = append(results, result)
+	}
+
+	summary := NewSummary(results)
+	wasErrors := summary.ByStatus[StatusFailed] != 0
+	if opts.Report != "" {
+		if err := WriteReport(opts.Report, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't write report with error:\n%v\n", err)
+			wasErrors = true
+		}
+	}
+	if writer.Journal != nil {
+		if err := writer.Journal.Finish(); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't finish journal with error:\n%v\n", err)
+			wasErrors = true
+		}
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, summary)
+	log.Println("Ended")
+	if wasErrors {
+		log.Println("Not all fastresume files was relocated")
+	}
+	return wasErrors
+}
@@ -0,0 +1,160 @@
+package transfer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/zeebo/bencode"
+)
+
+func TestRelocateFastresume(t *testing.T) {
+	type RelocateCase struct {
+		name      string
+		replaces  []string
+		separator string
+		input     map[string]interface{}
+		expected  map[string]interface{}
+		changed   bool
+	}
+	cases := []RelocateCase{
+		{
+			name:      "001 windows to linux with unknown keys",
+			replaces:  []string{"D:/films,/mnt/films"},
+			separator: "/",
+			input: map[string]interface{}{
+				"save_path":        `D:\films\`,
+				"qBt-savePath":     "D:/films/",
+				"qBt-downloadPath": "D:/films/incomplete",
+				"mapped_files":     []string{`testdir\dir1\testfile1.txt`, `D:\films\other\testfile2.txt`},
+				"qBt-unknownKey":   "kept",
+				"info-hash":        "\x00\xff",
+			},
+			expected: map[string]interface{}{
+				"save_path":        "/mnt/films/",
+				"qBt-savePath":     "/mnt/films/",
+				"qBt-downloadPath": "/mnt/films/incomplete",
+				"mapped_files":     []interface{}{"testdir/dir1/testfile1.txt", "/mnt/films/other/testfile2.txt"},
+				"qBt-unknownKey":   "kept",
+				"info-hash":        "\x00\xff",
+			},
+			changed: true,
+		},
+		{
+			name:      "002 new disk on linux",
+			replaces:  []string{"/mnt/old,/mnt/new"},
+			separator: "/",
+			input: map[string]interface{}{
+				"save_path":    "/mnt/old/music",
+				"mapped_files": []string{"", "/mnt/old/music/renamed.mp3"},
+			},
+			expected: map[string]interface{}{
+				"save_path":    "/mnt/new/music",
+				"mapped_files": []interface{}{"", "/mnt/new/music/renamed.mp3"},
+			},
+			changed: true,
+		},
+		{
+			name:      "003 unchanged",
+			replaces:  []string{"/mnt/old,/mnt/new"},
+			separator: "/",
+			input: map[string]interface{}{
+				"save_path":    "/mnt/films/",
+				"qBt-savePath": "/mnt/films/",
+			},
+			expected: map[string]interface{}{
+				"save_path":    "/mnt/films/",
+				"qBt-savePath": "/mnt/films/",
+			},
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			data, err := bencode.EncodeBytes(testCase.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newData, changed, err := RelocateFastresume(data, CreateReplaces(testCase.replaces), testCase.separator)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if changed != testCase.changed {
+				t.Fatalf("Unexpected changed flag %v", changed)
+			}
+			fastresume := map[string]interface{}{}
+			if err = bencode.DecodeBytes(newData, &fastresume); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(fastresume, testCase.expected) {
+				t.Fatalf("Unexpected fastresume:\n Got: %#v\n Expect %#v\n", fastresume, testCase.expected)
+			}
+		})
+	}
+
+	if _, _, err := RelocateFastresume([]byte("broken"), nil, "/"); err == nil {
+		t.Fatalf("Expected error for broken fastresume")
+	}
+}
+
+func TestHandleRelocation(t *testing.T) {
+	dir := t.TempDir()
+	fastresumePath := filepath.Join(dir, "3456bac107634970b022677c6bfaa584065e0917.fastresume")
+	torrentPath := filepath.Join(dir, "3456bac107634970b022677c6bfaa584065e0917.torrent")
+	original, err := bencode.EncodeBytes(map[string]interface{}{
+		"save_path":    `D:\films\`,
+		"qBt-savePath": "D:/films/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, data := range map[string][]byte{fastresumePath: original, torrentPath: []byte("torrent")} {
+		if err = os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := &options.Opts{
+		QBitDir:       dir,
+		Replaces:      []string{"D:/films,/mnt/films"},
+		PathSeparator: "/",
+		DryRun:        true,
+	}
+
+	if wasErrors := HandleRelocation(opts); wasErrors {
+		t.Fatalf("Unexpected errors")
+	}
+	if data, _ := os.ReadFile(fastresumePath); !reflect.DeepEqual(data, original) {
+		t.Fatalf("Dry run changed fastresume file")
+	}
+
+	opts.DryRun = false
+	if wasErrors := HandleRelocation(opts); wasErrors {
+		t.Fatalf("Unexpected errors")
+	}
+	fastresume := map[string]interface{}{}
+	if err = bencode.DecodeBytes(mustReadFile(t, fastresumePath), &fastresume); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fastresume["save_path"] != "/mnt/films/" || fastresume["qBt-savePath"] != "/mnt/films/" {
+		t.Fatalf("Unexpected relocated fastresume %v", fastresume)
+	}
+	if data := mustReadFile(t, torrentPath); string(data) != "torrent" {
+		t.Fatalf("Torrent file was changed")
+	}
+
+	if _, err = Undo(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data := mustReadFile(t, fastresumePath); !reflect.DeepEqual(data, original) {
+		t.Fatalf("Relocation wasn't undone")
+	}
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,56 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Report struct {
+	Summary *Summary  `json:"summary"`
+	Results []*Result `json:"results"`
+}
+
+// WriteReport save results to json or csv file, format depends on file extension
+func WriteReport(path string, results []*Result) error {
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		data, err = json.MarshalIndent(&Report{Summary: NewSummary(results), Results: results}, "", "  ")
+	case ".csv":
+		data, err = encodeCSVReport(results)
+	default:
+		return fmt.Errorf("unknown report format %v", filepath.Ext(path))
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func encodeCSVReport(results []*Result) ([]byte, error) {
+	var builder strings.Builder
+	w := csv.NewWriter(&builder)
+	records := [][]string{{"key", "hash", "status", "error_class", "error", "output_paths", "warnings", "elapsed"}}
+	for _, result := range results {
+		records = append(records, []string{
+			result.Key,
+			result.Hash,
+			string(result.Status),
+			string(result.ErrorClass),
+			result.Error,
+			strings.Join(result.OutputPaths, ";"),
+			strings.Join(result.Warnings, ";"),
+			strconv.FormatFloat(result.Elapsed, 'f', 3, 64),
+		})
+	}
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return []byte(builder.String()), nil
+}
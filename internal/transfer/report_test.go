@@ -0,0 +1,78 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testResults() []*Result {
+	return []*Result{
+		{Key: "b.torrent", Hash: "bb", Status: StatusImported, OutputPaths: []string{"bb.fastresume", "bb.torrent"}, Elapsed: 0.5},
+		{Key: "a.torrent", Status: StatusFailed, ErrorClass: ErrorTorrentFind, Error: "can't locate torrent file a.torrent"},
+		{Key: "c.torrent", Hash: "cc", Status: StatusImported, Warnings: []string{"torrent name c was normalized to c_"}},
+	}
+}
+
+func TestNewSummary(t *testing.T) {
+	summary := NewSummary(testResults())
+	expected := &Summary{
+		Total:        3,
+		ByStatus:     map[Status]int{StatusImported: 2, StatusFailed: 1},
+		ByErrorClass: map[ErrorClass]int{ErrorTorrentFind: 1},
+		WithWarnings: 1,
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Fatalf("Unexpected summary:\n Got: %+v\n Expect %+v\n", summary, expected)
+	}
+	if str := summary.String(); str != "Total: 3 (imported: 2, failed: 1, with warnings: 1, torrent_not_found: 1)" {
+		t.Fatalf("Unexpected summary string: %v", str)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	results := testResults()
+	SortResults(results)
+	dir := t.TempDir()
+
+	jsonPath := filepath.Join(dir, "report.json")
+	if err := WriteReport(jsonPath, results); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	report := &Report{}
+	if err = json.Unmarshal(data, report); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(report.Results, results) || report.Summary.Total != 3 {
+		t.Fatalf("Unexpected report:\n Got: %+v\n Expect %+v\n", report.Results, results)
+	}
+
+	csvPath := filepath.Join(dir, "report.csv")
+	if err = WriteReport(csvPath, results); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	file, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"b.torrent", "bb", "imported", "", "", "bb.fastresume;bb.torrent", "", "0.500"}
+	if len(records) != 4 || !reflect.DeepEqual(records[2], expected) {
+		t.Fatalf("Unexpected csv records:\n Got: %v\n Expect %v\n", records, expected)
+	}
+
+	if err = WriteReport(filepath.Join(dir, "report.txt"), results); err == nil {
+		t.Fatalf("Test must fail, but it's not")
+	}
+}
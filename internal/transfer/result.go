@@ -0,0 +1,129 @@
+package transfer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Status string
+
+const (
+	StatusImported Status = "imported"
+	StatusPlanned  Status = "planned"
+	StatusSkipped  Status = "skipped"
+	StatusDone     Status = "done_before" // processed by interrupted run
+	StatusFailed   Status = "failed"
+	// statuses of relocation of existing fastresume files
+	StatusRelocated Status = "relocated"
+	StatusUnchanged Status = "unchanged"
+)
+
+// ErrorClass group errors by reason, so it's easy to find all torrents with the same problem
+type ErrorClass string
+
+const (
+	ErrorMagnet          ErrorClass = "magnet"
+	ErrorTorrentFind     ErrorClass = "torrent_not_found"
+	ErrorTorrentParse    ErrorClass = "torrent_decode"
+	ErrorPartFile        ErrorClass = "part_file"
+	ErrorVerify          ErrorClass = "verify"
+	ErrorWrite           ErrorClass = "write"
+	ErrorPanic           ErrorClass = "panic"
+	ErrorFastresumeParse ErrorClass = "fastresume_decode"
+)
+
+// Result of processing of one torrent
+type Result struct {
+	Key         string     `json:"key"`
+	Hash        string     `json:"hash,omitempty"`
+	Status      Status     `json:"status"`
+	ErrorClass  ErrorClass `json:"error_class,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	OutputPaths []string   `json:"output_paths,omitempty"`
+	Warnings    []string   `json:"warnings,omitempty"`
+	Elapsed     float64    `json:"elapsed"` // seconds
+}
+
+func (result *Result) Fail(class ErrorClass, message string) {
+	result.Status = StatusFailed
+	result.ErrorClass = class
+	result.Error = message
+}
+
+// String return human-readable message for console
+func (result *Result) String() string {
+	switch result.Status {
+	case StatusFailed:
+		return result.Error
+	case StatusPlanned:
+		return fmt.Sprintf("Planned %v", result.Key)
+	case StatusDone:
+		return fmt.Sprintf("Already imported %v by interrupted run", result.Key)
+	case StatusSkipped:
+		if len(result.Warnings) != 0 {
+			return fmt.Sprintf("Skipped %v: %v", result.Key, strings.Join(result.Warnings, "; "))
+		}
+		return fmt.Sprintf("Skipped %v, because fastresume file already exists", result.Key)
+	case StatusRelocated:
+		return fmt.Sprintf("Relocated %v", result.Key)
+	case StatusUnchanged:
+		return fmt.Sprintf("Paths of %v weren't changed", result.Key)
+	}
+	if len(result.Warnings) != 0 {
+		return fmt.Sprintf("Sucessfully imported %v with warnings: %v", result.Key, strings.Join(result.Warnings, "; "))
+	}
+	return fmt.Sprintf("Sucessfully imported %v", result.Key)
+}
+
+// Summary counts of results by outcome
+type Summary struct {
+	Total        int                `json:"total"`
+	ByStatus     map[Status]int     `json:"by_status"`
+	ByErrorClass map[ErrorClass]int `json:"by_error_class,omitempty"`
+	WithWarnings int                `json:"with_warnings"`
+}
+
+func NewSummary(results []*Result) *Summary {
+	summary := &Summary{
+		Total:        len(results),
+		ByStatus:     map[Status]int{},
+		ByErrorClass: map[ErrorClass]int{},
+	}
+	for _, result := range results {
+		summary.ByStatus[result.Status]++
+		if result.ErrorClass != "" {
+			summary.ByErrorClass[result.ErrorClass]++
+		}
+		if len(result.Warnings) != 0 {
+			summary.WithWarnings++
+		}
+	}
+	return summary
+}
+
+func (summary *Summary) String() string {
+	var parts []string
+	for _, status := range []Status{StatusImported, StatusRelocated, StatusUnchanged, StatusPlanned, StatusSkipped, StatusDone, StatusFailed} {
+		if count, ok := summary.ByStatus[status]; ok {
+			parts = append(parts, fmt.Sprintf("%v: %v", status, count))
+		}
+	}
+	parts = append(parts, fmt.Sprintf("with warnings: %v", summary.WithWarnings))
+	var classes []string
+	for class := range summary.ByErrorClass {
+		classes = append(classes, string(class))
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		parts = append(parts, fmt.Sprintf("%v: %v", class, summary.ByErrorClass[ErrorClass(class)]))
+	}
+	return fmt.Sprintf("Total: %v (%v)", summary.Total, strings.Join(parts, ", "))
+}
+
+// SortResults make order of results independent of processing order
+func SortResults(results []*Result) {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Key < results[j].Key
+	})
+}
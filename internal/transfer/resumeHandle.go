@@ -3,20 +3,25 @@ package transfer
 import (
 	"fmt"
 	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/internal/sources"
 	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
 	"github.com/rumanzo/bt2qbt/pkg/helpers"
+	"github.com/rumanzo/bt2qbt/pkg/magnetLinks"
 	"github.com/rumanzo/bt2qbt/pkg/torrentStructures"
-	"github.com/rumanzo/bt2qbt/pkg/utorrentStructs"
+	"github.com/rumanzo/bt2qbt/pkg/verification"
 	"log"
 	"os"
-	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
-func HandleResumeItem(key string, transferStruct *TransferStructure, chans *Channels, wg *sync.WaitGroup) error {
+func HandleTorrent(key string, transferStruct *TransferStructure, chans *Channels, wg *sync.WaitGroup) error {
+	result := &Result{Key: key, Status: StatusImported}
+	startTime := time.Now()
 
 	//panic recover
 	defer wg.Done()
@@ -25,122 +30,248 @@ func HandleResumeItem(key string, transferStruct *TransferStructure, chans *Chan
 	}()
 	defer func() {
 		if r := recover(); r != nil {
-			chans.ErrChannel <- fmt.Sprintf(
+			result.Fail(ErrorPanic, fmt.Sprintf(
 				"Panic while processing torrent %v:\n======\nReason: %v.\nText panic:\n%v\n======",
-				key, r, string(debug.Stack()))
+				key, r, string(debug.Stack())))
 		}
+		result.Warnings = transferStruct.Warnings
+		result.Elapsed = time.Since(startTime).Seconds()
+		chans.ResultChannel <- result
 	}()
 
 	var err error
 
-	HandleTorrentFilePath(transferStruct, key)
-
-	err = FindTorrentFile(transferStruct)
-	if err != nil {
-		chans.ErrChannel <- err.Error()
-		return err
-	}
-
-	// struct for work with
-	err = helpers.DecodeTorrentFile(transferStruct.TorrentFilePath, transferStruct.TorrentFile)
-	if err != nil {
-		chans.ErrChannel <- fmt.Sprintf("Can't decode torrent file %v for torrent %v with error %v", transferStruct.TorrentFilePath, key, err)
-		return err
-	}
-
-	// because hash of info very important it will be better to use interface for get hash
-	if !strings.HasPrefix(key, "magnet:?") {
-		err = helpers.DecodeTorrentFile(transferStruct.TorrentFilePath, &transferStruct.TorrentFileRaw)
+	if strings.HasPrefix(key, "magnet:?") {
+		// magnet links don't have torrent file, qBittorrent will fetch metadata itself
+		transferStruct.Magnet = true
+		transferStruct.MagnetLink, err = magnetLinks.Parse(key)
 		if err != nil {
-			chans.ErrChannel <- fmt.Sprintf("Can't decode torrent file %v for torrent %v with error %v", transferStruct.TorrentFilePath, key, err)
+			result.Fail(ErrorMagnet, fmt.Sprintf("Can't parse magnet link %v with error %v", key, err))
 			return err
 		}
-	} else {
-		transferStruct.Magnet = true
 		transferStruct.TorrentFile = &torrentStructures.Torrent{
 			Info: &torrentStructures.TorrentInfo{},
 		}
+	} else {
+		HandleTorrentFilePath(transferStruct, key)
+
+		err = FindTorrentFile(transferStruct)
+		if err != nil {
+			result.Fail(ErrorTorrentFind, err.Error())
+			return err
+		}
+
+		err = transferStruct.LoadTorrentFile()
+		if err != nil {
+			result.Fail(ErrorTorrentParse, fmt.Sprintf("Can't decode torrent file %v for torrent %v with error %v", transferStruct.TorrentFilePath, key, err))
+			return err
+		}
 	}
 
 	transferStruct.HandleStructures()
 
-	newBaseName := transferStruct.GetHash()
-	if err = helpers.EncodeTorrentFile(filepath.Join(transferStruct.Opts.QBitDir, newBaseName+".fastresume"), transferStruct.Fastresume); err != nil {
-		chans.ErrChannel <- fmt.Sprintf("Can't create qBittorrent fastresume file %v. With error: %v", filepath.Join(transferStruct.Opts.QBitDir, newBaseName+".fastresume"), err)
+	if err = transferStruct.HandlePartFile(); err != nil {
+		result.Fail(ErrorPartFile, fmt.Sprintf("Can't handle uTorrent part file for torrent %v with error %v", key, err))
+		return err
+	}
+
+	if err = transferStruct.HandleVerify(); err != nil {
+		result.Fail(ErrorVerify, fmt.Sprintf("Can't verify pieces of torrent %v with error %v", key, err))
 		return err
 	}
-	if err = helpers.CopyFile(transferStruct.TorrentFilePath, filepath.Join(transferStruct.Opts.QBitDir, newBaseName+".torrent")); err != nil {
-		chans.ErrChannel <- fmt.Sprintf("Can't create qBittorrent torrent file %v", filepath.Join(transferStruct.Opts.QBitDir, newBaseName+".torrent"))
+
+	newBaseName := transferStruct.GetHash()
+	result.Hash = newBaseName
+	result.OutputPaths = transferStruct.GetOutputPaths(newBaseName)
+	if transferStruct.Opts.DryRun {
+		result.Status = StatusPlanned
+		chans.PlanChannel <- transferStruct.GetPlanItem(key, newBaseName)
+		return nil
+	}
+	written, err := transferStruct.Sink.Write(transferStruct, newBaseName, key)
+	if err != nil {
+		result.Fail(ErrorWrite, fmt.Sprintf("Can't write torrent %v to qBittorrent with error: %v", key, err))
 		return err
 	}
-	chans.ComChannel <- fmt.Sprintf("Sucessfully imported %v", key)
+	if !written {
+		// torrent already exists in qBittorrent
+		result.Status = StatusSkipped
+		result.OutputPaths = nil
+	}
 	return nil
 }
 
-func HandleResumeItems(opts *options.Opts, resumeItems map[string]*utorrentStructs.ResumeItem) {
-	totalJobs := len(resumeItems)
-	chans := Channels{ComChannel: make(chan string, totalJobs),
-		ErrChannel:     make(chan string, totalJobs),
+// HandleTorrents process all torrents of source and report if some of them or labels weren't processed
+func HandleTorrents(opts *options.Opts, torrents map[string]*sources.Torrent) bool {
+	var sink Sink
+	if !opts.DryRun {
+		var err error
+		if sink, err = NewSink(opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't prepare qBittorrent storage with error:\n%v\n", err)
+			return true
+		}
+	}
+	return TransferTorrents(opts, torrents, sink)
+}
+
+// TransferTorrents process torrents of any source and persist them with sink. Sink is nil on dry run
+func TransferTorrents(opts *options.Opts, torrents map[string]*sources.Torrent, sink Sink) bool {
+	totalJobs := len(torrents)
+	chans := Channels{ResultChannel: make(chan *Result, totalJobs),
+		PlanChannel:    make(chan PlanItem, totalJobs),
 		BoundedChannel: make(chan bool, runtime.GOMAXPROCS(0)*2)}
 	numJob := 1
 	var newTags []string
 	var wg sync.WaitGroup
 
 	positionNum := 0
+	// positions of not completed torrents in download queue
+	queue := map[string]int64{}
 
 	replaces := CreateReplaces(opts.Replaces)
+	var journal *Journal
+	if sink != nil {
+		journal = sink.Journal()
+	}
+
+	// verifier is shared between all torrents, so it bounds parallel reads for whole migration
+	var verifier *verification.Verifier
+	if opts.Verify {
+		verifier = verification.NewVerifier(opts.VerifyThreads)
+	}
 
-	for key, resumeItem := range resumeItems {
+	for key, torrent := range torrents {
 		positionNum++
 		if opts.WithoutTags == false {
-			if resumeItem.Labels != nil {
-				for _, label := range resumeItem.Labels {
+			if torrent.Tags != nil {
+				for _, label := range torrent.Tags {
 					if exists, tag := helpers.CheckExists(label, newTags); !exists {
 						newTags = append(newTags, tag)
 					}
 				}
 			}
 		}
+		if torrent.QueuePosition != nil && torrent.CompletedOn == 0 {
+			queue[helpers.HandleCesu8(key)] = *torrent.QueuePosition
+		}
+		if journal != nil && journal.IsCompleted(helpers.HandleCesu8(key)) {
+			// hash is kept to restore position of torrent in queue
+			chans.ResultChannel <- &Result{Key: helpers.HandleCesu8(key), Hash: journal.GetCompletedHash(helpers.HandleCesu8(key)), Status: StatusDone}
+			continue
+		}
 		wg.Add(1)
 		chans.BoundedChannel <- true
 		transferStruct := CreateEmptyNewTransferStructure()
-		transferStruct.ResumeItem = resumeItem
+		transferStruct.Torrent = torrent
+		transferStruct.Warnings = append(transferStruct.Warnings, torrent.Warnings...)
 		transferStruct.Replace = replaces
 		transferStruct.Opts = opts
-		go HandleResumeItem(helpers.HandleCesu8(key), &transferStruct, &chans, &wg)
+		transferStruct.Verifier = verifier
+		transferStruct.Sink = sink
+		go HandleTorrent(helpers.HandleCesu8(key), &transferStruct, &chans, &wg)
 	}
 	go func() {
 		wg.Wait()
-		close(chans.ComChannel)
-		close(chans.ErrChannel)
+		close(chans.ResultChannel)
+		close(chans.PlanChannel)
 	}()
-	for message := range chans.ComChannel {
-		fmt.Printf("%v/%v %v \n", numJob, totalJobs, message)
+	var results []*Result
+	for result := range chans.ResultChannel {
+		fmt.Fprintf(os.Stderr, "%v/%v %v \n", numJob, totalJobs, result)
+		results = append(results, result)
 		numJob++
+		if journal != nil && (result.Status == StatusImported || result.Status == StatusSkipped) {
+			var hash string
+			if result.Status == StatusImported {
+				hash = result.Hash
+			}
+			if err := journal.Complete(result.Key, hash); err != nil {
+				fmt.Fprintf(os.Stderr, "Can't write journal with error:\n%v\n", err)
+			}
+		}
 	}
-	var wasErrors bool
-	for message := range chans.ErrChannel {
-		fmt.Printf("%v/%v %v \n", numJob, totalJobs, message)
-		wasErrors = true
-		numJob++
+	SortResults(results)
+	summary := NewSummary(results)
+	wasErrors := summary.ByStatus[StatusFailed] != 0
+	if opts.DryRun {
+		plan := &Plan{}
+		for item := range chans.PlanChannel {
+			plan.Items = append(plan.Items, item)
+		}
+		if opts.WithoutTags == false {
+			var err error
+			plan.NewCategories, err = PlanLabels(opts, newTags)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Can't handle labels with error:\n%v\n", err)
+			}
+		}
+		plan.Sort()
+		plan.Print(os.Stdout)
+		if opts.Plan != "" {
+			if err := plan.Save(opts.Plan); err != nil {
+				fmt.Fprintf(os.Stderr, "Can't save migration plan with error:\n%v\n", err)
+				wasErrors = true
+			}
+		}
+	} else {
+		if hashes := GetQueueHashes(results, queue); len(hashes) != 0 {
+			if err := sink.WriteQueue(hashes); err != nil {
+				fmt.Fprintf(os.Stderr, "Can't write queue with error:\n%v\n", err)
+				wasErrors = true
+			}
+		}
+		if opts.WithoutTags == false {
+			if err := sink.WriteCategories(newTags); err != nil {
+				fmt.Fprintf(os.Stderr, "Can't handle labels with error:\n%v\n", err)
+				wasErrors = true
+			}
+		}
 	}
-	if opts.WithoutTags == false {
-		err := ProcessLabels(opts, newTags)
-		if err != nil {
-			fmt.Printf("Can't handle labels with error:\n%v\n", err)
+	if opts.Report != "" {
+		if err := WriteReport(opts.Report, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't write report with error:\n%v\n", err)
+			wasErrors = true
 		}
 	}
-	fmt.Println()
+	if sink != nil {
+		if err := sink.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't finish writing with error:\n%v\n", err)
+			wasErrors = true
+		}
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, summary)
 	log.Println("Ended")
 	if wasErrors {
 		log.Println("Not all torrents was processed")
 	}
+	return wasErrors
+}
+
+// GetQueueHashes return hashes of imported torrents that have position in queue, sorted by it.
+// Torrents imported by interrupted run are queued too, because queue is written only at the end of run
+func GetQueueHashes(results []*Result, queue map[string]int64) []string {
+	var queued []*Result
+	for _, result := range results {
+		imported := result.Status == StatusImported || result.Status == StatusDone
+		if _, ok := queue[result.Key]; ok && imported && result.Hash != "" {
+			queued = append(queued, result)
+		}
+	}
+	sort.SliceStable(queued, func(i, j int) bool {
+		return queue[queued[i].Key] < queue[queued[j].Key]
+	})
+	hashes := make([]string, 0, len(queued))
+	for _, result := range queued {
+		hashes = append(hashes, result.Hash)
+	}
+	return hashes
 }
 
 // HandleTorrentFilePath check if resume key is absolute path. It means that we should search torrent file using this absolute path
 // notice that torrent file name always known
 func HandleTorrentFilePath(transferStructure *TransferStructure, key string) {
-	if fileHelpers.IsAbs(key) {
+	if isAbsPath(key) {
 		transferStructure.TorrentFilePath = fileHelpers.Normalize(key, `/`)
 		transferStructure.TorrentFileName = fileHelpers.Base(key)
 	} else {
@@ -156,6 +287,10 @@ func FindTorrentFile(transferStructure *TransferStructure) error {
 			// normalize path with os.PathSeparator, because file can be on share, for example
 			fullPath := fileHelpers.Join([]string{searchPath, transferStructure.TorrentFileName}, string(os.PathSeparator))
 			if _, err = os.Stat(fullPath); err == nil {
+				if fullPath != transferStructure.TorrentFilePath {
+					transferStructure.Warnings = append(transferStructure.Warnings,
+						fmt.Sprintf("torrent file is missing at %v, found at %v", transferStructure.TorrentFilePath, fullPath))
+				}
 				transferStructure.TorrentFilePath = fullPath
 				return nil
 			}
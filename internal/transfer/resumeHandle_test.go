@@ -146,6 +146,16 @@ func TestHandleTorrentFilePath(t *testing.T) {
 				Opts:            &options.Opts{BitDir: `C:\\temp`},
 			},
 		},
+		{
+			name:                 "008 Check absolute posix path",
+			key:                  "/home/user/watch/t.torrent",
+			newTransferStructure: &TransferStructure{Opts: &options.Opts{BitDir: "/home/user/.session"}},
+			expected: &TransferStructure{
+				TorrentFilePath: "/home/user/watch/t.torrent",
+				TorrentFileName: "t.torrent",
+				Opts:            &options.Opts{BitDir: "/home/user/.session"},
+			},
+		},
 	}
 
 	for _, testCase := range cases {
@@ -192,3 +202,18 @@ func TestEmojiResumeDecode(t *testing.T) {
 		}
 	}
 }
+
+func TestGetQueueHashes(t *testing.T) {
+	results := []*Result{
+		{Key: "1.torrent", Hash: "aa", Status: StatusImported},
+		{Key: "2.torrent", Hash: "bb", Status: StatusDone},
+		{Key: "3.torrent", Status: StatusDone},
+		{Key: "4.torrent", Hash: "dd", Status: StatusSkipped},
+		{Key: "5.torrent", Hash: "ee", Status: StatusImported},
+	}
+	queue := map[string]int64{"1.torrent": 2, "2.torrent": 0, "3.torrent": 1, "4.torrent": 3}
+	expected := []string{"bb", "aa"}
+	if hashes := GetQueueHashes(results, queue); !reflect.DeepEqual(hashes, expected) {
+		t.Fatalf("Unexpected queue:\n Got: %v\n Expect %v\n", hashes, expected)
+	}
+}
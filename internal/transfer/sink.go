@@ -0,0 +1,207 @@
+package transfer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+)
+
+// Sink persist processed torrents into storage of qBittorrent
+type Sink interface {
+	// Write persist torrent with hash as torrent id. Key is key of source torrent, it's magnet link for torrents
+	// without metadata. It returns false if torrent already exists and is kept as is
+	Write(transfer *TransferStructure, hash string, key string) (bool, error)
+	// WriteCategories add categories that qBittorrent doesn't have yet
+	WriteCategories(newCategories []string) error
+	// WriteQueue put written torrents to the end of download queue in the same order as hashes
+	WriteQueue(hashes []string) error
+	// Journal return journal of run or nil if changes can't be undone
+	Journal() *Journal
+	// Close finish run after all torrents are written
+	Close() error
+}
+
+// NewSink create sink that options point to: WebUI API, torrents.db or BT_backup directory
+func NewSink(opts *options.Opts) (Sink, error) {
+	if opts.WebUI != "" {
+		// qBittorrent writes files itself, so there is nothing to journal
+		return NewWebUIClient(opts.WebUI, opts.WebUIUsername, opts.WebUIPassword)
+	}
+
+	journal, err := OpenJournal(opts.GetQBitDataDir(), JournalModeImport, opts.BitDir)
+	if err != nil {
+		return nil, fmt.Errorf("can't open journal: %v", err)
+	}
+	if journal.IsInterrupted() {
+		log.Println("Continue interrupted run, already imported torrents will be skipped")
+	}
+	local := localFiles{Opts: opts, Writer: NewOutputWriter(opts.OnConflict)}
+	local.Writer.Journal = journal
+	if !opts.IsTorrentsDB() {
+		return &BTBackupSink{localFiles: local}, nil
+	}
+
+	// whole database is saved to journal, so undo restores it
+	var torrentsDB *TorrentsDB
+	if err = local.Writer.Record(opts.QBitDir); err == nil {
+		torrentsDB, err = OpenTorrentsDB(opts.QBitDir, opts.OnConflict)
+	}
+	if err != nil {
+		journal.Finish()
+		return nil, err
+	}
+	torrentsDB.localFiles = local
+	return torrentsDB, nil
+}
+
+// localFiles common part of sinks that write into qBittorrent data directory: categories.json, part files and journal
+type localFiles struct {
+	Opts   *options.Opts
+	Writer *OutputWriter
+}
+
+func (local *localFiles) WriteCategories(newCategories []string) error {
+	err := local.Writer.Record(local.Opts.Categories)
+	if err == nil {
+		err = local.Writer.Record(local.Opts.Categories + ".bak")
+	}
+	if err == nil {
+		err = ProcessLabels(local.Opts, newCategories)
+	}
+	return err
+}
+
+// QueueFileName file of BT_backup with hashes of queued torrents, one per line from the top of queue
+const QueueFileName = "queue"
+
+// WriteQueue put hashes to the end of queue file. Torrents that are already in queue are moved
+func (sink *BTBackupSink) WriteQueue(hashes []string) error {
+	queuePath := filepath.Join(sink.Opts.QBitDir, QueueFileName)
+	data, err := os.ReadFile(queuePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't read qBittorrent queue file %v: %v", queuePath, err)
+	}
+	added := map[string]bool{}
+	for _, hash := range hashes {
+		added[hash] = true
+	}
+	var queue []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !added[line] {
+			queue = append(queue, line)
+		}
+	}
+	queue = append(queue, hashes...)
+	if err = sink.Writer.Record(queuePath); err != nil {
+		return fmt.Errorf("can't record qBittorrent queue file %v to journal. With error: %v", queuePath, err)
+	}
+	if err = helpers.WriteFileAtomic(queuePath, []byte(strings.Join(queue, "\n")+"\n")); err != nil {
+		return fmt.Errorf("can't write qBittorrent queue file %v. With error: %v", queuePath, err)
+	}
+	return nil
+}
+
+func (local *localFiles) Journal() *Journal {
+	if local.Writer == nil {
+		return nil
+	}
+	return local.Writer.Journal
+}
+
+// writePartFile write libtorrent part file with pieces of not selected files from uTorrent part file
+func (local *localFiles) writePartFile(transfer *TransferStructure, hash string) error {
+	if transfer.PartFile == nil {
+		return nil
+	}
+	partFilePath := transfer.GetPartFilePath(hash)
+	written, err := local.Writer.WritePartFile(partFilePath, transfer.PartFile)
+	if err != nil {
+		return fmt.Errorf("can't create libtorrent part file %v. With error: %v", partFilePath, err)
+	}
+	if !written {
+		transfer.Warnings = append(transfer.Warnings, fmt.Sprintf("libtorrent part file %v already exists and was skipped", partFilePath))
+	}
+	return nil
+}
+
+func (local *localFiles) Close() error {
+	if journal := local.Journal(); journal != nil {
+		return journal.Finish()
+	}
+	return nil
+}
+
+// BTBackupSink write fastresume and torrent files into BT_backup directory
+type BTBackupSink struct {
+	localFiles
+}
+
+func (sink *BTBackupSink) Write(transfer *TransferStructure, hash string, _ string) (bool, error) {
+	written, err := sink.Writer.WriteFastresume(transfer.GetFastresumePath(hash), transfer.Fastresume)
+	if err != nil {
+		return false, fmt.Errorf("can't create qBittorrent fastresume file %v. With error: %v", transfer.GetFastresumePath(hash), err)
+	}
+	if !written {
+		// torrent already exists in qBittorrent, so other files are kept too
+		return false, nil
+	}
+	if !transfer.Magnet {
+		if _, err = sink.Writer.CopyTorrent(transfer.TorrentFilePath, transfer.GetTorrentPath(hash)); err != nil {
+			return true, fmt.Errorf("can't create qBittorrent torrent file %v. With error: %v", transfer.GetTorrentPath(hash), err)
+		}
+	}
+	return true, sink.writePartFile(transfer, hash)
+}
+
+// Write insert torrent row with torrent file as metadata
+func (torrentsDB *TorrentsDB) Write(transfer *TransferStructure, hash string, _ string) (bool, error) {
+	var metadata []byte
+	if !transfer.Magnet {
+		var err error
+		if metadata, err = os.ReadFile(transfer.TorrentFilePath); err != nil {
+			return false, fmt.Errorf("can't read torrent file %v: %v", transfer.TorrentFilePath, err)
+		}
+	}
+	written, err := torrentsDB.WriteTorrent(hash, transfer.Fastresume, metadata)
+	if err != nil {
+		return false, fmt.Errorf("can't write into qBittorrent database %v: %v", torrentsDB.Path, err)
+	}
+	if !written {
+		return false, nil
+	}
+	return true, torrentsDB.writePartFile(transfer, hash)
+}
+
+// Write add torrent through API. Magnet link is key of source torrent
+func (webUI *WebUIClient) Write(transfer *TransferStructure, hash string, key string) (bool, error) {
+	written, err := webUI.AddTorrent(transfer, hash, key)
+	if err != nil {
+		return written, fmt.Errorf("can't add torrent through qBittorrent WebUI: %v", err)
+	}
+	if written && transfer.PartFile != nil {
+		// save path may be on remote machine
+		transfer.Warnings = append(transfer.Warnings, "uTorrent part file wasn't converted, because torrent was added through WebUI")
+	}
+	return written, nil
+}
+
+func (webUI *WebUIClient) WriteCategories(newCategories []string) error {
+	return webUI.CreateCategories(newCategories)
+}
+
+func (webUI *WebUIClient) WriteQueue(hashes []string) error {
+	return webUI.QueueTorrents(hashes)
+}
+
+func (webUI *WebUIClient) Journal() *Journal {
+	return nil
+}
+
+func (webUI *WebUIClient) Close() error {
+	return nil
+}
@@ -0,0 +1,133 @@
+package transfer
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/internal/sources"
+)
+
+// memorySink keep torrents in memory, so engine can be checked without storage
+type memorySink struct {
+	mu         sync.Mutex
+	savePaths  map[string]string
+	categories []string
+	queue      []string
+	closed     bool
+}
+
+func (sink *memorySink) Write(transfer *TransferStructure, hash string, _ string) (bool, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if _, ok := sink.savePaths[hash]; ok {
+		return false, nil
+	}
+	sink.savePaths[hash] = transfer.Fastresume.QbtSavePath
+	return true, nil
+}
+
+func (sink *memorySink) WriteCategories(newCategories []string) error {
+	sink.categories = newCategories
+	return nil
+}
+
+func (sink *memorySink) WriteQueue(hashes []string) error {
+	sink.queue = hashes
+	return nil
+}
+
+func (sink *memorySink) Journal() *Journal {
+	return nil
+}
+
+func (sink *memorySink) Close() error {
+	sink.closed = true
+	return nil
+}
+
+func TestTransferTorrentsCustomSink(t *testing.T) {
+	opts := &options.Opts{
+		BitDir:        "../../test/data",
+		PathSeparator: `/`,
+		SearchPaths:   []string{"../../test/data"},
+	}
+	firstPosition, secondPosition := int64(0), int64(1)
+	torrents := map[string]*sources.Torrent{
+		"testfileset.torrent":         {Path: "/mnt/films/testdir", Tags: []string{"label"}, QueuePosition: &firstPosition},
+		"testfile1_single_v1.torrent": {Path: "/mnt/films/testfile1.txt", QueuePosition: &secondPosition},
+	}
+	sink := &memorySink{savePaths: map[string]string{"3456bac107634970b022677c6bfaa584065e0917": "/existing/"}}
+	if wasErrors := TransferTorrents(opts, torrents, sink); wasErrors {
+		t.Fatalf("Unexpected errors while processing")
+	}
+	if len(sink.savePaths) != 2 || sink.savePaths["3456bac107634970b022677c6bfaa584065e0917"] != "/existing/" {
+		t.Fatalf("Unexpected torrents in sink: %v", sink.savePaths)
+	}
+	if len(sink.categories) != 1 || sink.categories[0] != "label" || !sink.closed {
+		t.Fatalf("Unexpected state of sink: %+v", sink)
+	}
+	// skipped torrent keeps its place in queue
+	if len(sink.queue) != 1 || sink.queue[0] != "d95d90a72e0a53e88e6f73a3905ca4fb5973472e" {
+		t.Fatalf("Unexpected queue: %v", sink.queue)
+	}
+}
+
+func TestBTBackupSink_WriteQueue(t *testing.T) {
+	dir := t.TempDir()
+	queuePath := filepath.Join(dir, QueueFileName)
+	if err := os.WriteFile(queuePath, []byte("aaa\nbbb\n"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sink, err := NewSink(&options.Opts{QBitDir: dir})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = sink.WriteQueue([]string{"ccc", "aaa"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(queuePath); string(data) != "bbb\nccc\naaa\n" {
+		t.Fatalf("Unexpected queue file: %q", data)
+	}
+
+	// queue file is restored by undo
+	if _, err = Undo(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(queuePath); string(data) != "aaa\nbbb\n" {
+		t.Fatalf("Queue file wasn't restored: %q", data)
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewSink(&options.Opts{QBitDir: dir})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := sink.(*BTBackupSink); !ok || sink.Journal() == nil {
+		t.Fatalf("Unexpected sink %T for BT_backup directory", sink)
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	sink, err = NewSink(&options.Opts{QBitDir: filepath.Join(dir, "torrents.db")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := sink.(*TorrentsDB); !ok || sink.Journal() == nil {
+		t.Fatalf("Unexpected sink %T for database", sink)
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "torrents.db")); err != nil {
+		t.Fatalf("Database wasn't created: %v", err)
+	}
+}
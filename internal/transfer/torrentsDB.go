@@ -0,0 +1,237 @@
+package transfer
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rumanzo/bt2qbt/pkg/qBittorrentStructures"
+	"github.com/zeebo/bencode"
+	_ "modernc.org/sqlite" // pure go driver, so cgo isn't required
+)
+
+// torrentsDBVersion version of schema that is created in new database. It's schema of qBittorrent 4.6
+const torrentsDBVersion = 6
+
+var torrentsDBSchema = []string{
+	`CREATE TABLE IF NOT EXISTS meta (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		value BLOB)`,
+	`CREATE TABLE IF NOT EXISTS torrents (
+		id INTEGER PRIMARY KEY,
+		torrent_id BLOB NOT NULL UNIQUE,
+		queue_position INTEGER NOT NULL DEFAULT -1,
+		name TEXT,
+		category TEXT,
+		tags TEXT,
+		target_save_path TEXT,
+		download_path TEXT,
+		content_layout TEXT NOT NULL,
+		ratio_limit INTEGER NOT NULL,
+		seeding_time_limit INTEGER NOT NULL,
+		inactive_seeding_time_limit INTEGER NOT NULL,
+		has_outer_pieces_priority INTEGER NOT NULL,
+		has_seed_status INTEGER NOT NULL,
+		operating_mode TEXT NOT NULL,
+		stopped INTEGER NOT NULL,
+		stop_condition TEXT NOT NULL DEFAULT 'None',
+		libtorrent_resume_data BLOB NOT NULL,
+		metadata BLOB)`,
+}
+
+// torrentsDBColumns columns of torrents table that bt2qbt fills
+var torrentsDBColumns = []string{
+	"torrent_id", "queue_position", "name", "category", "tags", "target_save_path", "download_path",
+	"content_layout", "ratio_limit", "seeding_time_limit", "inactive_seeding_time_limit",
+	"has_outer_pieces_priority", "has_seed_status", "operating_mode", "stopped", "stop_condition",
+	"libtorrent_resume_data", "metadata",
+}
+
+// TorrentsDB write torrents into SQLite resume storage of qBittorrent 4.5+ (torrents.db) instead of BT_backup.
+// Database of older qBittorrent versions has fewer columns, so only existing columns are written
+type TorrentsDB struct {
+	localFiles
+	Path    string
+	Policy  ConflictPolicy
+	db      *sql.DB
+	mu      sync.Mutex
+	columns map[string]bool
+}
+
+// OpenTorrentsDB open existing database or create new one with schema of qBittorrent
+func OpenTorrentsDB(path string, policy string) (*TorrentsDB, error) {
+	if policy == "" {
+		policy = string(ConflictOverwrite)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite allows only one writer
+	db.SetMaxOpenConns(1)
+	torrentsDB := &TorrentsDB{Path: path, Policy: ConflictPolicy(policy), db: db, columns: map[string]bool{}}
+	if err = torrentsDB.init(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can't prepare qBittorrent database %v: %v", path, err)
+	}
+	return torrentsDB, nil
+}
+
+func (torrentsDB *TorrentsDB) init() error {
+	for _, statement := range torrentsDBSchema {
+		if _, err := torrentsDB.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	// version is written only for new database, existing one is kept as is
+	if _, err := torrentsDB.db.Exec(`INSERT OR IGNORE INTO meta (name, value) VALUES ('version', ?)`, torrentsDBVersion); err != nil {
+		return err
+	}
+	rows, err := torrentsDB.db.Query(`PRAGMA table_info(torrents)`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid          int64
+			name, ctype  string
+			notNull, pk  int64
+			defaultValue interface{}
+		)
+		if err = rows.Scan(&cid, &name, &ctype, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		torrentsDB.columns[name] = true
+	}
+	return rows.Err()
+}
+
+// WriteTorrent insert torrent row. Metadata is torrent file, it's nil for magnet links.
+// It returns false if torrent already exists and policy is skip
+func (torrentsDB *TorrentsDB) WriteTorrent(hash string, fastresume *qBittorrentStructures.QBittorrentFastresume, metadata []byte) (bool, error) {
+	resumeData, err := GetLibtorrentResumeData(fastresume)
+	if err != nil {
+		return false, err
+	}
+
+	torrentsDB.mu.Lock()
+	defer torrentsDB.mu.Unlock()
+	tx, err := torrentsDB.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var existing []byte
+	queuePosition := int64(-1)
+	// existing torrent keeps its place in queue
+	err = tx.QueryRow(`SELECT libtorrent_resume_data, queue_position FROM torrents WHERE torrent_id = ?`, hash).
+		Scan(&existing, &queuePosition)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	if err == nil {
+		switch torrentsDB.Policy {
+		case ConflictSkip:
+			return false, nil
+		case ConflictMerge:
+			if resumeData, err = mergeFastresume(existing, resumeData); err != nil {
+				return false, fmt.Errorf("can't merge with existing torrent %v: %v", hash, err)
+			}
+		}
+	}
+
+	values := map[string]interface{}{
+		"torrent_id":                  hash,
+		"queue_position":              queuePosition,
+		"name":                        fastresume.QbtName,
+		"category":                    fastresume.QBtCategory,
+		"tags":                        strings.Join(fastresume.QbtTags, ","),
+		"target_save_path":            fastresume.QbtSavePath,
+		"download_path":               "",
+		"content_layout":              fastresume.QBtContentLayout,
+		"ratio_limit":                 fastresume.QbtRatioLimit,
+		"seeding_time_limit":          fastresume.QbtSeedingTimeLimit,
+		"inactive_seeding_time_limit": int64(-2),
+		"has_outer_pieces_priority":   fastresume.QBtFirstLastPiecePriority,
+		"has_seed_status":             fastresume.QbtSeedStatus,
+		"operating_mode":              "AutoManaged",
+		"stopped":                     fastresume.Paused,
+		"stop_condition":              "None",
+		"libtorrent_resume_data":      resumeData,
+		"metadata":                    metadata,
+	}
+	var columns, placeholders []string
+	var args []interface{}
+	for _, column := range torrentsDBColumns {
+		if torrentsDB.columns[column] {
+			columns = append(columns, column)
+			placeholders = append(placeholders, "?")
+			args = append(args, values[column])
+		}
+	}
+	query := fmt.Sprintf(`INSERT OR REPLACE INTO torrents (%v) VALUES (%v)`,
+		strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	if _, err = tx.Exec(query, args...); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// WriteQueue put torrents to the end of queue in the same order as hashes. qBittorrent sorts torrents by queue
+// position on start, so positions may have gaps
+func (torrentsDB *TorrentsDB) WriteQueue(hashes []string) error {
+	if !torrentsDB.columns["queue_position"] {
+		return nil
+	}
+	torrentsDB.mu.Lock()
+	defer torrentsDB.mu.Unlock()
+	tx, err := torrentsDB.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lastPosition int64
+	if err = tx.QueryRow(`SELECT COALESCE(MAX(queue_position), -1) FROM torrents`).Scan(&lastPosition); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		lastPosition++
+		if _, err = tx.Exec(`UPDATE torrents SET queue_position = ? WHERE torrent_id = ?`, lastPosition, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Close close database, then finish journal
+func (torrentsDB *TorrentsDB) Close() error {
+	err := torrentsDB.db.Close()
+	if journalErr := torrentsDB.localFiles.Close(); err == nil {
+		err = journalErr
+	}
+	return err
+}
+
+// GetLibtorrentResumeData return fastresume without qBittorrent keys and info dictionary,
+// because qBittorrent keeps them in columns of database
+func GetLibtorrentResumeData(fastresume *qBittorrentStructures.QBittorrentFastresume) ([]byte, error) {
+	data, err := bencode.EncodeBytes(fastresume)
+	if err != nil {
+		return nil, err
+	}
+	resumeData := map[string]bencode.RawMessage{}
+	if err = bencode.DecodeBytes(data, &resumeData); err != nil {
+		return nil, err
+	}
+	for key := range resumeData {
+		if key == "info" || strings.HasPrefix(key, "qBt-") {
+			delete(resumeData, key)
+		}
+	}
+	return bencode.EncodeBytes(resumeData)
+}
@@ -0,0 +1,220 @@
+package transfer
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/internal/sources"
+	"github.com/rumanzo/bt2qbt/pkg/qBittorrentStructures"
+)
+
+func TestTorrentsDB_WriteTorrent(t *testing.T) {
+	type TorrentsDBCase struct {
+		name               string
+		policy             string
+		oldSchema          bool // database of qBittorrent 4.5 without inactive seeding time limit
+		existing           bool
+		expectedWritten    bool
+		expectedName       string
+		expectedResumeData string
+		expectedQueue      int64
+	}
+	fastresume := &qBittorrentStructures.QBittorrentFastresume{
+		QbtName:          "new",
+		QBtCategory:      "films",
+		QbtTags:          []string{"tag1", "tag2"},
+		QbtSavePath:      "/mnt/films/",
+		QBtContentLayout: "Original",
+		QbtRatioLimit:    -2000,
+		SavePath:         "/mnt/films/",
+		Paused:           1,
+		Info:             map[string]interface{}{"name": "testfileset"},
+	}
+	cases := []TorrentsDBCase{
+		{
+			name:            "001 new database",
+			expectedWritten: true,
+			expectedName:    "new",
+			expectedQueue:   -1,
+		},
+		{
+			name:            "002 existing database of older version",
+			oldSchema:       true,
+			expectedWritten: true,
+			expectedName:    "new",
+			expectedQueue:   -1,
+		},
+		{
+			name:          "003 skip existing torrent",
+			policy:        "skip",
+			existing:      true,
+			expectedName:  "old",
+			expectedQueue: 5,
+		},
+		{
+			name:            "004 overwrite existing torrent keeps queue position",
+			existing:        true,
+			expectedWritten: true,
+			expectedName:    "new",
+			expectedQueue:   5,
+		},
+		{
+			name:               "005 merge existing torrent",
+			policy:             "merge",
+			existing:           true,
+			expectedWritten:    true,
+			expectedName:       "new",
+			expectedResumeData: "7:unknowni1e",
+			expectedQueue:      5,
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "torrents.db")
+			if testCase.oldSchema || testCase.existing {
+				db, err := sql.Open("sqlite", path)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if testCase.oldSchema {
+					_, err = db.Exec(`CREATE TABLE torrents (id INTEGER PRIMARY KEY, torrent_id BLOB NOT NULL UNIQUE,
+						queue_position INTEGER NOT NULL DEFAULT -1, name TEXT, category TEXT, tags TEXT,
+						target_save_path TEXT, content_layout TEXT NOT NULL, ratio_limit INTEGER NOT NULL,
+						seeding_time_limit INTEGER NOT NULL, has_outer_pieces_priority INTEGER NOT NULL,
+						has_seed_status INTEGER NOT NULL, operating_mode TEXT NOT NULL, stopped INTEGER NOT NULL,
+						libtorrent_resume_data BLOB NOT NULL, metadata BLOB)`)
+				} else {
+					for _, statement := range torrentsDBSchema {
+						if _, err = db.Exec(statement); err != nil {
+							break
+						}
+					}
+					if err == nil {
+						_, err = db.Exec(`INSERT INTO torrents (torrent_id, queue_position, name, content_layout, ratio_limit,
+							seeding_time_limit, inactive_seeding_time_limit, has_outer_pieces_priority, has_seed_status,
+							tags, operating_mode, stopped, libtorrent_resume_data) VALUES ('hash', 5, 'old', 'Original', -2000,
+							-2, -2, 0, 1, '', 'AutoManaged', 0, 'd9:save_path4:/old7:unknowni1ee')`)
+					}
+				}
+				db.Close()
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			torrentsDB, err := OpenTorrentsDB(path, testCase.policy)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			written, err := torrentsDB.WriteTorrent("hash", fastresume, []byte("d4:infod4:name11:testfilesetee"))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if written != testCase.expectedWritten {
+				t.Fatalf("Unexpected written flag: %v", written)
+			}
+
+			var name, tags string
+			var queuePosition, stopped int64
+			var resumeData []byte
+			err = torrentsDB.db.QueryRow(`SELECT name, tags, queue_position, stopped, libtorrent_resume_data FROM torrents WHERE torrent_id = 'hash'`).
+				Scan(&name, &tags, &queuePosition, &stopped, &resumeData)
+			torrentsDB.Close()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if name != testCase.expectedName || queuePosition != testCase.expectedQueue {
+				t.Fatalf("Unexpected row: name %v, queue position %v", name, queuePosition)
+			}
+			if !testCase.expectedWritten {
+				return
+			}
+			if tags != "tag1,tag2" || stopped != 1 {
+				t.Fatalf("Unexpected row: tags %v, stopped %v", tags, stopped)
+			}
+			if !strings.Contains(string(resumeData), "9:save_path11:/mnt/films/") || strings.Contains(string(resumeData), "qBt-") ||
+				strings.Contains(string(resumeData), "4:info") {
+				t.Fatalf("Unexpected libtorrent resume data: %v", string(resumeData))
+			}
+			if testCase.expectedResumeData != "" && !strings.Contains(string(resumeData), testCase.expectedResumeData) {
+				t.Fatalf("Libtorrent resume data doesn't contain %v: %v", testCase.expectedResumeData, string(resumeData))
+			}
+		})
+	}
+}
+
+func TestTorrentsDB_WriteQueue(t *testing.T) {
+	torrentsDB, err := OpenTorrentsDB(filepath.Join(t.TempDir(), "torrents.db"), "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer torrentsDB.Close()
+	for _, hash := range []string{"aaa", "bbb", "ccc"} {
+		if _, err = torrentsDB.WriteTorrent(hash, &qBittorrentStructures.QBittorrentFastresume{}, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if _, err = torrentsDB.db.Exec(`UPDATE torrents SET queue_position = 3 WHERE torrent_id = 'aaa'`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err = torrentsDB.WriteQueue([]string{"ccc", "bbb"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]int64{"aaa": 3, "bbb": 5, "ccc": 4}
+	for hash, expectedPosition := range expected {
+		var position int64
+		if err = torrentsDB.db.QueryRow(`SELECT queue_position FROM torrents WHERE torrent_id = ?`, hash).Scan(&position); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if position != expectedPosition {
+			t.Fatalf("Unexpected queue position of %v: %v, expected %v", hash, position, expectedPosition)
+		}
+	}
+}
+
+func TestHandleTorrentsTorrentsDB(t *testing.T) {
+	qbtDir := t.TempDir()
+	opts := &options.Opts{
+		BitDir:        "../../test/data",
+		QBitDir:       filepath.Join(qbtDir, "torrents.db"),
+		PathSeparator: `/`,
+		SearchPaths:   []string{"../../test/data"},
+		WithoutTags:   true,
+	}
+	torrents := map[string]*sources.Torrent{
+		"testfileset.torrent": {Path: "/mnt/films/testfileset", Started: true},
+	}
+	if wasErrors := HandleTorrents(opts, torrents); wasErrors {
+		t.Fatalf("Unexpected errors while processing")
+	}
+
+	torrentsDB, err := OpenTorrentsDB(opts.QBitDir, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var savePath string
+	var metadata []byte
+	err = torrentsDB.db.QueryRow(`SELECT target_save_path, metadata FROM torrents WHERE torrent_id = ?`,
+		"3456bac107634970b022677c6bfaa584065e0917").Scan(&savePath, &metadata)
+	torrentsDB.Close()
+	if err != nil {
+		t.Fatalf("Torrent wasn't written: %v", err)
+	}
+	torrentFile, _ := os.ReadFile("../../test/data/testfileset.torrent")
+	if savePath != "/mnt/films/testfileset" || string(metadata) != string(torrentFile) {
+		t.Fatalf("Unexpected row: save path %v", savePath)
+	}
+
+	// fastresume files aren't written and database is removed by undo, because it didn't exist
+	if _, err = Undo(qbtDir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entries, _ := os.ReadDir(qbtDir); len(entries) != 0 {
+		t.Fatalf("Unexpected files after undo: %v", entries)
+	}
+}
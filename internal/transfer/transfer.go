@@ -2,29 +2,37 @@ package transfer
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
-	"io"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/rumanzo/bt2qbt/internal/options"
 	"github.com/rumanzo/bt2qbt/internal/replace"
+	"github.com/rumanzo/bt2qbt/internal/sources"
+	"github.com/rumanzo/bt2qbt/pkg/bencodeScanner"
 	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
 	"github.com/rumanzo/bt2qbt/pkg/helpers"
+	"github.com/rumanzo/bt2qbt/pkg/magnetLinks"
 	"github.com/rumanzo/bt2qbt/pkg/normalization"
+	"github.com/rumanzo/bt2qbt/pkg/partFiles"
 	"github.com/rumanzo/bt2qbt/pkg/qBittorrentStructures"
 	"github.com/rumanzo/bt2qbt/pkg/torrentStructures"
-	"github.com/rumanzo/bt2qbt/pkg/utorrentStructs"
+	"github.com/rumanzo/bt2qbt/pkg/verification"
 	"github.com/zeebo/bencode"
 )
 
 //goland:noinspection GoNameStartsWithPackageName
 type TransferStructure struct {
 	Fastresume      *qBittorrentStructures.QBittorrentFastresume `bencode:"-"`
-	ResumeItem      *utorrentStructs.ResumeItem                  `bencode:"-"`
+	Torrent         *sources.Torrent                             `bencode:"-"`
 	TorrentFile     *torrentStructures.Torrent                   `bencode:"-"`
-	TorrentFileRaw  map[string]interface{}                       `bencode:"-"`
+	TorrentInfoRaw  []byte                                       `bencode:"-"` // exact bytes of info dictionary from torrent file
 	Opts            *options.Opts                                `bencode:"-"`
 	TorrentFilePath string                                       `bencode:"-"`
 	TorrentFileName string                                       `bencode:"-"`
@@ -32,6 +40,11 @@ type TransferStructure struct {
 	Replace         []*replace.Replace                           `bencode:"-"`
 	Targets         map[int64]string                             `bencode:"-"`
 	Magnet          bool                                         `bencode:"-"`
+	MagnetLink      *magnetLinks.MagnetLink                      `bencode:"-"`
+	PartFile        *partFiles.UTorrentPartFile                  `bencode:"-"`
+	Warnings        []string                                     `bencode:"-"`
+	Sink            Sink                                         `bencode:"-"` // nil on dry run
+	Verifier        *verification.Verifier                       `bencode:"-"`
 }
 
 func CreateEmptyNewTransferStructure() TransferStructure {
@@ -67,25 +80,24 @@ func CreateEmptyNewTransferStructure() TransferStructure {
 			UploadRateLimit:     0,
 			QbtName:             "",
 		},
-		TorrentFile:    &torrentStructures.Torrent{},
-		TorrentFileRaw: map[string]interface{}{},
-		ResumeItem:     &utorrentStructs.ResumeItem{},
-		Targets:        map[int64]string{},
-		Opts:           &options.Opts{},
+		TorrentFile: &torrentStructures.Torrent{},
+		Torrent:     &sources.Torrent{},
+		Targets:     map[int64]string{},
+		Opts:        &options.Opts{},
 	}
 	return transferStructure
 }
 
 func (transfer *TransferStructure) HandleCaption() {
-	if transfer.ResumeItem.Caption != "" {
-		transfer.Fastresume.QbtName = helpers.HandleCesu8(transfer.ResumeItem.Caption)
+	if transfer.Torrent.Name != "" {
+		transfer.Fastresume.QbtName = helpers.HandleCesu8(transfer.Torrent.Name)
 	}
 }
 
 // HandleState transfer torrents state.
 // if torrent has several files, and it doesn't complete downloaded (priority), it will be stopped
 func (transfer *TransferStructure) HandleState() {
-	if transfer.ResumeItem.Started == 0 {
+	if !transfer.Torrent.Started {
 		transfer.Fastresume.Paused = 1
 		transfer.Fastresume.AutoManaged = 0
 	} else {
@@ -110,24 +122,141 @@ func (transfer *TransferStructure) HandleState() {
 }
 
 func (transfer *TransferStructure) HandleTotalDownloaded() {
-	if transfer.ResumeItem.CompletedOn == 0 {
+	if transfer.Torrent.CompletedOn == 0 {
 		transfer.Fastresume.TotalDownloaded = 0
 	} else {
-		transfer.Fastresume.TotalDownloaded = transfer.ResumeItem.Downloaded
+		transfer.Fastresume.TotalDownloaded = transfer.Torrent.Downloaded
 	}
 }
 
+// HandleCompleted transfer time when torrent was seen complete. It's unknown for some completed torrents, but they are
+// complete now
 func (transfer *TransferStructure) HandleCompleted() {
+	transfer.Fastresume.LastSeenComplete = transfer.Torrent.LastSeenComplete
 	if transfer.Fastresume.CompletedTime != 0 {
-		transfer.Fastresume.LastSeenComplete = time.Now().Unix()
+		if transfer.Fastresume.LastSeenComplete == 0 {
+			transfer.Fastresume.LastSeenComplete = time.Now().Unix()
+		}
 	} else {
-		transfer.Fastresume.Unfinished = new([]interface{})
+		transfer.Fastresume.Unfinished = transfer.GetUnfinished()
+	}
+}
+
+/*
+HandleActivity transfer time statistics. Finished time is time of seeding, because clients count seeding time from
+completion too. Only time of the last activity is known, so it's time of the last upload, and time of the last
+download if torrent isn't completed. Completed torrents were downloaded the last time at completion.
+*/
+func (transfer *TransferStructure) HandleActivity() {
+	transfer.Fastresume.FinishedTime = transfer.Torrent.SeedingTime
+	lastActive := transfer.Torrent.LastActive
+	if transfer.Torrent.Uploaded > 0 {
+		transfer.Fastresume.LastUpload = lastActive
+	}
+	if transfer.Torrent.Downloaded > 0 {
+		transfer.Fastresume.LastDownload = lastActive
+		if completedOn := transfer.Torrent.CompletedOn; completedOn != 0 && (completedOn < lastActive || lastActive == 0) {
+			transfer.Fastresume.LastDownload = completedOn
+		}
+	}
+}
+
+// blockSize libtorrent downloads pieces by 16KiB blocks
+const blockSize = 16 * 1024
+
+/*
+GetUnfinished convert partially downloaded pieces to libtorrent unfinished pieces with bitmask of downloaded 16KiB
+blocks (one bit per block, least significant bit first).
+https://libtorrent.org/manual-ref.html#fast-resume
+*/
+func (transfer *TransferStructure) GetUnfinished() *[]interface{} {
+	unfinished := []interface{}{}
+	if transfer.TorrentFile.Info.PieceLength <= 0 {
+		return &unfinished
+	}
+	blocksPerPiece := (transfer.TorrentFile.Info.PieceLength + blockSize - 1) / blockSize
+	for _, unfinishedPiece := range transfer.Torrent.Unfinished {
+		piece := unfinishedPiece.Piece
+		if piece < 0 || piece >= transfer.NumPieces || transfer.havePiece(piece) {
+			continue
+		}
+		bitmask := make([]byte, (blocksPerPiece+7)/8)
+		for _, block := range unfinishedPiece.Blocks {
+			if block >= 0 && block < blocksPerPiece {
+				bitmask[block/8] |= 1 << uint(block%8)
+			}
+		}
+		unfinished = append(unfinished, map[string]interface{}{
+			"piece":   piece,
+			"bitmask": string(bitmask),
+		})
+	}
+	return &unfinished
+}
+
+// havePiece check bitfield of completed pieces, the high bit in the first byte corresponds to piece index 0
+func (transfer *TransferStructure) havePiece(piece int64) bool {
+	if piece/8 >= int64(len(transfer.Torrent.Pieces)) {
+		return false
+	}
+	return transfer.Torrent.Pieces[piece/8]&(0x80>>uint(piece%8)) != 0
+}
+
+// HasAllPieces report if all pieces of fastresume are downloaded, so checking of files can be skipped
+func (transfer *TransferStructure) HasAllPieces() bool {
+	if transfer.Magnet || len(transfer.Fastresume.Pieces) == 0 {
+		return false
+	}
+	for _, piece := range transfer.Fastresume.Pieces {
+		if piece&1 == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// HandleSeedSettings transfer ratio and seeding time limits if torrent overrides global seeding settings.
+// 0 means without limit, it's -1 in qBittorrent (ratio is multiplied by 1000)
+func (transfer *TransferStructure) HandleSeedSettings() {
+	seedLimits := transfer.Torrent.SeedLimits
+	if seedLimits == nil {
+		return
+	}
+	transfer.Fastresume.QbtRatioLimit = -1000
+	if seedLimits.Ratio > 0 {
+		transfer.Fastresume.QbtRatioLimit = int64(math.Round(seedLimits.Ratio * 1000))
+	}
+	transfer.Fastresume.QbtSeedingTimeLimit = -1
+	if seedLimits.SeedingTime > 0 {
+		// qBittorrent saves minutes. Round up, because 0 means to stop seeding at once
+		transfer.Fastresume.QbtSeedingTimeLimit = (seedLimits.SeedingTime + 59) / 60
+	}
+}
+
+// HandleLimits transfer per torrent rate limits, upload slots, super seeding and peer sources.
+// 0 means unlimited or default of client, so such values aren't transferred
+func (transfer *TransferStructure) HandleLimits() {
+	transfer.Fastresume.UploadRateLimit = transfer.Torrent.UploadLimit
+	if transfer.Torrent.DownloadLimit > 0 {
+		transfer.Fastresume.DownloadRateLimit = transfer.Torrent.DownloadLimit
+	}
+	if transfer.Torrent.UploadSlots > 0 {
+		transfer.Fastresume.MaxUploads = transfer.Torrent.UploadSlots
+	}
+	if transfer.Torrent.SuperSeeding {
+		transfer.Fastresume.SuperSeeding = 1
+	}
+	if transfer.Torrent.DisableDHT {
+		transfer.Fastresume.DisableDht = 1
+	}
+	if transfer.Torrent.DisablePEX {
+		transfer.Fastresume.DisablePex = 1
 	}
 }
 
 func (transfer *TransferStructure) HandleTags() {
-	if transfer.Opts.WithoutTags == false && transfer.ResumeItem.Labels != nil {
-		for _, label := range transfer.ResumeItem.Labels {
+	if transfer.Opts.WithoutTags == false && transfer.Torrent.Tags != nil {
+		for _, label := range transfer.Torrent.Tags {
 			if label != "" {
 				transfer.Fastresume.QbtTags = append(transfer.Fastresume.QbtTags, helpers.HandleCesu8(label))
 			}
@@ -138,7 +267,7 @@ func (transfer *TransferStructure) HandleTags() {
 }
 func (transfer *TransferStructure) HandleLabels() {
 	if transfer.Opts.WithoutLabels == false {
-		transfer.Fastresume.QBtCategory = helpers.HandleCesu8(transfer.ResumeItem.Label)
+		transfer.Fastresume.QBtCategory = helpers.HandleCesu8(transfer.Torrent.Category)
 	} else {
 		transfer.Fastresume.QBtCategory = ""
 	}
@@ -146,60 +275,174 @@ func (transfer *TransferStructure) HandleLabels() {
 
 var localTracker = regexp.MustCompile(`(http|udp)://\S+\.local\S*`)
 
+// HandleTrackers transfer tiers of trackers. Local trackers are moved to the last tier
 func (transfer *TransferStructure) HandleTrackers() {
-	trackers := helpers.GetStrings(transfer.ResumeItem.Trackers)
-	trackersMap := map[string][]string{}
-	var index string
-	for _, tracker := range trackers {
-		if localTracker.MatchString(tracker) {
-			index = "local"
-		} else {
-			index = "main"
+	var localTrackers []string
+	for _, tier := range transfer.Torrent.Trackers {
+		var trackers []string
+		for _, tracker := range tier {
+			if localTracker.MatchString(tracker) {
+				localTrackers = append(localTrackers, helpers.HandleCesu8(tracker))
+			} else {
+				trackers = append(trackers, helpers.HandleCesu8(tracker))
+			}
 		}
-		if _, ok := trackersMap[index]; ok {
-			trackersMap[index] = append(trackersMap[index], helpers.HandleCesu8(tracker))
-		} else {
-			trackersMap[index] = []string{helpers.HandleCesu8(tracker)}
+		if len(trackers) != 0 {
+			transfer.Fastresume.Trackers = append(transfer.Fastresume.Trackers, trackers)
 		}
 	}
-	if val, ok := trackersMap["main"]; ok {
-		transfer.Fastresume.Trackers = append(transfer.Fastresume.Trackers, val)
-	}
-	if val, ok := trackersMap["local"]; ok {
-		transfer.Fastresume.Trackers = append(transfer.Fastresume.Trackers, val)
+	if len(localTrackers) != 0 {
+		transfer.Fastresume.Trackers = append(transfer.Fastresume.Trackers, localTrackers)
 	}
 }
 
 func (transfer *TransferStructure) HandlePriority() {
-	if transfer.TorrentFile.IsV2OrHybryd() { // so we need get only odd
-		trimmedPrio := make([]byte, 0, len(transfer.ResumeItem.Prio)/2)
-		for i := 0; i < len(transfer.ResumeItem.Prio); i += 2 {
-			trimmedPrio = append(trimmedPrio, transfer.ResumeItem.Prio[i])
-		}
-		transfer.ResumeItem.Prio = trimmedPrio
-	}
-	for _, c := range transfer.ResumeItem.Prio {
-		if i := int(c); (i == 0) || (i == 128) { // if priority not selected
-			transfer.Fastresume.FilePriority = append(transfer.Fastresume.FilePriority, 0)
-		} else if (i >= 1) && (i <= 8) { // if low or normal priority
+	for _, priority := range transfer.Torrent.FilePriorities {
+		switch priority {
+		case sources.PriorityNormal:
 			transfer.Fastresume.FilePriority = append(transfer.Fastresume.FilePriority, 1)
-		} else if (i > 8) && (i <= 15) { // if high priority
+		case sources.PriorityHigh:
 			transfer.Fastresume.FilePriority = append(transfer.Fastresume.FilePriority, 6)
-		} else {
+		default:
 			transfer.Fastresume.FilePriority = append(transfer.Fastresume.FilePriority, 0)
 		}
 	}
 }
 
+// HandleMagnet fill metadata-less fastresume from magnet link
+func (transfer *TransferStructure) HandleMagnet() {
+	magnetLink := transfer.MagnetLink
+	if magnetLink.InfoHash != nil {
+		transfer.Fastresume.InfoHash = string(magnetLink.InfoHash)
+	} else {
+		transfer.Fastresume.InfoHash = string(make([]byte, 20))
+	}
+	if magnetLink.InfoHashV2 != nil {
+		transfer.Fastresume.InfoHash2 = string(magnetLink.InfoHashV2)
+	}
+	transfer.Fastresume.Name = magnetLink.Name
+
+	// magnet trackers append as new tiers if source client doesn't know them
+	knownTrackers := map[string]bool{}
+	for _, tier := range transfer.Fastresume.Trackers {
+		for _, tracker := range tier {
+			knownTrackers[tracker] = true
+		}
+	}
+	for _, tier := range magnetLink.Trackers {
+		for _, tracker := range tier {
+			if !knownTrackers[tracker] {
+				knownTrackers[tracker] = true
+				transfer.Fastresume.Trackers = append(transfer.Fastresume.Trackers, []string{tracker})
+			}
+		}
+	}
+	transfer.Fastresume.UrlList = append(transfer.Fastresume.UrlList, magnetLink.WebSeeds...)
+
+	if len(transfer.Fastresume.FilePriority) == 0 {
+		transfer.Fastresume.FilePriority = magnetLink.GetFilePriority()
+	}
+}
+
+// HandleInfoHash fill info hashes from info dictionary and check that source client has the same v1 info hash.
+// v2 info hash exists only for v2 and hybrid torrents, v2 only torrents don't have v1 info hash
+func (transfer *TransferStructure) HandleInfoHash() {
+	if transfer.TorrentFile.IsV2Only() {
+		transfer.Fastresume.InfoHash = string(make([]byte, sha1.Size))
+	} else {
+		transfer.Fastresume.InfoHash = string(transfer.GetInfoHashV1())
+		if transfer.Torrent != nil && transfer.Torrent.InfoHash != "" &&
+			transfer.Torrent.InfoHash != transfer.Fastresume.InfoHash {
+			transfer.Warnings = append(transfer.Warnings, fmt.Sprintf("info hash %x from source client doesn't match info hash %x of torrent file",
+				transfer.Torrent.InfoHash, transfer.Fastresume.InfoHash))
+		}
+	}
+	if transfer.TorrentFile.IsV2OrHybryd() {
+		transfer.Fastresume.InfoHash2 = string(transfer.GetInfoHashV2())
+	}
+}
+
+// GetInfoHashV1 SHA-1 of info dictionary
+func (transfer *TransferStructure) GetInfoHashV1() []byte {
+	hash := sha1.Sum(transfer.TorrentInfoRaw)
+	return hash[:]
+}
+
+// GetInfoHashV2 SHA-256 of info dictionary
+func (transfer *TransferStructure) GetInfoHashV2() []byte {
+	hash := sha256.Sum256(transfer.TorrentInfoRaw)
+	return hash[:]
+}
+
+// LoadTorrentFile decode torrent file and keep raw bytes of info dictionary,
+// because decoding and encoding it again change hash for non-canonical bencode
+func (transfer *TransferStructure) LoadTorrentFile() error {
+	data, err := os.ReadFile(transfer.TorrentFilePath)
+	if err != nil {
+		return err
+	}
+	if err = bencode.DecodeBytes(data, transfer.TorrentFile); err != nil {
+		return err
+	}
+	transfer.TorrentInfoRaw, err = bencodeScanner.RawValue(data, "info")
+	return err
+}
+
+// GetHash return torrent id that qBittorrent use for file names in BT_backup.
+// It's v1 info hash for v1 torrents and v2 info hash truncated to 20 bytes for v2 and hybrid torrents
 func (transfer *TransferStructure) GetHash() (hash string) {
-	torinfo, _ := bencode.EncodeString(transfer.TorrentFileRaw["info"])
-	h := sha1.New()
-	io.WriteString(h, torinfo)
-	hash = hex.EncodeToString(h.Sum(nil))
-	return
+	if transfer.Magnet {
+		return transfer.MagnetLink.GetTorrentID()
+	}
+	if transfer.TorrentFile.IsV2OrHybryd() {
+		return hex.EncodeToString(transfer.GetInfoHashV2()[:sha1.Size])
+	}
+	return hex.EncodeToString(transfer.GetInfoHashV1())
+}
+
+// GetFastresumePath return path of fastresume file in qBittorrent directory
+func (transfer *TransferStructure) GetFastresumePath(hash string) string {
+	return filepath.Join(transfer.Opts.QBitDir, hash+".fastresume")
+}
+
+// GetTorrentPath return path of torrent file copy in qBittorrent directory
+func (transfer *TransferStructure) GetTorrentPath(hash string) string {
+	return filepath.Join(transfer.Opts.QBitDir, hash+".torrent")
+}
+
+// GetPartFilePath return path of libtorrent part file. libtorrent keeps part file in save path
+func (transfer *TransferStructure) GetPartFilePath(hash string) string {
+	return fileHelpers.Join([]string{transfer.Fastresume.SavePath, "." + hash + ".parts"}, string(os.PathSeparator))
+}
+
+// GetOutputPaths return paths of all files that will be written for torrent
+func (transfer *TransferStructure) GetOutputPaths(hash string) []string {
+	var paths []string
+	if transfer.Opts.WebUI != "" {
+		// qBittorrent writes files itself
+		paths = append(paths, transfer.Opts.WebUI)
+	} else if transfer.Opts.IsTorrentsDB() {
+		// all torrents are rows of the same database
+		paths = append(paths, transfer.Opts.QBitDir)
+	} else {
+		paths = append(paths, transfer.GetFastresumePath(hash))
+		if !transfer.Magnet {
+			paths = append(paths, transfer.GetTorrentPath(hash))
+		}
+	}
+	if transfer.PartFile != nil && transfer.Opts.WebUI == "" {
+		paths = append(paths, transfer.GetPartFilePath(hash))
+	}
+	return paths
 }
 
 func (transfer *TransferStructure) HandlePieces() {
+	// bitfield of source client is exact, so priorities are used only if it is missing
+	if transfer.Torrent != nil && transfer.Torrent.Pieces != nil &&
+		int64(len(transfer.Torrent.Pieces)) == (transfer.NumPieces+7)/8 {
+		transfer.FillHavePieces()
+		return
+	}
 	if transfer.Fastresume.Unfinished != nil {
 		transfer.FillWholePieces(0)
 	} else {
@@ -211,6 +454,17 @@ func (transfer *TransferStructure) HandlePieces() {
 	}
 }
 
+func (transfer *TransferStructure) FillHavePieces() {
+	transfer.Fastresume.Pieces = make([]byte, 0, transfer.NumPieces)
+	for i := int64(0); i < transfer.NumPieces; i++ {
+		if transfer.havePiece(i) {
+			transfer.Fastresume.Pieces = append(transfer.Fastresume.Pieces, byte(1))
+		} else {
+			transfer.Fastresume.Pieces = append(transfer.Fastresume.Pieces, byte(0))
+		}
+	}
+}
+
 func (transfer *TransferStructure) FillWholePieces(piecePrio int) {
 	transfer.Fastresume.Pieces = make([]byte, 0, transfer.NumPieces)
 	for i := int64(0); i < transfer.NumPieces; i++ {
@@ -265,6 +519,79 @@ func (transfer *TransferStructure) FillPiecesParted() {
 	}
 }
 
+// HandlePartFile find uTorrent part file with pieces of not selected files in torrent directory.
+// Only multi file torrents with not selected files have such pieces on boundaries
+func (transfer *TransferStructure) HandlePartFile() error {
+	if transfer.Magnet || transfer.TorrentFile.IsSingle() {
+		return nil
+	}
+	var parted bool
+	for _, prio := range transfer.Fastresume.FilePriority {
+		if prio == 0 {
+			parted = true
+			break
+		}
+	}
+	if !parted {
+		return nil
+	}
+	partFile, warnings, err := partFiles.FindUTorrentPartFile(transfer.GetContentDir(), transfer.NumPieces, transfer.TorrentFile.Info.PieceLength)
+	if err != nil {
+		return err
+	}
+	transfer.Warnings = append(transfer.Warnings, warnings...)
+	transfer.PartFile = partFile
+	return nil
+}
+
+// HandleVerify compute pieces by hashing files on disk instead of guessing them from priorities
+func (transfer *TransferStructure) HandleVerify() error {
+	if transfer.Verifier == nil || transfer.Magnet {
+		return nil
+	}
+	pieces, err := transfer.Verifier.Verify(transfer.TorrentFile, transfer.GetFilePaths())
+	if err != nil {
+		return err
+	}
+	transfer.Fastresume.Pieces = pieces
+	return nil
+}
+
+// GetFilePaths return paths of torrent files on disk with mapped files after all replaces
+func (transfer *TransferStructure) GetFilePaths() []string {
+	separator := string(os.PathSeparator)
+	var fileList []string
+	if transfer.TorrentFile.IsSingle() {
+		fileList = []string{transfer.TorrentFile.GetTorrentName()}
+	} else {
+		fileList, _ = transfer.TorrentFile.GetFileList()
+	}
+	filePaths := make([]string, 0, len(fileList))
+	for index, filePath := range fileList {
+		if index < len(transfer.Fastresume.MappedFiles) && transfer.Fastresume.MappedFiles[index] != "" {
+			mappedFile := transfer.Fastresume.MappedFiles[index]
+			if fileHelpers.IsAbs(mappedFile) || filepath.IsAbs(mappedFile) {
+				filePaths = append(filePaths, fileHelpers.Normalize(mappedFile, separator))
+			} else {
+				filePaths = append(filePaths, fileHelpers.Join([]string{transfer.Fastresume.SavePath, mappedFile}, separator))
+			}
+		} else if transfer.TorrentFile.IsSingle() {
+			filePaths = append(filePaths, fileHelpers.Join([]string{transfer.Fastresume.SavePath, filePath}, separator))
+		} else {
+			filePaths = append(filePaths, fileHelpers.Join([]string{transfer.GetContentDir(), filePath}, separator))
+		}
+	}
+	return filePaths
+}
+
+// GetContentDir return directory with torrent files after all replaces
+func (transfer *TransferStructure) GetContentDir() string {
+	if transfer.Fastresume.QBtContentLayout == "Original" && !transfer.TorrentFile.IsSingle() {
+		return fileHelpers.Join([]string{transfer.Fastresume.SavePath, transfer.Fastresume.Name}, string(os.PathSeparator))
+	}
+	return fileHelpers.Normalize(transfer.Fastresume.SavePath, string(os.PathSeparator))
+}
+
 func (transfer *TransferStructure) HandleSavePaths() {
 	// Original paths always ending with pathSeparator
 	// SubFolder or NoSubfolder never have ending pathSeparator
@@ -272,7 +599,7 @@ func (transfer *TransferStructure) HandleSavePaths() {
 
 	if transfer.Magnet {
 		transfer.Fastresume.QBtContentLayout = "Original"
-		transfer.Fastresume.QbtSavePath = fileHelpers.Normalize(helpers.HandleCesu8(transfer.ResumeItem.Path), "/")
+		transfer.Fastresume.QbtSavePath = fileHelpers.Normalize(helpers.HandleCesu8(transfer.Torrent.Path), "/")
 	} else {
 		var nameNormalized bool
 		transfer.Fastresume.Name, nameNormalized = normalization.FullNormalize(transfer.TorrentFile.GetTorrentName())
@@ -280,8 +607,12 @@ func (transfer *TransferStructure) HandleSavePaths() {
 		if strings.ContainsAny(transfer.Fastresume.Name, "\u200e\u200f") {
 			nameNormalized = true
 		}
+		if nameNormalized {
+			transfer.Warnings = append(transfer.Warnings, fmt.Sprintf("torrent name %v was normalized to %v",
+				transfer.TorrentFile.GetTorrentName(), transfer.Fastresume.Name))
+		}
 
-		lastPathName := fileHelpers.Base(helpers.HandleCesu8(transfer.ResumeItem.Path))
+		lastPathName := fileHelpers.Base(helpers.HandleCesu8(transfer.Torrent.Path))
 		// if FileList contain only 1 file that means it is single file torrent
 		if !transfer.TorrentFile.IsSingle() {
 			fileList, filesNormalized := transfer.TorrentFile.GetFileList()
@@ -290,30 +621,27 @@ func (transfer *TransferStructure) HandleSavePaths() {
 					filesNormalized = true
 				}
 			}
+			if filesNormalized {
+				transfer.Warnings = append(transfer.Warnings, "file names were normalized, content layout NoSubfolder is used")
+			}
 
 			if lastPathName == transfer.Fastresume.Name && !filesNormalized && !nameNormalized {
 				transfer.Fastresume.QBtContentLayout = "Original"
-				transfer.Fastresume.QbtSavePath = fileHelpers.CutLastPath(helpers.HandleCesu8(transfer.ResumeItem.Path), transfer.Opts.PathSeparator)
+				transfer.Fastresume.QbtSavePath = fileHelpers.CutLastPath(helpers.HandleCesu8(transfer.Torrent.Path), transfer.Opts.PathSeparator)
 				if maxIndex := transfer.FindHighestIndexOfMappedFiles(); maxIndex >= 0 {
 					transfer.Fastresume.MappedFiles = make([]string, maxIndex+1, maxIndex+1)
-					for _, paths := range transfer.ResumeItem.Targets {
-						index := paths[0].(int64)
-						var pathParts []string
-						if fileHelpers.IsAbs(helpers.HandleCesu8(paths[1].(string))) {
-							pathParts = []string{fileHelpers.Normalize(helpers.HandleCesu8(paths[1].(string)), transfer.Opts.PathSeparator)}
+					for _, renamedFile := range transfer.Torrent.RenamedFiles {
+						filePath := helpers.HandleCesu8(renamedFile.Path)
+						if fileHelpers.IsAbs(filePath) {
 							// if path is absolute just normalize it
-							transfer.Fastresume.MappedFiles[index] = fileHelpers.Join(pathParts, transfer.Opts.PathSeparator)
+							transfer.Fastresume.MappedFiles[renamedFile.Index] = fileHelpers.Normalize(filePath, transfer.Opts.PathSeparator)
 						} else {
-							pathParts = make([]string, len(paths)-1, len(paths)-1)
-							for num, part := range paths[1:] {
-								pathParts[num] = helpers.HandleCesu8(part.(string))
-							}
 							// we have to append torrent name(from torrent file) at the top of path
-							transfer.Fastresume.MappedFiles[index] = fileHelpers.Join(append([]string{transfer.Fastresume.Name}, pathParts...), transfer.Opts.PathSeparator)
+							transfer.Fastresume.MappedFiles[renamedFile.Index] = fileHelpers.Join([]string{transfer.Fastresume.Name, filePath}, transfer.Opts.PathSeparator)
 						}
 					}
 				}
-				transfer.Fastresume.QbtSavePath = fileHelpers.CutLastPath(helpers.HandleCesu8(transfer.ResumeItem.Path), "/")
+				transfer.Fastresume.QbtSavePath = fileHelpers.CutLastPath(helpers.HandleCesu8(transfer.Torrent.Path), "/")
 				if string(transfer.Fastresume.QbtSavePath[len(transfer.Fastresume.QbtSavePath)-1]) != `/` {
 					transfer.Fastresume.QbtSavePath += `/`
 				}
@@ -324,23 +652,13 @@ func (transfer *TransferStructure) HandleSavePaths() {
 					transfer.Fastresume.MappedFiles = append(transfer.Fastresume.MappedFiles,
 						fileHelpers.Normalize(filePath, transfer.Opts.PathSeparator))
 				}
-				// and then doing remap if resumeItem contain target field
+				// and then doing remap if torrent has renamed files
 				if maxIndex := transfer.FindHighestIndexOfMappedFiles(); maxIndex >= 0 {
-					for _, paths := range transfer.ResumeItem.Targets {
-						index := paths[0].(int64)
-						var pathParts []string
-						if fileHelpers.IsAbs(helpers.HandleCesu8(paths[1].(string))) {
-							pathParts = []string{fileHelpers.Normalize(helpers.HandleCesu8(paths[1].(string)), transfer.Opts.PathSeparator)}
-						} else {
-							pathParts = make([]string, len(paths)-1, len(paths)-1)
-							for num, part := range paths[1:] {
-								pathParts[num] = helpers.HandleCesu8(part.(string))
-							}
-						}
-						transfer.Fastresume.MappedFiles[index] = fileHelpers.Join(pathParts, transfer.Opts.PathSeparator)
+					for _, renamedFile := range transfer.Torrent.RenamedFiles {
+						transfer.Fastresume.MappedFiles[renamedFile.Index] = fileHelpers.Normalize(helpers.HandleCesu8(renamedFile.Path), transfer.Opts.PathSeparator)
 					}
 				}
-				transfer.Fastresume.QbtSavePath = fileHelpers.Normalize(helpers.HandleCesu8(transfer.ResumeItem.Path), "/")
+				transfer.Fastresume.QbtSavePath = fileHelpers.Normalize(helpers.HandleCesu8(transfer.Torrent.Path), "/")
 			}
 		} else {
 			transfer.Fastresume.QBtContentLayout = "Original" // utorrent\bittorrent don't support create subfolders for torrents with single file
@@ -348,22 +666,14 @@ func (transfer *TransferStructure) HandleSavePaths() {
 				//it means that we have renamed path and targets item, and should have mapped files
 				transfer.Fastresume.MappedFiles = []string{lastPathName}
 			}
-			transfer.Fastresume.QbtSavePath = fileHelpers.CutLastPath(helpers.HandleCesu8(transfer.ResumeItem.Path), `/`)
+			transfer.Fastresume.QbtSavePath = fileHelpers.CutLastPath(helpers.HandleCesu8(transfer.Torrent.Path), `/`)
 			if string(transfer.Fastresume.QbtSavePath[len(transfer.Fastresume.QbtSavePath)-1]) != `/` {
 				transfer.Fastresume.QbtSavePath += `/`
 			}
 		}
 	}
 
-	for _, pattern := range transfer.Replace {
-		transfer.Fastresume.QbtSavePath = strings.ReplaceAll(transfer.Fastresume.QbtSavePath, pattern.From, pattern.To)
-		// replace mapped files if them are absolute paths
-		for mapIndex, mapPath := range transfer.Fastresume.MappedFiles {
-			if fileHelpers.IsAbs(mapPath) {
-				transfer.Fastresume.MappedFiles[mapIndex] = strings.ReplaceAll(mapPath, pattern.From, pattern.To)
-			}
-		}
-	}
+	transfer.Fastresume.QbtSavePath = ReplacePaths(transfer.Fastresume.QbtSavePath, transfer.Fastresume.MappedFiles, transfer.Replace)
 
 	transfer.Fastresume.SavePath = fileHelpers.Normalize(transfer.Fastresume.QbtSavePath, transfer.Opts.PathSeparator)
 	if transfer.Fastresume.QBtContentLayout == "Original" && !transfer.Magnet {
@@ -373,13 +683,34 @@ func (transfer *TransferStructure) HandleSavePaths() {
 	}
 }
 
+// ReplacePaths apply replace patterns one by one to save path and to mapped files that are absolute paths.
+// Mapped files are replaced in place
+func ReplacePaths(savePath string, mappedFiles []string, replaces []*replace.Replace) string {
+	for _, pattern := range replaces {
+		savePath = strings.ReplaceAll(savePath, pattern.From, pattern.To)
+		for mapIndex, mapPath := range mappedFiles {
+			if isAbsPath(mapPath) {
+				mappedFiles[mapIndex] = strings.ReplaceAll(mapPath, pattern.From, pattern.To)
+			}
+		}
+	}
+	return savePath
+}
+
+// isAbsPath check windows absolute paths and posix absolute paths, that come from linux clients
+func isAbsPath(filePath string) bool {
+	return fileHelpers.IsAbs(filePath) || strings.HasPrefix(filePath, "/")
+}
+
 // FindHighestIndexOfMappedFiles just helper for creating mappedfiles
 func (transfer *TransferStructure) FindHighestIndexOfMappedFiles() int64 {
-	if resumeItem := transfer.ResumeItem; resumeItem.Targets != nil {
-		lastElem := resumeItem.Targets[len(resumeItem.Targets)-1] // it must be like []interface{0, "path"}
-		return lastElem[0].(int64)
+	maxIndex := int64(-1)
+	for _, renamedFile := range transfer.Torrent.RenamedFiles {
+		if renamedFile.Index > maxIndex {
+			maxIndex = renamedFile.Index
+		}
 	}
-	return -1
+	return maxIndex
 }
 
 func CreateReplaces(replaces []string) []*replace.Replace {
@@ -1,17 +1,24 @@
 package transfer
 
 import (
+	"crypto/sha1"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/r3labs/diff/v2"
 	_ "github.com/r3labs/diff/v2"
 	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/internal/sources"
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+	"github.com/rumanzo/bt2qbt/pkg/magnetLinks"
 	"github.com/rumanzo/bt2qbt/pkg/qBittorrentStructures"
 	"github.com/rumanzo/bt2qbt/pkg/torrentStructures"
-	"github.com/rumanzo/bt2qbt/pkg/utorrentStructs"
 )
 
 func TestTransferStructure_HandleSavePaths(t *testing.T) {
@@ -28,7 +35,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "001 Test torrent with windows single nofolder (original) path without replaces",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test_torrent.txt`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test_torrent.txt`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: `test_torrent.txt`,
@@ -49,7 +56,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "002 Test torrent with windows single nofolder (original) path with replace",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test_torrent.txt`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test_torrent.txt`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent.txt",
@@ -70,7 +77,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "003 Test torrent with windows single nofolder (original) path without replaces. NameUTF8",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test_torrent.txt`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test_torrent.txt`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						NameUTF8: "test_torrent.txt",
@@ -91,12 +98,12 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "004 Test torrent with windows single nofolder (original) path without replaces. Renamed File",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\renamed_test_torrent.txt`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							0,
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 0,
+							Path:  "renamed_test_torrent.txt",
 						},
 					},
 				},
@@ -121,12 +128,12 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "005 Test torrent with windows single nofolder (original) path with replace to linux paths and linux sep. Renamed File",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\renamed_test_torrent.txt`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							0,
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 0,
+							Path:  "renamed_test_torrent.txt",
 						},
 					},
 				},
@@ -151,7 +158,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "006 Test torrent with windows folder (original) path without replaces",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test_torrent`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test_torrent`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent",
@@ -177,7 +184,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "007 Test torrent with windows folder (original) path with replace",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test_torrent`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test_torrent`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent",
@@ -203,7 +210,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "008 Test torrent with windows folder (original) path without replaces. NameUTF8",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test_torrent`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test_torrent`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						NameUTF8: "test_torrent",
@@ -229,12 +236,12 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "009 Test torrent with windows folder (original) path without replaces. Renamed File",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\test_torrent`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
 					},
 				},
@@ -268,12 +275,12 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "010 Test torrent with windows folder (original) path with replace to linux paths and linux sep. Renamed File",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\test_torrent`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
 					},
 				},
@@ -307,7 +314,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "011 Test torrent with windows folder (NoSubfolder) path without replaces",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent",
@@ -338,7 +345,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "012 Test torrent with windows folder (NoSubfolder) path with replace",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent",
@@ -369,7 +376,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "013 Test torrent with windows folder (NoSubfolder) path without replaces. NameUTF8",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						NameUTF8: "test_torrent",
@@ -400,12 +407,12 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "014 Test torrent with windows folder (NoSubfolder) path without replaces. Renamed File",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\test`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
 					},
 				},
@@ -439,12 +446,12 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "015 Test torrent with windows folder (NoSubfolder) path with replace to linux paths and linux sep. Renamed File",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\test`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
 					},
 				},
@@ -478,7 +485,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "016 Test torrent with windows folder (NoSubfolder) path without replaces. TorrentPaths UTF8",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent",
@@ -510,7 +517,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			mustFail: true,
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent.txt`},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test_torrent.txt`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test_torrent.txt`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: `test_torrent.txt`,
@@ -531,20 +538,20 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "018 Test torrent with windows folder (original) path without replaces. Moved files with absolute paths",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\test_torrent`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
-						[]interface{}{
-							int64(3),
-							`E:\somedir1\renamed_test_torrent2.txt`,
+						{
+							Index: 3,
+							Path:  `E:\somedir1\renamed_test_torrent2.txt`,
 						},
-						[]interface{}{
-							int64(4),
-							`F:\somedir\somedir4\renamed_test_torrent3.txt`,
+						{
+							Index: 4,
+							Path:  `F:\somedir\somedir4\renamed_test_torrent3.txt`,
 						},
 					},
 				},
@@ -582,20 +589,20 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "019 Test torrent with windows folder (original) path with replaces. Moved files with absolute paths",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\test_torrent`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
-						[]interface{}{
-							int64(3),
-							`E:\somedir1\renamed_test_torrent2.txt`,
+						{
+							Index: 3,
+							Path:  `E:\somedir1\renamed_test_torrent2.txt`,
 						},
-						[]interface{}{
-							int64(4),
-							`F:\somedir\somedir4\renamed_test_torrent3.txt`,
+						{
+							Index: 4,
+							Path:  `F:\somedir\somedir4\renamed_test_torrent3.txt`,
 						},
 					},
 				},
@@ -633,20 +640,20 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "020 Test torrent with windows folder (NoSubfolder) path without replaces. Moved files with absolute paths",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\test`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
-						[]interface{}{
-							int64(3),
-							`E:\somedir1\renamed_test_torrent2.txt`,
+						{
+							Index: 3,
+							Path:  `E:\somedir1\renamed_test_torrent2.txt`,
 						},
-						[]interface{}{
-							int64(4),
-							`F:\somedir\somedir4\renamed_test_torrent3.txt`,
+						{
+							Index: 4,
+							Path:  `F:\somedir\somedir4\renamed_test_torrent3.txt`,
 						},
 					},
 				},
@@ -684,20 +691,20 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "021 Test torrent with windows share folder (Original) path without replaces. Moved files with absolute paths. Windows share",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `\\torrents\test_torrent`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
-						[]interface{}{
-							int64(3),
-							`E:\somedir1\renamed_test_torrent2.txt`,
+						{
+							Index: 3,
+							Path:  `E:\somedir1\renamed_test_torrent2.txt`,
 						},
-						[]interface{}{
-							int64(4),
-							`\\somedir\somedir4\renamed_test_torrent3.txt`,
+						{
+							Index: 4,
+							Path:  `\\somedir\somedir4\renamed_test_torrent3.txt`,
 						},
 					},
 				},
@@ -735,20 +742,20 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "022 Test torrent with windows share folder (NoSubfolder) path without replaces. Moved files with absolute paths",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `\\torrents\test`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
-						[]interface{}{
-							int64(3),
-							`E:\somedir1\renamed_test_torrent2.txt`,
+						{
+							Index: 3,
+							Path:  `E:\somedir1\renamed_test_torrent2.txt`,
 						},
-						[]interface{}{
-							int64(4),
-							`\\somedir\somedir4\renamed_test_torrent3.txt`,
+						{
+							Index: 4,
+							Path:  `\\somedir\somedir4\renamed_test_torrent3.txt`,
 						},
 					},
 				},
@@ -786,20 +793,20 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "023 Test torrent with windows folder (Original) path without replaces. Absolute paths. Windows share Replace",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{Name: `test_torrent`},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `\\torrents\test_torrent`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
-						[]interface{}{
-							int64(3),
-							`E:\somedir1\renamed_test_torrent2.txt`,
+						{
+							Index: 3,
+							Path:  `E:\somedir1\renamed_test_torrent2.txt`,
 						},
-						[]interface{}{
-							int64(4),
-							`\\somedir\somedir4\renamed_test_torrent3.txt`,
+						{
+							Index: 4,
+							Path:  `\\somedir\somedir4\renamed_test_torrent3.txt`,
 						},
 					},
 				},
@@ -837,20 +844,20 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "024 Test torrent with windows folder (NoSubfolder) path without replaces. Absolute paths. Windows share Replace",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `\\torrents\test`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"renamed_test_torrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "renamed_test_torrent.txt",
 						},
-						[]interface{}{
-							int64(3),
-							`E:\somedir1\renamed_test_torrent2.txt`,
+						{
+							Index: 3,
+							Path:  `E:\somedir1\renamed_test_torrent2.txt`,
 						},
-						[]interface{}{
-							int64(4),
-							`\\somedir\somedir4\renamed_test_torrent3.txt`,
+						{
+							Index: 4,
+							Path:  `\\somedir\somedir4\renamed_test_torrent3.txt`,
 						},
 					},
 				},
@@ -888,7 +895,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "025 Test magnet link downloads",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\test`,
 				},
 				TorrentFile: &torrentStructures.Torrent{
@@ -910,7 +917,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "026 Test torrent with signle file torrent and savepath in rootdirectory",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\test.txt`,
 				},
 				TorrentFile: &torrentStructures.Torrent{
@@ -933,7 +940,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "027 Test torrent with multi file torrent and savepath in rootdirectory",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\test_torrent`,
 				},
 				TorrentFile: &torrentStructures.Torrent{
@@ -963,20 +970,20 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "028 Test torrent with windows folder (original) path without replaces. Emoji utf8 in file and name",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: "D:\\torrents\\test_torrent \xf0\x9f\x86\x95",
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(0),
-							"E:\\somedir1 \xf0\x9f\x86\x95\\\xf0\x9f\x86\x95 renamed_test_torrent2.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 0,
+							Path:  "E:\\somedir1 \xf0\x9f\x86\x95\\\xf0\x9f\x86\x95 renamed_test_torrent2.txt",
 						},
-						[]interface{}{
-							int64(1),
-							"\\\\somedir\\somedir4 \xf0\x9f\x86\x95\\\xf0\x9f\x86\x95 renamed_test_torrent3.txt",
+						{
+							Index: 1,
+							Path:  "\\\\somedir\\somedir4 \xf0\x9f\x86\x95\\\xf0\x9f\x86\x95 renamed_test_torrent3.txt",
 						},
-						[]interface{}{
-							int64(2),
-							"renamed \xf0\x9f\x86\x95 file1.txt",
+						{
+							Index: 2,
+							Path:  "renamed \xf0\x9f\x86\x95 file1.txt",
 						},
 					},
 				},
@@ -1010,7 +1017,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "029 Test torrent with windows folder (NoSubfolder) with renamed files. Emoji utf8 in file and torrent name",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: "D:\\torrents\\renamed test_torrent \xf0\x9f\x86\x95"},
+				Torrent:    &sources.Torrent{Path: "D:\\torrents\\renamed test_torrent \xf0\x9f\x86\x95"},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent \xf0\x9f\x86\x95",
@@ -1041,20 +1048,20 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "030 Test torrent with windows folder (original) path with renamed files. Emoji cesu8 in file and name",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: "D:\\torrents\\test_torrent \xed\xa0\xbc\xed\xb6\x95",
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(0),
-							"E:\\somedir1 \xed\xa0\xbc\xed\xb6\x95\\\xed\xa0\xbc\xed\xb6\x95 renamed_test_torrent2.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 0,
+							Path:  "E:\\somedir1 \xed\xa0\xbc\xed\xb6\x95\\\xed\xa0\xbc\xed\xb6\x95 renamed_test_torrent2.txt",
 						},
-						[]interface{}{
-							int64(1),
-							"\\\\somedir\\somedir4 \xed\xa0\xbc\xed\xb6\x95\\\xed\xa0\xbc\xed\xb6\x95 renamed_test_torrent3.txt",
+						{
+							Index: 1,
+							Path:  "\\\\somedir\\somedir4 \xed\xa0\xbc\xed\xb6\x95\\\xed\xa0\xbc\xed\xb6\x95 renamed_test_torrent3.txt",
 						},
-						[]interface{}{
-							int64(2),
-							"renamed \xed\xa0\xbc\xed\xb6\x95 file1.txt",
+						{
+							Index: 2,
+							Path:  "renamed \xed\xa0\xbc\xed\xb6\x95 file1.txt",
 						},
 					},
 				},
@@ -1088,7 +1095,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "031 Test torrent with windows folder (NoSubfolder) with renamed files. Emoji cesu8 in file and torrent name",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: "D:\\torrents\\renamed test_torrent \xed\xa0\xbc\xed\xb6\x95"},
+				Torrent:    &sources.Torrent{Path: "D:\\torrents\\renamed test_torrent \xed\xa0\xbc\xed\xb6\x95"},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent \xed\xa0\xbc\xed\xb6\x95",
@@ -1119,7 +1126,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "032 Test torrent with windows folder without renamed files. Emoji cesu8 in file and torrent name",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: "D:\\torrents\\test_torrent"},
+				Torrent:    &sources.Torrent{Path: "D:\\torrents\\test_torrent"},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent",
@@ -1154,20 +1161,20 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "033 Test torrent with windows folder with renamed files. Emoji cesu8 in file and torrent name",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: "D:\\torrents\\test_torrent",
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(0),
-							"E:\\somedir1 \xed\xa0\xbc\xed\xb6\x95\\\xed\xa0\xbc\xed\xb6\x95 renamed_test_torrent2.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 0,
+							Path:  "E:\\somedir1 \xed\xa0\xbc\xed\xb6\x95\\\xed\xa0\xbc\xed\xb6\x95 renamed_test_torrent2.txt",
 						},
-						[]interface{}{
-							int64(1),
-							"\\\\somedir\\somedir4 \xed\xa0\xbc\xed\xb6\x95\\\xed\xa0\xbc\xed\xb6\x95 renamed_test_torrent3.txt",
+						{
+							Index: 1,
+							Path:  "\\\\somedir\\somedir4 \xed\xa0\xbc\xed\xb6\x95\\\xed\xa0\xbc\xed\xb6\x95 renamed_test_torrent3.txt",
 						},
-						[]interface{}{
-							int64(3),
-							"renamed \xed\xa0\xbc\xed\xb6\x95 file1.txt",
+						{
+							Index: 3,
+							Path:  "renamed \xed\xa0\xbc\xed\xb6\x95 file1.txt",
 						},
 					},
 				},
@@ -1207,7 +1214,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "034 Test torrent with windows single nofolder (original) path without replaces. Cesu8 emoji",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: "D:\\torrents\\test_torrent \xed\xa0\xbc\xed\xb6\x95.txt"},
+				Torrent:    &sources.Torrent{Path: "D:\\torrents\\test_torrent \xed\xa0\xbc\xed\xb6\x95.txt"},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent \xed\xa0\xbc\xed\xb6\x95.txt",
@@ -1231,7 +1238,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "035 Test torrent with windows folder (NoSubfolder) with special symbols.",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\renamed test_torrent`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\renamed test_torrent`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent",
@@ -1261,7 +1268,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "035 Test torrent with windows folder (NoSubfolder) with prohibited symbols.",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\renamed test_torrent`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\renamed test_torrent`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent",
@@ -1291,7 +1298,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "036 Test torrent with windows single nofolder (original) path without replaces. With prohibited symbols",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test_torrent.txt`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test_torrent.txt`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: `test|torrent.txt`,
@@ -1315,7 +1322,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "037 Test torrent with windows folder (NoSubfolder) with prohibited symbols. *nix path separator",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\renamed test_torrent`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\renamed test_torrent`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: "test_torrent",
@@ -1344,7 +1351,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "038 Test torrent with windows single nofolder (original) path without replaces. With prohibited symbols. *nix path separator",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Path: `D:\torrents\test_torrent.txt`},
+				Torrent:    &sources.Torrent{Path: `D:\torrents\test_torrent.txt`},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Name: `test|torrent.txt`,
@@ -1368,12 +1375,12 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "039 Test torrent with windows single nofolder (original) path with renamed file. v2 torrent",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\testtorrent.txt`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(0),
-							"testtorrent.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 0,
+							Path:  "testtorrent.txt",
 						},
 					},
 				},
@@ -1405,12 +1412,12 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "040 Test torrent with windows folder (original) path with renamed file. v2 torrent",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\torrents\testtorrent`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(1),
-							"testtorrent2.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 1,
+							Path:  "testtorrent2.txt",
 						},
 					},
 				},
@@ -1452,7 +1459,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "041 Test torrent with multi file torrent (NoSubfolder) with prohibited symbols in name",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\test_torrent_test`,
 				},
 				TorrentFile: &torrentStructures.Torrent{
@@ -1489,12 +1496,12 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "042 Test torrent with multi file torrent with renames (NoSubFolder) with prohibited symbols in name",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\test_torrent_test`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"file0_file.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "file0_file.txt",
 						},
 					},
 				},
@@ -1532,7 +1539,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "043 Test torrent with multi file torrent (NoSubfolder) with space at the end of torrent name",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\test_torrent_`,
 				},
 				TorrentFile: &torrentStructures.Torrent{
@@ -1569,7 +1576,7 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "044 Test torrent with multi file torrent (NoSubfolder) with space at the end directory",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\test_torrent_`,
 				},
 				TorrentFile: &torrentStructures.Torrent{
@@ -1606,12 +1613,12 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "045 Test torrent with multi file torrent (NoSubfolder) with space at the end directory with renamed files",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
+				Torrent: &sources.Torrent{
 					Path: `D:\test_torrent_`,
-					Targets: [][]interface{}{
-						[]interface{}{
-							int64(2),
-							"file2_renamed.txt",
+					RenamedFiles: []sources.RenamedFile{
+						{
+							Index: 2,
+							Path:  "file2_renamed.txt",
 						},
 					},
 				},
@@ -1649,9 +1656,9 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "046 Test torrent with multi file torrent with transfer to NoSubfolder cesu8 symbols in names",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Path:    "D:\\test_slashes_emoji \xed\xa0\xbc\xed\xb6\x95_",
-					Caption: "test_slashes_emoji \xed\xa0\xbc\xed\xb6\x95_",
+				Torrent: &sources.Torrent{
+					Path: "D:\\test_slashes_emoji \xed\xa0\xbc\xed\xb6\x95_",
+					Name: "test_slashes_emoji \xed\xa0\xbc\xed\xb6\x95_",
 				},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
@@ -1690,8 +1697,8 @@ func TestTransferStructure_HandleSavePaths(t *testing.T) {
 			name: "047 Test torrent with multi file torrent with transfer to NoSubfolder RLF LRF symbols in torrent name and files",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Path:    "D:\\test files \u200e\u200f",
+				Torrent: &sources.Torrent{
+					Path: "D:\\test files \u200e\u200f",
 				},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
@@ -1924,6 +1931,45 @@ func TestTransferStructure_HandlePieces(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "006 have bitfield",
+			newTransferStructure: &TransferStructure{
+				NumPieces: 10,
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
+					Unfinished: new([]interface{}),
+				},
+				Torrent: &sources.Torrent{
+					Pieces: []byte{0xa5, 0x40},
+				},
+				TorrentFile: &torrentStructures.Torrent{
+					Info: &torrentStructures.TorrentInfo{},
+				},
+			},
+			expected: &TransferStructure{
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
+					Unfinished: new([]interface{}),
+					Pieces:     []byte{1, 0, 1, 0, 0, 1, 0, 1, 0, 1},
+				},
+			},
+		},
+		{
+			name: "007 have bitfield with wrong length fallback to priorities",
+			newTransferStructure: &TransferStructure{
+				NumPieces:  3,
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
+				Torrent: &sources.Torrent{
+					Pieces: []byte{0x00, 0x00},
+				},
+				TorrentFile: &torrentStructures.Torrent{
+					Info: &torrentStructures.TorrentInfo{},
+				},
+			},
+			expected: &TransferStructure{
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
+					Pieces: []byte{1, 1, 1},
+				},
+			},
+		},
 	}
 	for _, testCase := range cases {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -1943,6 +1989,7 @@ func TestTransferStructure_HandlePieces(t *testing.T) {
 }
 
 func TestTransferStructure_HandlePriority(t *testing.T) {
+	skip, normal, high := sources.PrioritySkip, sources.PriorityNormal, sources.PriorityHigh
 
 	type HandlePriorityCase struct {
 		name                 string
@@ -1956,8 +2003,8 @@ func TestTransferStructure_HandlePriority(t *testing.T) {
 			newTransferStructure: &TransferStructure{
 				Fastresume:  &qBittorrentStructures.QBittorrentFastresume{FilePriority: []int64{}},
 				TorrentFile: &torrentStructures.Torrent{Info: &torrentStructures.TorrentInfo{}},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Prio: []byte{},
+				Torrent: &sources.Torrent{
+					FilePriorities: []sources.Priority{},
 				},
 			},
 			mustFail: true,
@@ -1968,23 +2015,14 @@ func TestTransferStructure_HandlePriority(t *testing.T) {
 			newTransferStructure: &TransferStructure{
 				Fastresume:  &qBittorrentStructures.QBittorrentFastresume{FilePriority: []int64{}},
 				TorrentFile: &torrentStructures.Torrent{Info: &torrentStructures.TorrentInfo{}},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Prio: []byte{
-						byte(0),
-						byte(128),
-						byte(2),
-						byte(5),
-						byte(8),
-						byte(9),
-						byte(15),
-						byte(127), // unexpected
-					},
+				Torrent: &sources.Torrent{
+					FilePriorities: []sources.Priority{skip, skip, normal, normal, normal, high, high, skip},
 				},
 			},
 			expected: []int64{0, 0, 1, 1, 1, 6, 6, 0},
 		},
 		{
-			name: "002 check priotiry for v2 torrents",
+			name: "003 check priotiry for v2 torrents",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{FilePriority: []int64{}},
 				TorrentFile: &torrentStructures.Torrent{
@@ -1992,25 +2030,8 @@ func TestTransferStructure_HandlePriority(t *testing.T) {
 						FileTree: map[string]interface{}{},
 					},
 				},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Prio: []byte{
-						byte(0),
-						byte(128),
-						byte(128),
-						byte(128),
-						byte(2),
-						byte(128),
-						byte(5),
-						byte(128),
-						byte(8),
-						byte(128),
-						byte(9),
-						byte(128),
-						byte(15),
-						byte(128),
-						byte(127), // unexpected
-						byte(128),
-					},
+				Torrent: &sources.Torrent{
+					FilePriorities: []sources.Priority{skip, skip, normal, normal, normal, high, high, skip},
 				},
 			},
 			expected: []int64{0, 0, 1, 1, 1, 6, 6, 0},
@@ -2036,31 +2057,30 @@ func TestTransferStructure_HandlePriority(t *testing.T) {
 func TestTransferStructure_HandleTrackers(t *testing.T) {
 	transferStructure := TransferStructure{
 		Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-		ResumeItem: &utorrentStructs.ResumeItem{
-			Trackers: []interface{}{
-				"http://test1.org",
-				"udp://test1.org",
-				"http://test1.local",
-				"udp://test1.local",
-				[]interface{}{
+		Torrent: &sources.Torrent{
+			Trackers: [][]string{
+				{
+					"http://test1.org",
+					"udp://test1.org",
+					"http://test1.local",
+					"udp://test1.local",
 					"http://test2.org:80",
 					"udp://test2.org:8080",
 					"http://test2.local:80",
 					"udp://test2.local:8080",
-					[]interface{}{
-						"http://test3.org:80/somepath",
-						"udp://test3.org:8080/somepath",
-						"http://test3.local:80/somepath",
-						"udp://test3.local:8080/somepath",
-					},
 				},
-				[]interface{}{
-					[]interface{}{
-						"http://test4.org:80/",
-						"udp://test4.org:8080/",
-						"http://test4.local:80/",
-						"udp://test4.local:8080/",
-					},
+				{
+					"http://test3.org:80/somepath",
+					"udp://test3.org:8080/somepath",
+					"http://test3.local:80/somepath",
+					"udp://test3.local:8080/somepath",
+					"http://test4.org:80/",
+					"udp://test4.org:8080/",
+					"http://test4.local:80/",
+					"udp://test4.local:8080/",
+				},
+				{
+					"http://test5.local",
 				},
 			},
 		},
@@ -2068,13 +2088,15 @@ func TestTransferStructure_HandleTrackers(t *testing.T) {
 	expect := [][]string{
 		[]string{
 			"http://test1.org", "udp://test1.org",
-			"http://test2.org:80", "udp://test2.org:8080",
+			"http://test2.org:80", "udp://test2.org:8080"},
+		[]string{
 			"http://test3.org:80/somepath", "udp://test3.org:8080/somepath",
 			"http://test4.org:80/", "udp://test4.org:8080/"},
 		[]string{"http://test1.local", "udp://test1.local",
 			"http://test2.local:80", "udp://test2.local:8080",
 			"http://test3.local:80/somepath", "udp://test3.local:8080/somepath",
-			"http://test4.local:80/", "udp://test4.local:8080/"},
+			"http://test4.local:80/", "udp://test4.local:8080/",
+			"http://test5.local"},
 	}
 	transferStructure.HandleTrackers()
 	if !reflect.DeepEqual(transferStructure.Fastresume.Trackers, expect) {
@@ -2094,7 +2116,7 @@ func TestTransferStructure_HandleState(t *testing.T) {
 			name: "001 Mustfail",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{},
+				Torrent:    &sources.Torrent{},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Files: []*torrentStructures.TorrentFile{
@@ -2114,7 +2136,7 @@ func TestTransferStructure_HandleState(t *testing.T) {
 			name: "002 stopped resume",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Started: 0},
+				Torrent:    &sources.Torrent{Started: false},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
 						Files: []*torrentStructures.TorrentFile{
@@ -2133,7 +2155,7 @@ func TestTransferStructure_HandleState(t *testing.T) {
 			name: "003 started resume",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{Started: 1},
+				Torrent:    &sources.Torrent{Started: true},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{},
 				},
@@ -2146,8 +2168,8 @@ func TestTransferStructure_HandleState(t *testing.T) {
 			name: "004 started resume with full downloaded files",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Started: 1,
+				Torrent: &sources.Torrent{
+					Started: true,
 				},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
@@ -2173,8 +2195,8 @@ func TestTransferStructure_HandleState(t *testing.T) {
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
 					FilePriority: []int64{1, 0, 1, 1, 1, 6, 6},
 				},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Started: 1,
+				Torrent: &sources.Torrent{
+					Started: true,
 				},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
@@ -2204,8 +2226,8 @@ func TestTransferStructure_HandleState(t *testing.T) {
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
 					FilePriority: []int64{1, 0, 1, 1, 1, 6, 6},
 				},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Started: 1,
+				Torrent: &sources.Torrent{
+					Started: true,
 				},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
@@ -2235,8 +2257,8 @@ func TestTransferStructure_HandleState(t *testing.T) {
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
 					FilePriority: []int64{1, 0, 1, 1, 1, 6, 6},
 				},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Started: 1,
+				Torrent: &sources.Torrent{
+					Started: true,
 				},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
@@ -2266,8 +2288,8 @@ func TestTransferStructure_HandleState(t *testing.T) {
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
 					FilePriority: []int64{1, 0, 1, 1, 1, 6, 6},
 				},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Started: 1,
+				Torrent: &sources.Torrent{
+					Started: true,
 				},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{
@@ -2295,8 +2317,8 @@ func TestTransferStructure_HandleState(t *testing.T) {
 			name: "009 started resume without files",
 			newTransferStructure: &TransferStructure{
 				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
-				ResumeItem: &utorrentStructs.ResumeItem{
-					Started: 0,
+				Torrent: &sources.Torrent{
+					Started: false,
 				},
 				TorrentFile: &torrentStructures.Torrent{
 					Info: &torrentStructures.TorrentInfo{},
@@ -2324,3 +2346,489 @@ func TestTransferStructure_HandleState(t *testing.T) {
 	}
 
 }
+
+func TestTransferStructure_GetFilePaths(t *testing.T) {
+	type GetFilePathsCase struct {
+		name                 string
+		mustFail             bool
+		newTransferStructure *TransferStructure
+		expected             []string
+	}
+	cases := []GetFilePathsCase{
+		{
+			name: "001 single file",
+			newTransferStructure: &TransferStructure{
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{SavePath: `/mnt/torrents/`, QBtContentLayout: "Original"},
+				TorrentFile: &torrentStructures.Torrent{
+					Info: &torrentStructures.TorrentInfo{Name: "file.txt"},
+				},
+			},
+			expected: []string{`/mnt/torrents/file.txt`},
+		},
+		{
+			name: "002 single renamed file",
+			newTransferStructure: &TransferStructure{
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
+					SavePath:         `/mnt/torrents/`,
+					QBtContentLayout: "Original",
+					MappedFiles:      []string{"renamed.txt"},
+				},
+				TorrentFile: &torrentStructures.Torrent{
+					Info: &torrentStructures.TorrentInfo{Name: "file.txt"},
+				},
+			},
+			expected: []string{`/mnt/torrents/renamed.txt`},
+		},
+		{
+			name: "003 original folder with absolute and relative mapped files",
+			newTransferStructure: &TransferStructure{
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
+					SavePath:         `/mnt/torrents/`,
+					Name:             "torrent",
+					QBtContentLayout: "Original",
+					MappedFiles:      []string{"", `torrent/dir/renamed.txt`, `/mnt/other/file2.txt`},
+				},
+				TorrentFile: &torrentStructures.Torrent{
+					Info: &torrentStructures.TorrentInfo{
+						Name: "torrent",
+						Files: []*torrentStructures.TorrentFile{
+							&torrentStructures.TorrentFile{Path: []string{"file0.txt"}},
+							&torrentStructures.TorrentFile{Path: []string{"dir", "file1.txt"}},
+							&torrentStructures.TorrentFile{Path: []string{"file2.txt"}},
+						},
+					},
+				},
+			},
+			expected: []string{`/mnt/torrents/torrent/file0.txt`, `/mnt/torrents/torrent/dir/renamed.txt`, `/mnt/other/file2.txt`},
+		},
+		{
+			name: "004 nosubfolder",
+			newTransferStructure: &TransferStructure{
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
+					SavePath:         `/mnt/torrents/renamed_torrent`,
+					Name:             "torrent",
+					QBtContentLayout: "NoSubfolder",
+					MappedFiles:      []string{`file0.txt`, `dir/file1.txt`},
+				},
+				TorrentFile: &torrentStructures.Torrent{
+					Info: &torrentStructures.TorrentInfo{
+						Name: "torrent",
+						Files: []*torrentStructures.TorrentFile{
+							&torrentStructures.TorrentFile{Path: []string{"file0.txt"}},
+							&torrentStructures.TorrentFile{Path: []string{"dir", "file1.txt"}},
+						},
+					},
+				},
+			},
+			expected: []string{`/mnt/torrents/renamed_torrent/file0.txt`, `/mnt/torrents/renamed_torrent/dir/file1.txt`},
+		},
+		{
+			name: "005 nosubfolder mustFail",
+			newTransferStructure: &TransferStructure{
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
+					SavePath:         `/mnt/torrents/renamed_torrent`,
+					Name:             "torrent",
+					QBtContentLayout: "NoSubfolder",
+					MappedFiles:      []string{`file0.txt`, `dir/file1.txt`},
+				},
+				TorrentFile: &torrentStructures.Torrent{
+					Info: &torrentStructures.TorrentInfo{
+						Name: "torrent",
+						Files: []*torrentStructures.TorrentFile{
+							&torrentStructures.TorrentFile{Path: []string{"file0.txt"}},
+							&torrentStructures.TorrentFile{Path: []string{"dir", "file1.txt"}},
+						},
+					},
+				},
+			},
+			mustFail: true,
+			expected: []string{`/mnt/torrents/renamed_torrent/torrent/file0.txt`, `/mnt/torrents/renamed_torrent/torrent/dir/file1.txt`},
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			filePaths := testCase.newTransferStructure.GetFilePaths()
+			equal := reflect.DeepEqual(testCase.expected, filePaths)
+			if !equal && !testCase.mustFail {
+				t.Fatalf("Unexpected error: paths aren't equal:\n Got: %#v\n Expect %#v\n", filePaths, testCase.expected)
+			} else if equal && testCase.mustFail {
+				t.Fatalf("Unexpected error: paths are equal, but they shouldn't\n Got: %#v\n", filePaths)
+			}
+		})
+	}
+}
+
+func TestTransferStructure_GetUnfinished(t *testing.T) {
+	type GetUnfinishedCase struct {
+		name                 string
+		mustFail             bool
+		newTransferStructure *TransferStructure
+		expected             *[]interface{}
+	}
+	cases := []GetUnfinishedCase{
+		{
+			name: "001 without blocks",
+			newTransferStructure: &TransferStructure{
+				NumPieces:   4,
+				Torrent:     &sources.Torrent{},
+				TorrentFile: &torrentStructures.Torrent{Info: &torrentStructures.TorrentInfo{PieceLength: 65536}},
+			},
+			expected: &[]interface{}{},
+		},
+		{
+			name: "002 blocks of not completed pieces",
+			newTransferStructure: &TransferStructure{
+				NumPieces: 4,
+				Torrent: &sources.Torrent{
+					Pieces: []byte{0x80},
+					Unfinished: []sources.UnfinishedPiece{
+						{Piece: 0, Blocks: []int64{0, 1, 2, 3}},
+						{Piece: 2, Blocks: []int64{0, 2}},
+						{Piece: 9, Blocks: []int64{0}},
+					},
+				},
+				TorrentFile: &torrentStructures.Torrent{Info: &torrentStructures.TorrentInfo{PieceLength: 65536}},
+			},
+			expected: &[]interface{}{
+				map[string]interface{}{"piece": int64(2), "bitmask": "\x05"},
+			},
+		},
+		{
+			name: "003 blocks out of piece",
+			newTransferStructure: &TransferStructure{
+				NumPieces: 4,
+				Torrent: &sources.Torrent{
+					Unfinished: []sources.UnfinishedPiece{{Piece: 1, Blocks: []int64{4, -1}}},
+				},
+				TorrentFile: &torrentStructures.Torrent{Info: &torrentStructures.TorrentInfo{PieceLength: 65536}},
+			},
+			expected: &[]interface{}{
+				map[string]interface{}{"piece": int64(1), "bitmask": "\x00"},
+			},
+		},
+		{
+			name: "004 mustFail",
+			newTransferStructure: &TransferStructure{
+				NumPieces: 4,
+				Torrent: &sources.Torrent{
+					Unfinished: []sources.UnfinishedPiece{{Piece: 1, Blocks: []int64{0, 1, 2, 3}}},
+				},
+				TorrentFile: &torrentStructures.Torrent{Info: &torrentStructures.TorrentInfo{PieceLength: 65536}},
+			},
+			mustFail: true,
+			expected: &[]interface{}{},
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			unfinished := testCase.newTransferStructure.GetUnfinished()
+			equal := reflect.DeepEqual(testCase.expected, unfinished)
+			if !equal && !testCase.mustFail {
+				t.Fatalf("Unexpected error: unfinished aren't equal:\n Got: %#v\n Expect %#v\n", unfinished, testCase.expected)
+			} else if equal && testCase.mustFail {
+				t.Fatalf("Unexpected error: unfinished are equal, but they shouldn't\n Got: %#v\n", unfinished)
+			}
+		})
+	}
+}
+
+func TestTransferStructure_HandleMagnet(t *testing.T) {
+	magnetLink, err := magnetLinks.Parse("magnet:?xt=urn:btih:d95d90a72e0a53e88e6f73a3905ca4fb5973472e&dn=test" +
+		"&tr=http%3A%2F%2Ftest1.org&tr=http%3A%2F%2Ftest2.org&ws=http%3A%2F%2Fseed.org&so=1")
+	if err != nil {
+		t.Fatalf("Can't parse magnet link: %v", err)
+	}
+	transferStructure := TransferStructure{
+		Fastresume: &qBittorrentStructures.QBittorrentFastresume{
+			Trackers: [][]string{{"http://test1.org"}},
+		},
+		Magnet:     true,
+		MagnetLink: magnetLink,
+	}
+	transferStructure.HandleMagnet()
+	expected := &qBittorrentStructures.QBittorrentFastresume{
+		InfoHash:     "\xd9\x5d\x90\xa7\x2e\x0a\x53\xe8\x8e\x6f\x73\xa3\x90\x5c\xa4\xfb\x59\x73\x47\x2e",
+		Name:         "test",
+		Trackers:     [][]string{{"http://test1.org"}, {"http://test2.org"}},
+		UrlList:      []string{"http://seed.org"},
+		FilePriority: []int64{0, 1},
+	}
+	if !reflect.DeepEqual(transferStructure.Fastresume, expected) {
+		changes, err := diff.Diff(transferStructure.Fastresume, expected, diff.DiscardComplexOrigin())
+		if err != nil {
+			t.Error(err.Error())
+		}
+		t.Fatalf("Unexpected error: structures aren't equal:\n Diff: %v\n", spew.Sdump(changes))
+	}
+	if hash := transferStructure.GetHash(); hash != "d95d90a72e0a53e88e6f73a3905ca4fb5973472e" {
+		t.Fatalf("Unexpected hash %v", hash)
+	}
+}
+
+func TestTransferStructure_HandleInfoHash(t *testing.T) {
+	type HandleInfoHashCase struct {
+		name           string
+		torrentPath    string
+		fastresumePath string
+		expectedHash   string
+	}
+	cases := []HandleInfoHashCase{
+		{
+			name:           "001 testdir v1",
+			torrentPath:    "../../test/data/testdir_v1.torrent",
+			fastresumePath: "../../test/data/testdir_v1.fastresume",
+			expectedHash:   "3456bac107634970b022677c6bfaa584065e0917",
+		},
+		{
+			name:           "002 testdir v2",
+			torrentPath:    "../../test/data/testdir_v2.torrent",
+			fastresumePath: "../../test/data/testdir_v2.fastresume",
+			expectedHash:   "b541be18ee9829e1cc2a292c8a0944e8072e8d1d",
+		},
+		{
+			name:           "003 testdir hybrid",
+			torrentPath:    "../../test/data/testdir_hybrid.torrent",
+			fastresumePath: "../../test/data/testdir_hybrid.fastresume",
+			expectedHash:   "31155b796be16d4015a59cd9f8cbca7072520c1c",
+		},
+		{
+			name:           "004 single v1",
+			torrentPath:    "../../test/data/testfile1_single_v1.torrent",
+			fastresumePath: "../../test/data/testfile1_single_v1.fastresume",
+			expectedHash:   "d95d90a72e0a53e88e6f73a3905ca4fb5973472e",
+		},
+		{
+			name:           "005 single v2",
+			torrentPath:    "../../test/data/testfile1_single_v2.torrent",
+			fastresumePath: "../../test/data/testfile1_single_v2.fastresume",
+			expectedHash:   "155fbbf17a04a3001618a4872e05d666c315c993",
+		},
+		{
+			name:           "006 single hybrid",
+			torrentPath:    "../../test/data/testfile1_single_hybrid.torrent",
+			fastresumePath: "../../test/data/testfile1_single_hybrid.fastresume",
+			expectedHash:   "8c03b2a5ecd70ce2bc6ffc6a92fff72d44540eb1",
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			transferStructure := CreateEmptyNewTransferStructure()
+			transferStructure.TorrentFilePath = testCase.torrentPath
+			if err := transferStructure.LoadTorrentFile(); err != nil {
+				t.Fatalf("Can't decode torrent file: %v", err)
+			}
+			expected := &qBittorrentStructures.QBittorrentFastresume{}
+			if err := helpers.DecodeTorrentFile(testCase.fastresumePath, expected); err != nil {
+				t.Fatalf("Can't decode fastresume file: %v", err)
+			}
+			transferStructure.HandleInfoHash()
+			if len(transferStructure.Warnings) != 0 {
+				t.Fatalf("Unexpected warnings: %v", transferStructure.Warnings)
+			}
+			if transferStructure.Fastresume.InfoHash != expected.InfoHash {
+				t.Fatalf("Unexpected info hash:\n Got: %x\n Expect %x\n", transferStructure.Fastresume.InfoHash, expected.InfoHash)
+			}
+			// qBittorrent writes zero v2 info hash for v1 torrents
+			if transferStructure.TorrentFile.IsV2OrHybryd() && transferStructure.Fastresume.InfoHash2 != expected.InfoHash2 {
+				t.Fatalf("Unexpected info hash2:\n Got: %x\n Expect %x\n", transferStructure.Fastresume.InfoHash2, expected.InfoHash2)
+			}
+			if hash := transferStructure.GetHash(); hash != testCase.expectedHash {
+				t.Fatalf("Unexpected hash:\n Got: %v\n Expect %v\n", hash, testCase.expectedHash)
+			}
+		})
+	}
+}
+
+func TestTransferStructure_HandleInfoHashNonCanonical(t *testing.T) {
+	// keys of info dictionary aren't sorted, so decode and encode it again gives another hash
+	info := "d4:name4:test12:piece lengthi16384e6:lengthi1e6:pieces20:" + strings.Repeat("a", 20) + "e"
+	dir := t.TempDir()
+	torrentPath := filepath.Join(dir, "test.torrent")
+	if err := os.WriteFile(torrentPath, []byte("d8:announce3:url4:info"+info+"e"), 0644); err != nil {
+		t.Fatalf("Can't write torrent file: %v", err)
+	}
+	expectedHash := sha1.Sum([]byte(info))
+
+	transferStructure := CreateEmptyNewTransferStructure()
+	transferStructure.TorrentFilePath = torrentPath
+	transferStructure.Torrent = &sources.Torrent{InfoHash: string(expectedHash[:])}
+	if err := transferStructure.LoadTorrentFile(); err != nil {
+		t.Fatalf("Can't decode torrent file: %v", err)
+	}
+	if string(transferStructure.TorrentInfoRaw) != info {
+		t.Fatalf("Unexpected raw info:\n Got: %v\n Expect %v\n", string(transferStructure.TorrentInfoRaw), info)
+	}
+	transferStructure.HandleInfoHash()
+	if transferStructure.Fastresume.InfoHash != string(expectedHash[:]) {
+		t.Fatalf("Unexpected info hash:\n Got: %x\n Expect %x\n", transferStructure.Fastresume.InfoHash, expectedHash)
+	}
+	if len(transferStructure.Warnings) != 0 {
+		t.Fatalf("Unexpected warnings: %v", transferStructure.Warnings)
+	}
+
+	transferStructure = CreateEmptyNewTransferStructure()
+	transferStructure.TorrentFilePath = torrentPath
+	transferStructure.Torrent = &sources.Torrent{InfoHash: strings.Repeat("b", 20)}
+	if err := transferStructure.LoadTorrentFile(); err != nil {
+		t.Fatalf("Can't decode torrent file: %v", err)
+	}
+	transferStructure.HandleInfoHash()
+	if len(transferStructure.Warnings) != 1 {
+		t.Fatalf("Expected warning about info hash mismatch, got: %v", transferStructure.Warnings)
+	}
+}
+
+func TestTransferStructure_HandleLimits(t *testing.T) {
+	type HandleLimitsCase struct {
+		name     string
+		torrent  *sources.Torrent
+		expected *qBittorrentStructures.QBittorrentFastresume
+	}
+	cases := []HandleLimitsCase{
+		{
+			name:    "001 default settings",
+			torrent: &sources.Torrent{},
+			expected: &qBittorrentStructures.QBittorrentFastresume{
+				DownloadRateLimit:   -1,
+				MaxUploads:          100,
+				QbtRatioLimit:       -2000,
+				QbtSeedingTimeLimit: -2,
+			},
+		},
+		{
+			name: "002 tuned torrent",
+			torrent: &sources.Torrent{
+				DisableDHT:    true,
+				DisablePEX:    true,
+				DownloadLimit: 204800,
+				SeedLimits:    &sources.SeedLimits{Ratio: 1.5, SeedingTime: 5430},
+				SuperSeeding:  true,
+				UploadLimit:   102400,
+				UploadSlots:   4,
+			},
+			expected: &qBittorrentStructures.QBittorrentFastresume{
+				DisableDht:          1,
+				DisablePex:          1,
+				DownloadRateLimit:   204800,
+				MaxUploads:          4,
+				QbtRatioLimit:       1500,
+				QbtSeedingTimeLimit: 91,
+				SuperSeeding:        1,
+				UploadRateLimit:     102400,
+			},
+		},
+		{
+			name: "003 override without limits",
+			torrent: &sources.Torrent{
+				SeedLimits: &sources.SeedLimits{},
+			},
+			expected: &qBittorrentStructures.QBittorrentFastresume{
+				DownloadRateLimit:   -1,
+				MaxUploads:          100,
+				QbtRatioLimit:       -1000,
+				QbtSeedingTimeLimit: -1,
+			},
+		},
+		{
+			name: "004 override with ratio only",
+			torrent: &sources.Torrent{
+				SeedLimits: &sources.SeedLimits{Ratio: 2},
+			},
+			expected: &qBittorrentStructures.QBittorrentFastresume{
+				DownloadRateLimit:   -1,
+				MaxUploads:          100,
+				QbtRatioLimit:       2000,
+				QbtSeedingTimeLimit: -1,
+			},
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			transferStructure := TransferStructure{
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{
+					DownloadRateLimit:   -1,
+					MaxUploads:          100,
+					QbtRatioLimit:       -2000,
+					QbtSeedingTimeLimit: -2,
+				},
+				Torrent: testCase.torrent,
+			}
+			transferStructure.HandleLimits()
+			transferStructure.HandleSeedSettings()
+			if !reflect.DeepEqual(testCase.expected, transferStructure.Fastresume) {
+				t.Fatalf("Unexpected error: fastresume isn't equal:\n Got: %#v\n Expect %#v\n", transferStructure.Fastresume, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestTransferStructure_HandleActivity(t *testing.T) {
+	type HandleActivityCase struct {
+		name     string
+		torrent  *sources.Torrent
+		expected *qBittorrentStructures.QBittorrentFastresume
+	}
+	cases := []HandleActivityCase{
+		{
+			name:     "001 torrent without activity",
+			torrent:  &sources.Torrent{AddedOn: 1650146407},
+			expected: &qBittorrentStructures.QBittorrentFastresume{},
+		},
+		{
+			name: "002 seeding torrent",
+			torrent: &sources.Torrent{
+				CompletedOn: 1650146407,
+				Downloaded:  297,
+				LastActive:  1650150000,
+				SeedingTime: 3600,
+				Uploaded:    594,
+			},
+			expected: &qBittorrentStructures.QBittorrentFastresume{
+				FinishedTime: 3600,
+				LastDownload: 1650146407,
+				LastUpload:   1650150000,
+			},
+		},
+		{
+			name: "003 downloading torrent",
+			torrent: &sources.Torrent{
+				Downloaded: 297,
+				LastActive: 1650150000,
+			},
+			expected: &qBittorrentStructures.QBittorrentFastresume{
+				LastDownload: 1650150000,
+			},
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			transferStructure := TransferStructure{
+				Fastresume: &qBittorrentStructures.QBittorrentFastresume{},
+				Torrent:    testCase.torrent,
+			}
+			transferStructure.HandleActivity()
+			if !reflect.DeepEqual(testCase.expected, transferStructure.Fastresume) {
+				t.Fatalf("Unexpected error: fastresume isn't equal:\n Got: %#v\n Expect %#v\n", transferStructure.Fastresume, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestTransferStructure_HandleCompleted(t *testing.T) {
+	transferStructure := TransferStructure{
+		Fastresume: &qBittorrentStructures.QBittorrentFastresume{CompletedTime: 1650146407},
+		Torrent:    &sources.Torrent{LastSeenComplete: 1650150000},
+	}
+	transferStructure.HandleCompleted()
+	if transferStructure.Fastresume.LastSeenComplete != 1650150000 {
+		t.Fatalf("Unexpected last seen complete %v", transferStructure.Fastresume.LastSeenComplete)
+	}
+
+	// completed torrent without last seen complete is complete now
+	startTime := time.Now().Unix()
+	transferStructure.Torrent = &sources.Torrent{}
+	transferStructure.HandleCompleted()
+	if transferStructure.Fastresume.LastSeenComplete < startTime {
+		t.Fatalf("Unexpected last seen complete %v", transferStructure.Fastresume.LastSeenComplete)
+	}
+}
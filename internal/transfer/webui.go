@@ -0,0 +1,332 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
+)
+
+// WebUIClient add torrents through qBittorrent WebUI API, so qBittorrent may be running and its data directory
+// isn't required. https://github.com/qbittorrent/qBittorrent/wiki/WebUI-API-(qBittorrent-4.1)
+type WebUIClient struct {
+	URL          string
+	PollInterval time.Duration // how often to check that added torrent appeared in qBittorrent
+	PollAttempts int
+	client       *http.Client
+}
+
+// webUIFile file of torrent as WebUI API returns it
+type webUIFile struct {
+	Index *int64 `json:"index"` // qBittorrent older than 4.3 doesn't return index, so position in list is used
+	Name  string `json:"name"`
+}
+
+// NewWebUIClient log into WebUI. Session cookie is kept by client
+func NewWebUIClient(webUIURL string, username string, password string) (*WebUIClient, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	webUI := &WebUIClient{
+		URL:          strings.TrimRight(webUIURL, "/"),
+		PollInterval: 200 * time.Millisecond,
+		PollAttempts: 50,
+		client:       &http.Client{Jar: jar, Timeout: 5 * time.Minute},
+	}
+	body, err := webUI.post("/api/v2/auth/login", url.Values{"username": {username}, "password": {password}})
+	if err != nil {
+		return nil, fmt.Errorf("can't log into qBittorrent WebUI %v: %v", webUI.URL, err)
+	}
+	if strings.TrimSpace(body) != "Ok." {
+		return nil, fmt.Errorf("can't log into qBittorrent WebUI %v: wrong username or password", webUI.URL)
+	}
+	return webUI, nil
+}
+
+// AddTorrent add torrent with settings from fastresume, then apply file priorities and renames.
+// magnetURI is used for torrents without metadata. It returns false if torrent already exists in qBittorrent
+func (webUI *WebUIClient) AddTorrent(transfer *TransferStructure, hash string, magnetURI string) (bool, error) {
+	exists, err := webUI.torrentExists(hash)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	fastresume := transfer.Fastresume
+	fields := map[string]string{
+		"savepath":      fastresume.QbtSavePath,
+		"category":      fastresume.QBtCategory,
+		"tags":          strings.Join(fastresume.QbtTags, ","),
+		"contentLayout": fastresume.QBtContentLayout,
+		"rename":        fastresume.QbtName,
+		"autoTMM":       "false",
+		"paused":        strconv.FormatBool(fastresume.Paused == 1),
+		"stopped":       strconv.FormatBool(fastresume.Paused == 1), // qBittorrent 5.0 renamed paused to stopped
+		"skip_checking": strconv.FormatBool(transfer.HasAllPieces()),
+	}
+	if fastresume.UploadRateLimit > 0 {
+		fields["upLimit"] = strconv.FormatInt(fastresume.UploadRateLimit, 10)
+	}
+	if fastresume.DownloadRateLimit > 0 {
+		fields["dlLimit"] = strconv.FormatInt(fastresume.DownloadRateLimit, 10)
+	}
+	if fastresume.QbtRatioLimit != -2000 {
+		fields["ratioLimit"] = strconv.FormatFloat(float64(fastresume.QbtRatioLimit)/1000, 'f', -1, 64)
+	}
+	if fastresume.QbtSeedingTimeLimit != -2 {
+		fields["seedingTimeLimit"] = strconv.FormatInt(fastresume.QbtSeedingTimeLimit, 10)
+	}
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	for _, name := range sortedKeys(fields) {
+		if err = writer.WriteField(name, fields[name]); err != nil {
+			return false, err
+		}
+	}
+	if transfer.Magnet {
+		err = writer.WriteField("urls", magnetURI)
+	} else {
+		err = writeFormFile(writer, "torrents", hash+".torrent", transfer.TorrentFilePath)
+	}
+	if err != nil {
+		return false, err
+	}
+	if err = writer.Close(); err != nil {
+		return false, err
+	}
+	body, err := webUI.request(http.MethodPost, "/api/v2/torrents/add", writer.FormDataContentType(), &form)
+	if err != nil {
+		return false, fmt.Errorf("can't add torrent: %v", err)
+	}
+	if strings.TrimSpace(body) != "Ok." {
+		return false, fmt.Errorf("qBittorrent didn't add torrent: %v", body)
+	}
+
+	// files of magnet links are unknown until qBittorrent fetches metadata
+	if transfer.Magnet {
+		return true, nil
+	}
+	files, err := webUI.waitFiles(hash)
+	if err != nil {
+		return true, err
+	}
+	if err = webUI.setFilePriorities(hash, fastresume.FilePriority); err != nil {
+		return true, err
+	}
+	return true, webUI.renameFiles(transfer, hash, files)
+}
+
+// CreateCategories create categories that qBittorrent doesn't have yet, as ProcessLabels does with categories.json
+func (webUI *WebUIClient) CreateCategories(newCategories []string) error {
+	body, err := webUI.get("/api/v2/torrents/categories", nil)
+	if err != nil {
+		return err
+	}
+	categories := map[string]interface{}{}
+	if err = json.Unmarshal([]byte(body), &categories); err != nil {
+		return fmt.Errorf("can't decode categories: %v", err)
+	}
+	for _, category := range newCategories {
+		if _, ok := categories[category]; ok {
+			continue
+		}
+		if _, err = webUI.post("/api/v2/torrents/createCategory", url.Values{"category": {category}, "savePath": {""}}); err != nil {
+			return fmt.Errorf("can't create category %v: %v", category, err)
+		}
+	}
+	return nil
+}
+
+// QueueTorrents move torrents to the bottom of queue one by one, so they keep order. It's possible only if queueing
+// is enabled in qBittorrent, otherwise queue is skipped with message
+func (webUI *WebUIClient) QueueTorrents(hashes []string) error {
+	for _, hash := range hashes {
+		_, err := webUI.post("/api/v2/torrents/bottomPrio", url.Values{"hashes": {hash}})
+		var requestErr *webUIError
+		if errors.As(err, &requestErr) && requestErr.StatusCode == http.StatusConflict {
+			log.Println("Torrent queueing is disabled in qBittorrent, queue order isn't transferred")
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("can't move torrent %v in queue: %v", hash, err)
+		}
+	}
+	return nil
+}
+
+func (webUI *WebUIClient) torrentExists(hash string) (bool, error) {
+	body, err := webUI.get("/api/v2/torrents/info", url.Values{"hashes": {hash}})
+	if err != nil {
+		return false, err
+	}
+	var torrents []interface{}
+	if err = json.Unmarshal([]byte(body), &torrents); err != nil {
+		return false, fmt.Errorf("can't decode torrents info: %v", err)
+	}
+	return len(torrents) != 0, nil
+}
+
+// waitFiles return files of torrent. qBittorrent adds torrents asynchronously, so files appear after some time
+func (webUI *WebUIClient) waitFiles(hash string) ([]webUIFile, error) {
+	var lastErr error
+	for attempt := 0; attempt < webUI.PollAttempts; attempt++ {
+		if attempt != 0 {
+			time.Sleep(webUI.PollInterval)
+		}
+		body, err := webUI.get("/api/v2/torrents/files", url.Values{"hash": {hash}})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var files []webUIFile
+		if err = json.Unmarshal([]byte(body), &files); err != nil {
+			return nil, fmt.Errorf("can't decode files of torrent: %v", err)
+		}
+		if len(files) != 0 {
+			return files, nil
+		}
+	}
+	return nil, fmt.Errorf("torrent didn't appear in qBittorrent after adding: %v", lastErr)
+}
+
+// setFilePriorities set priorities that differ from normal. File priorities of fastresume are 0, 1, 6 and 7 as API expects
+func (webUI *WebUIClient) setFilePriorities(hash string, filePriority []int64) error {
+	indexes := map[int64][]string{}
+	for index, priority := range filePriority {
+		if priority != 1 {
+			indexes[priority] = append(indexes[priority], strconv.Itoa(index))
+		}
+	}
+	var priorities []int64
+	for priority := range indexes {
+		priorities = append(priorities, priority)
+	}
+	sort.Slice(priorities, func(i, j int) bool { return priorities[i] < priorities[j] })
+	for _, priority := range priorities {
+		_, err := webUI.post("/api/v2/torrents/filePrio", url.Values{
+			"hash":     {hash},
+			"id":       {strings.Join(indexes[priority], "|")},
+			"priority": {strconv.FormatInt(priority, 10)},
+		})
+		if err != nil {
+			return fmt.Errorf("can't set file priorities: %v", err)
+		}
+	}
+	return nil
+}
+
+// renameFiles rename files that have mapped files. Mapped files are relative to save path, as paths in WebUI API
+func (webUI *WebUIClient) renameFiles(transfer *TransferStructure, hash string, files []webUIFile) error {
+	for position, file := range files {
+		index := int64(position)
+		if file.Index != nil {
+			index = *file.Index
+		}
+		if index >= int64(len(transfer.Fastresume.MappedFiles)) || transfer.Fastresume.MappedFiles[index] == "" {
+			continue
+		}
+		mappedFile := transfer.Fastresume.MappedFiles[index]
+		if isAbsPath(mappedFile) {
+			transfer.Warnings = append(transfer.Warnings,
+				fmt.Sprintf("file %v is outside of save path, it can't be renamed through WebUI API", mappedFile))
+			continue
+		}
+		newPath := fileHelpers.Normalize(mappedFile, `/`)
+		oldPath := fileHelpers.Normalize(file.Name, `/`)
+		if newPath == oldPath {
+			continue
+		}
+		_, err := webUI.post("/api/v2/torrents/renameFile", url.Values{"hash": {hash}, "oldPath": {oldPath}, "newPath": {newPath}})
+		if err != nil {
+			return fmt.Errorf("can't rename file %v to %v: %v", oldPath, newPath, err)
+		}
+	}
+	return nil
+}
+
+func (webUI *WebUIClient) get(path string, query url.Values) (string, error) {
+	if query != nil {
+		path += "?" + query.Encode()
+	}
+	return webUI.request(http.MethodGet, path, "", nil)
+}
+
+func (webUI *WebUIClient) post(path string, form url.Values) (string, error) {
+	return webUI.request(http.MethodPost, path, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
+
+func (webUI *WebUIClient) request(method string, path string, contentType string, body io.Reader) (string, error) {
+	request, err := http.NewRequest(method, webUI.URL+path, body)
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	// WebUI checks referer against host to protect from CSRF
+	request.Header.Set("Referer", webUI.URL)
+	response, err := webUI.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", &webUIError{
+			StatusCode: response.StatusCode,
+			Message:    fmt.Sprintf("%v %v returned %v %v", method, path, response.Status, strings.TrimSpace(string(data))),
+		}
+	}
+	return string(data), nil
+}
+
+// webUIError request that WebUI answered with unsuccessful status
+type webUIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *webUIError) Error() string {
+	return e.Message
+}
+
+func writeFormFile(writer *multipart.Writer, field string, name string, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	part, err := writer.CreateFormFile(field, name)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(data)
+	return err
+}
+
+func sortedKeys(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
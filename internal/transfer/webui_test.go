@@ -0,0 +1,187 @@
+package transfer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rumanzo/bt2qbt/internal/options"
+	"github.com/rumanzo/bt2qbt/internal/sources"
+)
+
+// fakeWebUI stand-in of qBittorrent WebUI API that records requests
+type fakeWebUI struct {
+	mu         sync.Mutex
+	torrents   map[string]bool
+	categories map[string]bool
+	added      []map[string]string
+	requests   []string
+	noQueueing bool // torrent queueing is disabled in preferences
+}
+
+func newFakeWebUI(t *testing.T) (*fakeWebUI, *httptest.Server) {
+	fake := &fakeWebUI{torrents: map[string]bool{"existing": true}, categories: map[string]bool{"existing": true}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		if r.URL.Path == "/api/v2/auth/login" {
+			if r.PostFormValue("username") != "admin" || r.PostFormValue("password") != "secret" {
+				io.WriteString(w, "Fails.")
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "SID", Value: "session", Path: "/"})
+			io.WriteString(w, "Ok.")
+			return
+		}
+		if cookie, err := r.Cookie("SID"); err != nil || cookie.Value != "session" || r.Header.Get("Referer") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v2/torrents/info":
+			var torrents []string
+			if fake.torrents[r.URL.Query().Get("hashes")] {
+				torrents = append(torrents, r.URL.Query().Get("hashes"))
+			}
+			json.NewEncoder(w).Encode(torrents)
+		case "/api/v2/torrents/add":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			fields := map[string]string{}
+			for name, values := range r.MultipartForm.Value {
+				fields[name] = values[0]
+			}
+			for _, files := range r.MultipartForm.File {
+				fields["torrent"] = files[0].Filename
+				fake.torrents[strings.TrimSuffix(files[0].Filename, ".torrent")] = true
+			}
+			fake.added = append(fake.added, fields)
+			io.WriteString(w, "Ok.")
+		case "/api/v2/torrents/files":
+			if !fake.torrents[r.URL.Query().Get("hash")] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			var files []map[string]interface{}
+			for i, name := range []string{"testfile1.txt", "testfile2.txt", "testfile3.txt", "dir1/testfile1.txt",
+				"dir2/testfile1.txt", "dir2/testfile2.txt", "dir3/testfile1.txt", "dir3/testfile2.txt", "dir3/testfile3.txt"} {
+				files = append(files, map[string]interface{}{"index": i, "name": "testdir/" + name})
+			}
+			json.NewEncoder(w).Encode(files)
+		case "/api/v2/torrents/categories":
+			json.NewEncoder(w).Encode(fake.categories)
+		case "/api/v2/torrents/createCategory":
+			fake.categories[r.PostFormValue("category")] = true
+		case "/api/v2/torrents/bottomPrio":
+			if fake.noQueueing {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			fake.requests = append(fake.requests, r.URL.Path+"?hashes="+r.PostFormValue("hashes"))
+		default:
+			r.ParseForm()
+			fake.requests = append(fake.requests, r.URL.Path+"?"+r.PostForm.Encode())
+		}
+	}))
+	return fake, server
+}
+
+func TestNewWebUIClient(t *testing.T) {
+	_, server := newFakeWebUI(t)
+	defer server.Close()
+	if _, err := NewWebUIClient(server.URL, "admin", "wrong"); err == nil {
+		t.Fatalf("Login with wrong password must fail")
+	}
+	webUI, err := NewWebUIClient(server.URL+"/", "admin", "secret")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exists, err := webUI.torrentExists("existing")
+	if err != nil || !exists {
+		t.Fatalf("Session cookie isn't sent: %v", err)
+	}
+}
+
+func TestHandleTorrentsWebUI(t *testing.T) {
+	fake, server := newFakeWebUI(t)
+	defer server.Close()
+	opts := &options.Opts{
+		BitDir:        "../../test/data",
+		PathSeparator: `/`,
+		SearchPaths:   []string{"../../test/data"},
+		WebUI:         server.URL,
+		WebUIUsername: "admin",
+		WebUIPassword: "secret",
+	}
+	normal, skip, high := sources.PriorityNormal, sources.PrioritySkip, sources.PriorityHigh
+	torrents := map[string]*sources.Torrent{
+		"testfileset.torrent": {
+			Path:           "/mnt/films/testdir",
+			Name:           "films",
+			Started:        true,
+			Tags:           []string{"label", "existing"},
+			FilePriorities: []sources.Priority{normal, skip, high, normal, normal, normal, normal, normal, normal},
+			RenamedFiles:   []sources.RenamedFile{{Index: 0, Path: "renamed.txt"}},
+
+			DownloadLimit: 51200,
+			SeedLimits:    &sources.SeedLimits{Ratio: 1.5, SeedingTime: 3600},
+		},
+	}
+	if wasErrors := HandleTorrents(opts, torrents); wasErrors {
+		t.Fatalf("Unexpected errors while processing")
+	}
+
+	if len(fake.added) != 1 {
+		t.Fatalf("Unexpected added torrents: %v", fake.added)
+	}
+	added := fake.added[0]
+	if added["torrent"] != "3456bac107634970b022677c6bfaa584065e0917.torrent" || added["savepath"] != "/mnt/films/" ||
+		added["rename"] != "films" || added["tags"] != "label,existing" || added["contentLayout"] != "Original" ||
+		added["paused"] != "true" || added["skip_checking"] != "false" || added["autoTMM"] != "false" ||
+		added["dlLimit"] != "51200" || added["ratioLimit"] != "1.5" || added["seedingTimeLimit"] != "60" {
+		t.Fatalf("Unexpected parameters of added torrent: %v", added)
+	}
+	expectedRequests := []string{
+		"/api/v2/torrents/filePrio?hash=3456bac107634970b022677c6bfaa584065e0917&id=1&priority=0",
+		"/api/v2/torrents/filePrio?hash=3456bac107634970b022677c6bfaa584065e0917&id=2&priority=6",
+		"/api/v2/torrents/renameFile?hash=3456bac107634970b022677c6bfaa584065e0917&newPath=testdir%2Frenamed.txt&oldPath=testdir%2Ftestfile1.txt",
+	}
+	if strings.Join(fake.requests, "\n") != strings.Join(expectedRequests, "\n") {
+		t.Fatalf("Unexpected requests:\n%v", strings.Join(fake.requests, "\n"))
+	}
+	if !fake.categories["label"] {
+		t.Fatalf("Category wasn't created: %v", fake.categories)
+	}
+
+	// torrent that already exists in qBittorrent is skipped
+	if wasErrors := HandleTorrents(opts, torrents); wasErrors || len(fake.added) != 1 {
+		t.Fatalf("Existing torrent must be skipped")
+	}
+}
+
+func TestWebUIClient_QueueTorrents(t *testing.T) {
+	fake, server := newFakeWebUI(t)
+	defer server.Close()
+	webUI, err := NewWebUIClient(server.URL, "admin", "secret")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = webUI.QueueTorrents([]string{"bbb", "aaa"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedRequests := []string{"/api/v2/torrents/bottomPrio?hashes=bbb", "/api/v2/torrents/bottomPrio?hashes=aaa"}
+	if strings.Join(fake.requests, "\n") != strings.Join(expectedRequests, "\n") {
+		t.Fatalf("Unexpected requests:\n%v", strings.Join(fake.requests, "\n"))
+	}
+
+	// queue is skipped if queueing is disabled
+	fake.noQueueing = true
+	if err = webUI.QueueTorrents([]string{"bbb"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package transfer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+	"github.com/rumanzo/bt2qbt/pkg/partFiles"
+	"github.com/rumanzo/bt2qbt/pkg/qBittorrentStructures"
+	"github.com/zeebo/bencode"
+)
+
+// ConflictPolicy define what to do if file already exists in qBittorrent directory
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictBackup    ConflictPolicy = "backup"
+	ConflictMerge     ConflictPolicy = "merge"
+)
+
+// OutputWriter write files into qBittorrent directory atomically with respect to conflict policy
+type OutputWriter struct {
+	Policy  ConflictPolicy
+	Journal *Journal // optional
+}
+
+// NewOutputWriter create writer. Existing files are overwritten by default
+func NewOutputWriter(policy string) *OutputWriter {
+	if policy == "" {
+		policy = string(ConflictOverwrite)
+	}
+	return &OutputWriter{Policy: ConflictPolicy(policy)}
+}
+
+// WriteFastresume write fastresume file. On merge keys of existing fastresume that don't exist in new one are kept.
+// It returns false if file was skipped
+func (w *OutputWriter) WriteFastresume(path string, fastresume *qBittorrentStructures.QBittorrentFastresume) (bool, error) {
+	data, err := bencode.EncodeBytes(fastresume)
+	if err != nil {
+		return false, err
+	}
+	return w.write(path, data, mergeFastresume)
+}
+
+// CopyTorrent copy torrent file. Torrent file with the same hash has the same info, so existing one is kept on merge
+func (w *OutputWriter) CopyTorrent(src string, dst string) (bool, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return false, err
+	}
+	return w.write(dst, data, keepExisting)
+}
+
+// WritePartFile write libtorrent part file converted from uTorrent part file. Existing part file may contain pieces
+// that qBittorrent downloaded already, so it's kept on merge
+func (w *OutputWriter) WritePartFile(path string, partFile *partFiles.UTorrentPartFile) (bool, error) {
+	var buffer bytes.Buffer
+	if err := partFile.WriteLibtorrentPartFile(&buffer); err != nil {
+		return false, err
+	}
+	return w.write(path, buffer.Bytes(), keepExisting)
+}
+
+func (w *OutputWriter) write(path string, data []byte, merge func(existing []byte, data []byte) ([]byte, error)) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err = w.Record(path); err != nil {
+			return false, err
+		}
+		return true, helpers.WriteFileAtomic(path, data)
+	} else if err != nil {
+		return false, err
+	}
+
+	switch w.Policy {
+	case ConflictSkip:
+		return false, nil
+	case ConflictBackup:
+		backupPath := fmt.Sprintf("%v.%v.bak", path, time.Now().Format("20060102150405"))
+		if err = w.Record(backupPath); err != nil {
+			return false, err
+		}
+		if err = helpers.WriteFileAtomic(backupPath, existing); err != nil {
+			return false, fmt.Errorf("can't backup %v: %v", path, err)
+		}
+	case ConflictMerge:
+		if data, err = merge(existing, data); err != nil {
+			return false, fmt.Errorf("can't merge with existing %v: %v", path, err)
+		}
+	}
+	if err = w.Record(path); err != nil {
+		return false, err
+	}
+	return true, helpers.WriteFileAtomic(path, data)
+}
+
+// Record save file state to journal before it will be written
+func (w *OutputWriter) Record(path string) error {
+	if w.Journal == nil {
+		return nil
+	}
+	return w.Journal.Record(path)
+}
+
+func keepExisting(existing []byte, _ []byte) ([]byte, error) {
+	return existing, nil
+}
+
+func mergeFastresume(existing []byte, data []byte) ([]byte, error) {
+	// raw values keep bytes of info dictionary and unknown keys as is
+	existingMap := map[string]bencode.RawMessage{}
+	if err := bencode.DecodeBytes(existing, &existingMap); err != nil {
+		return nil, err
+	}
+	newMap := map[string]bencode.RawMessage{}
+	if err := bencode.DecodeBytes(data, &newMap); err != nil {
+		return nil, err
+	}
+	for key, value := range newMap {
+		existingMap[key] = value
+	}
+	return bencode.EncodeBytes(existingMap)
+}
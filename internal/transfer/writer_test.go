@@ -0,0 +1,140 @@
+package transfer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rumanzo/bt2qbt/pkg/partFiles"
+	"github.com/rumanzo/bt2qbt/pkg/qBittorrentStructures"
+)
+
+func TestOutputWriter_WriteFastresume(t *testing.T) {
+	type WriterCase struct {
+		name            string
+		policy          string
+		existing        string
+		expectedWritten bool
+		expectedContent string
+		expectedBackup  bool
+	}
+	fastresume := &qBittorrentStructures.QBittorrentFastresume{QbtName: "new"}
+	cases := []WriterCase{
+		{
+			name:            "001 not existing file",
+			policy:          "skip",
+			expectedWritten: true,
+			expectedContent: "8:qBt-name3:new",
+		},
+		{
+			name:            "002 default policy overwrites",
+			existing:        "d8:qBt-name3:old7:unknowni1ee",
+			expectedWritten: true,
+			expectedContent: "8:qBt-name3:new",
+		},
+		{
+			name:            "003 skip existing",
+			policy:          "skip",
+			existing:        "d8:qBt-name3:old7:unknowni1ee",
+			expectedContent: "d8:qBt-name3:old7:unknowni1ee",
+		},
+		{
+			name:            "004 backup existing",
+			policy:          "backup",
+			existing:        "d8:qBt-name3:old7:unknowni1ee",
+			expectedWritten: true,
+			expectedContent: "8:qBt-name3:new",
+			expectedBackup:  true,
+		},
+		{
+			name:            "005 merge existing",
+			policy:          "merge",
+			existing:        "d8:qBt-name3:old7:unknowni1ee",
+			expectedWritten: true,
+			expectedContent: "7:unknowni1e",
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "hash.fastresume")
+			if testCase.existing != "" {
+				if err := os.WriteFile(path, []byte(testCase.existing), 0644); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			written, err := NewOutputWriter(testCase.policy).WriteFastresume(path, fastresume)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if written != testCase.expectedWritten {
+				t.Fatalf("Unexpected written flag: %v", written)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !strings.Contains(string(data), testCase.expectedContent) {
+				t.Fatalf("Unexpected content:\n Got: %v\n Expect contains %v\n", string(data), testCase.expectedContent)
+			}
+			backups, err := filepath.Glob(path + ".*.bak")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if testCase.expectedBackup {
+				if len(backups) != 1 {
+					t.Fatalf("Backup wasn't created")
+				}
+				backup, err := os.ReadFile(backups[0])
+				if err != nil || string(backup) != testCase.existing {
+					t.Fatalf("Unexpected backup content: %v %v", string(backup), err)
+				}
+			} else if len(backups) != 0 {
+				t.Fatalf("Unexpected backups: %v", backups)
+			}
+		})
+	}
+}
+
+func TestOutputWriter_CopyTorrentMerge(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "hash.torrent")
+	if err := os.WriteFile(dst, []byte("existing"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := NewOutputWriter("merge").CopyTorrent("../../test/data/testfileset.torrent", dst); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "existing" {
+		t.Fatalf("Existing torrent file must be kept on merge")
+	}
+}
+
+func TestOutputWriter_WritePartFile(t *testing.T) {
+	dir := t.TempDir()
+	// uTorrent part file of one piece with 4 bytes stored in slot 1
+	src := filepath.Join(dir, "~uTorrentPartFile_1A2B.dat")
+	if err := os.WriteFile(src, []byte("\x01\x00\x00\x00aaaa"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	partFile := &partFiles.UTorrentPartFile{Path: src, NumPieces: 1, PieceLength: 4, Slots: map[int64]int64{0: 4}}
+	dst := filepath.Join(dir, ".hash.parts")
+	if written, err := NewOutputWriter("skip").WritePartFile(dst, partFile); err != nil || !written {
+		t.Fatalf("Unexpected result: %v %v", written, err)
+	}
+	if data, _ := os.ReadFile(dst); int64(len(data)) != partFiles.LibtorrentHeaderSize(1)+4 {
+		t.Fatalf("Unexpected part file size %v", len(data))
+	}
+	if err := os.WriteFile(dst, []byte("existing"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, policy := range []string{"skip", "merge"} {
+		if _, err := NewOutputWriter(policy).WritePartFile(dst, partFile); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if data, _ := os.ReadFile(dst); string(data) != "existing" {
+			t.Fatalf("Existing part file must be kept with policy %v", policy)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package bencodeScanner
+
+/*
+Scanner walks over bencoded data without decoding, so raw bytes of values stay exactly as they were written.
+It's important for info dictionary, because any client hashes original bytes even if they aren't canonical bencode.
+http://www.bittorrent.org/beps/bep_0003.html#bencoding
+*/
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// maxDepth protect from stack overflow on broken or malicious data
+const maxDepth = 512
+
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("bencode syntax error at offset %v: %v", e.Offset, e.Msg)
+}
+
+// Entry dictionary entry with offsets of key and value in data
+type Entry struct {
+	Key        string
+	Start      int // offset of key
+	ValueStart int // offset of value
+	End        int // offset right after value
+}
+
+// ScanValue return offset right after value that starts at pos
+func ScanValue(data []byte, pos int) (int, error) {
+	return scanValue(data, pos, 0)
+}
+
+func scanValue(data []byte, pos int, depth int) (int, error) {
+	if depth > maxDepth {
+		return pos, &SyntaxError{Offset: pos, Msg: "too deep nesting"}
+	}
+	if pos >= len(data) {
+		return pos, &SyntaxError{Offset: pos, Msg: "unexpected end of data"}
+	}
+	switch c := data[pos]; {
+	case c == 'i':
+		return scanInt(data, pos)
+	case c == 'l':
+		pos++
+		for {
+			if pos >= len(data) {
+				return pos, &SyntaxError{Offset: pos, Msg: "unterminated list"}
+			}
+			if data[pos] == 'e' {
+				return pos + 1, nil
+			}
+			end, err := scanValue(data, pos, depth+1)
+			if err != nil {
+				return end, err
+			}
+			pos = end
+		}
+	case c == 'd':
+		pos++
+		for {
+			if pos >= len(data) {
+				return pos, &SyntaxError{Offset: pos, Msg: "unterminated dictionary"}
+			}
+			if data[pos] == 'e' {
+				return pos + 1, nil
+			}
+			_, end, err := ScanString(data, pos)
+			if err != nil {
+				return end, err
+			}
+			end, err = scanValue(data, end, depth+1)
+			if err != nil {
+				return end, err
+			}
+			pos = end
+		}
+	case c >= '0' && c <= '9':
+		_, end, err := ScanString(data, pos)
+		return end, err
+	default:
+		return pos, &SyntaxError{Offset: pos, Msg: fmt.Sprintf("unexpected symbol %q", c)}
+	}
+}
+
+func scanInt(data []byte, pos int) (int, error) {
+	i := pos + 1
+	if i < len(data) && data[i] == '-' {
+		i++
+	}
+	digitsStart := i
+	for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+		i++
+	}
+	if i == digitsStart {
+		return i, &SyntaxError{Offset: i, Msg: "integer without digits"}
+	}
+	if i >= len(data) || data[i] != 'e' {
+		return i, &SyntaxError{Offset: i, Msg: "unterminated integer"}
+	}
+	return i + 1, nil
+}
+
+// ScanString return string that starts at pos and offset right after it
+func ScanString(data []byte, pos int) (string, int, error) {
+	i := pos
+	for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+		i++
+	}
+	if i == pos || i >= len(data) || data[i] != ':' {
+		return "", i, &SyntaxError{Offset: i, Msg: "wrong string length"}
+	}
+	length, err := strconv.Atoi(string(data[pos:i]))
+	if err != nil {
+		return "", i, &SyntaxError{Offset: pos, Msg: "wrong string length"}
+	}
+	start := i + 1
+	if length > len(data)-start {
+		return "", len(data), &SyntaxError{Offset: start, Msg: "string is longer than data"}
+	}
+	return string(data[start : start+length]), start + length, nil
+}
+
+// DictEntries return entries of dictionary that starts at the beginning of data
+func DictEntries(data []byte) ([]Entry, error) {
+	if len(data) == 0 || data[0] != 'd' {
+		return nil, &SyntaxError{Offset: 0, Msg: "data isn't dictionary"}
+	}
+	var entries []Entry
+	pos := 1
+	for {
+		if pos >= len(data) {
+			return entries, &SyntaxError{Offset: pos, Msg: "unterminated dictionary"}
+		}
+		if data[pos] == 'e' {
+			return entries, nil
+		}
+		key, valueStart, err := ScanString(data, pos)
+		if err != nil {
+			return entries, err
+		}
+		end, err := ScanValue(data, valueStart)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, Entry{Key: key, Start: pos, ValueStart: valueStart, End: end})
+		pos = end
+	}
+}
+
+// RawValue return raw bytes of value with key from dictionary at the beginning of data
+func RawValue(data []byte, key string) ([]byte, error) {
+	entries, err := DictEntries(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Key == key {
+			return data[entry.ValueStart:entry.End], nil
+		}
+	}
+	return nil, fmt.Errorf("key %v not found", key)
+}
+
+/*
+SalvageDictEntries walk dictionary at the beginning of data like DictEntries, but don't stop on damaged entry.
+After damaged entry data is searched byte by byte for the next entry, isEntry filter candidates (value is rest of data
+after key), so keys of nested dictionaries aren't taken for entries. Keys of damaged entries are returned if they can
+be read. Error is returned only if data isn't dictionary
+*/
+func SalvageDictEntries(data []byte, isEntry func(key string, value []byte) bool) ([]Entry, []string, error) {
+	if len(data) == 0 || data[0] != 'd' {
+		return nil, nil, &SyntaxError{Offset: 0, Msg: "data isn't dictionary"}
+	}
+	var entries []Entry
+	var damaged []string
+	pos := 1
+	for pos < len(data) && data[pos] != 'e' {
+		key, valueStart, err := ScanString(data, pos)
+		if err == nil {
+			end, err := ScanValue(data, valueStart)
+			if err == nil {
+				entries = append(entries, Entry{Key: key, Start: pos, ValueStart: valueStart, End: end})
+				pos = end
+				continue
+			}
+			damaged = append(damaged, key)
+		}
+		pos = nextEntry(data, pos+1, isEntry)
+	}
+	return entries, damaged, nil
+}
+
+// nextEntry return offset of the first entry candidate after pos or length of data if there is no candidate
+func nextEntry(data []byte, pos int, isEntry func(key string, value []byte) bool) int {
+	for ; pos < len(data); pos++ {
+		if key, valueStart, err := ScanString(data, pos); err == nil && isEntry(key, data[valueStart:]) {
+			return pos
+		}
+	}
+	return len(data)
+}
@@ -0,0 +1,162 @@
+package bencodeScanner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRawValue(t *testing.T) {
+	type RawValueCase struct {
+		name     string
+		mustFail bool
+		data     string
+		key      string
+		expected string
+	}
+	cases := []RawValueCase{
+		{
+			name:     "001 canonical dictionary",
+			data:     "d8:announce3:url4:infod4:name4:testee",
+			key:      "info",
+			expected: "d4:name4:teste",
+		},
+		{
+			name:     "002 unsorted keys and extra fields stay as is",
+			data:     "d4:infod4:name4:test1:ai-0e1:zl1:aee8:announce3:urle",
+			key:      "info",
+			expected: "d4:name4:test1:ai-0e1:zl1:aee",
+		},
+		{
+			name:     "003 nested info key isn't used",
+			data:     "d1:ad4:infoi1ee4:infoi2ee",
+			key:      "info",
+			expected: "i2e",
+		},
+		{
+			name:     "004 missing key",
+			mustFail: true,
+			data:     "d8:announce3:urle",
+			key:      "info",
+		},
+		{
+			name:     "005 not dictionary",
+			mustFail: true,
+			data:     "l4:infoe",
+			key:      "info",
+		},
+		{
+			name:     "006 truncated string",
+			mustFail: true,
+			data:     "d4:info10:abce",
+			key:      "info",
+		},
+		{
+			name:     "007 unterminated integer",
+			mustFail: true,
+			data:     "d4:infoi12",
+			key:      "info",
+		},
+		{
+			name:     "008 unterminated dictionary",
+			mustFail: true,
+			data:     "d4:infod4:name4:test",
+			key:      "info",
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			raw, err := RawValue([]byte(testCase.data), testCase.key)
+			if err != nil && !testCase.mustFail {
+				t.Fatalf("Unexpected error: %v", err)
+			} else if err == nil && testCase.mustFail {
+				t.Fatalf("Test must fail, but it's not")
+			} else if !testCase.mustFail && string(raw) != testCase.expected {
+				t.Fatalf("Unexpected raw value:\n Got: %v\n Expect %v\n", string(raw), testCase.expected)
+			}
+		})
+	}
+}
+
+func TestDictEntriesPartial(t *testing.T) {
+	entries, err := DictEntries([]byte("d1:ai1e1:bi2e1:cx"))
+	if err == nil {
+		t.Fatalf("Test must fail, but it's not")
+	}
+	if len(entries) != 2 || entries[0].Key != "a" || entries[1].Key != "b" {
+		t.Fatalf("Unexpected entries before error: %+v", entries)
+	}
+}
+
+func TestScanValueDepth(t *testing.T) {
+	data := make([]byte, 0, 2*(maxDepth+2))
+	for i := 0; i < maxDepth+2; i++ {
+		data = append(data, 'l')
+	}
+	for i := 0; i < maxDepth+2; i++ {
+		data = append(data, 'e')
+	}
+	if _, err := ScanValue(data, 0); err == nil {
+		t.Fatalf("Test must fail, but it's not")
+	}
+}
+
+func TestSalvageDictEntries(t *testing.T) {
+	isEntry := func(key string, value []byte) bool {
+		return len(key) > 1 && len(value) != 0 && value[0] == 'd'
+	}
+	type SalvageCase struct {
+		name            string
+		mustFail        bool
+		data            string
+		expected        []string
+		expectedDamaged []string
+	}
+	cases := []SalvageCase{
+		{
+			name:     "001 valid dictionary",
+			data:     "d3:aaad1:ai1ee3:bbbd1:bi2eee",
+			expected: []string{"aaa", "bbb"},
+		},
+		{
+			name:            "002 truncated dictionary",
+			data:            "d3:aaad1:ai1ee3:bbbd1:bi2",
+			expected:        []string{"aaa"},
+			expectedDamaged: []string{"bbb"},
+		},
+		{
+			name:            "003 damaged value in the middle",
+			data:            "d3:aaad1:ai1ee3:bbbd1:bi2x3:cccd1:c3:abcee",
+			expected:        []string{"aaa", "ccc"},
+			expectedDamaged: []string{"bbb"},
+		},
+		{
+			name:            "004 damaged key in the middle, nested keys aren't entries",
+			data:            "d3:aaad1:ai1ee9x:bbbd1:bd1:xi1eee3:cccd1:ci3eee",
+			expected:        []string{"aaa", "ccc"},
+			expectedDamaged: nil,
+		},
+		{
+			name:     "005 not dictionary",
+			mustFail: true,
+			data:     "l3:aaae",
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			entries, damaged, err := SalvageDictEntries([]byte(testCase.data), isEntry)
+			if err != nil && !testCase.mustFail {
+				t.Fatalf("Unexpected error: %v", err)
+			} else if err == nil && testCase.mustFail {
+				t.Fatalf("Test must fail, but it's not")
+			}
+			var keys []string
+			for _, entry := range entries {
+				keys = append(keys, entry.Key)
+			}
+			if !reflect.DeepEqual(keys, testCase.expected) || !reflect.DeepEqual(damaged, testCase.expectedDamaged) {
+				t.Fatalf("Unexpected entries:\n Got: %v, damaged %v\n Expect %v, damaged %v\n",
+					keys, damaged, testCase.expected, testCase.expectedDamaged)
+			}
+		})
+	}
+}
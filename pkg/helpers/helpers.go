@@ -1,12 +1,12 @@
 package helpers
 
 import (
-	"bufio"
 	"bytes"
 	"github.com/crazytyper/go-cesu8"
 	"github.com/zeebo/bencode"
 	"io"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -46,36 +46,57 @@ func DecodeTorrentFile(path string, decodeTo interface{}) error {
 }
 
 func EncodeTorrentFile(path string, content interface{}) error {
-	var err error
-	var file *os.File
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		file, err = os.Create(path)
-		if err != nil {
-			panic(err)
-			return err
-		}
-		defer file.Close()
-	} else {
-		file, err = os.OpenFile(path, os.O_WRONLY, 0666)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
+	data, err := bencode.EncodeBytes(content)
+	if err != nil {
+		return err
 	}
+	return WriteFileAtomic(path, data)
+}
 
-	bufferedWriter := bufio.NewWriter(file)
+// WriteFileAtomic write data to temporary file in the same directory and rename it to path,
+// so path contains either old or new content even if process is interrupted
+func WriteFileAtomic(path string, data []byte) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmpFile, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	// remove temporary file if something goes wrong
+	defer os.Remove(tmpPath)
 
-	enc := bencode.NewEncoder(bufferedWriter)
-	if err = enc.Encode(content); err != nil {
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
 		return err
 	}
-	err = bufferedWriter.Flush()
-	if err := enc.Encode(content); err != nil {
+	if err = tmpFile.Sync(); err != nil {
+		tmpFile.Close()
 		return err
 	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	syncDir(dir)
 	return nil
 }
 
+// syncDir flush directory entry after rename. It's not supported on some systems (windows), so errors are ignored
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
 func CopyFile(src string, dst string) error {
 	originalFile, err := os.Open(src)
 	if err != nil {
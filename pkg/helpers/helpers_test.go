@@ -1,6 +1,8 @@
 package helpers
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -133,3 +135,31 @@ func TestReplaceAllSymbols(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeTorrentFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.fastresume")
+	// longer existing content must be truncated
+	if err := os.WriteFile(path, []byte("d4:name20:very long string valuee"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := EncodeTorrentFile(path, map[string]string{"name": "short"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != "d4:name5:shorte" {
+		t.Fatalf("Unexpected file content:\n Got: %v\n Expect %v\n", string(data), "d4:name5:shorte")
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Temporary file wasn't removed: %v", entries)
+	}
+	if err = EncodeTorrentFile(filepath.Join(path, "not-exists", "test.fastresume"), map[string]string{}); err == nil {
+		t.Fatalf("Test must fail, but it doesn't")
+	}
+}
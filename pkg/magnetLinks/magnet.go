@@ -0,0 +1,158 @@
+package magnetLinks
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MagnetLink parsed magnet uri
+// http://www.bittorrent.org/beps/bep_0009.html http://www.bittorrent.org/beps/bep_0052.html
+type MagnetLink struct {
+	InfoHash   []byte     // 20 bytes SHA-1 v1 info hash from btih
+	InfoHashV2 []byte     // 32 bytes SHA-256 v2 info hash from btmh
+	Name       string     // dn
+	Trackers   [][]string // tr, every tracker is separate tier as libtorrent does
+	WebSeeds   []string   // ws
+	SelectOnly []int64    // so, sorted indexes of selected files
+}
+
+// sha256 multihash prefix: function code 0x12 and digest length 0x20
+const multihashSha256Prefix = "1220"
+
+// maxSelectOnlyRange protect from allocating priorities for absurd indexes, priorities are allocated up to the last index
+const maxSelectOnlyRange = 1 << 20
+
+func Parse(uri string) (*MagnetLink, error) {
+	if !strings.HasPrefix(uri, "magnet:?") {
+		return nil, fmt.Errorf("%v isn't magnet link", uri)
+	}
+	query, err := url.ParseQuery(strings.TrimPrefix(uri, "magnet:?"))
+	if err != nil {
+		return nil, err
+	}
+
+	magnetLink := &MagnetLink{Name: query.Get("dn")}
+	for _, xt := range query["xt"] {
+		switch {
+		case strings.HasPrefix(xt, "urn:btih:"):
+			magnetLink.InfoHash, err = decodeBtih(strings.TrimPrefix(xt, "urn:btih:"))
+		case strings.HasPrefix(xt, "urn:btmh:"):
+			magnetLink.InfoHashV2, err = decodeBtmh(strings.TrimPrefix(xt, "urn:btmh:"))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if magnetLink.InfoHash == nil && magnetLink.InfoHashV2 == nil {
+		return nil, fmt.Errorf("magnet link doesn't contain info hash")
+	}
+
+	// url.ParseQuery loses order between different keys, so tr.N keys sorted by their number after tr keys
+	trackerKeys := make([]string, 0)
+	for key := range query {
+		if strings.HasPrefix(key, "tr.") {
+			trackerKeys = append(trackerKeys, key)
+		}
+	}
+	sort.Slice(trackerKeys, func(i, j int) bool {
+		numI, _ := strconv.Atoi(strings.TrimPrefix(trackerKeys[i], "tr."))
+		numJ, _ := strconv.Atoi(strings.TrimPrefix(trackerKeys[j], "tr."))
+		return numI < numJ
+	})
+	for _, key := range append([]string{"tr"}, trackerKeys...) {
+		for _, tracker := range query[key] {
+			if tracker != "" {
+				magnetLink.Trackers = append(magnetLink.Trackers, []string{tracker})
+			}
+		}
+	}
+
+	for _, webSeed := range query["ws"] {
+		if webSeed != "" {
+			magnetLink.WebSeeds = append(magnetLink.WebSeeds, webSeed)
+		}
+	}
+
+	if so := query.Get("so"); so != "" {
+		magnetLink.SelectOnly, err = decodeSelectOnly(so)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return magnetLink, nil
+}
+
+// decodeBtih info hash can be 40 symbols hex or 32 symbols base32
+func decodeBtih(btih string) ([]byte, error) {
+	switch len(btih) {
+	case 40:
+		return hex.DecodeString(btih)
+	case 32:
+		return base32.StdEncoding.DecodeString(strings.ToUpper(btih))
+	}
+	return nil, fmt.Errorf("wrong btih length %v", len(btih))
+}
+
+// decodeBtmh v2 info hash is hex encoded sha256 multihash
+func decodeBtmh(btmh string) ([]byte, error) {
+	if len(btmh) != 68 || !strings.HasPrefix(btmh, multihashSha256Prefix) {
+		return nil, fmt.Errorf("wrong btmh %v", btmh)
+	}
+	return hex.DecodeString(btmh[len(multihashSha256Prefix):])
+}
+
+// decodeSelectOnly so contains comma separated indexes and ranges, like 0,2,4-6
+func decodeSelectOnly(so string) ([]int64, error) {
+	selected := map[int64]bool{}
+	for _, part := range strings.Split(so, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.ParseInt(bounds[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("wrong so value %v", so)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.ParseInt(bounds[1], 10, 64)
+			if err != nil || last < first {
+				return nil, fmt.Errorf("wrong so value %v", so)
+			}
+		}
+		if first < 0 || last > maxSelectOnlyRange {
+			return nil, fmt.Errorf("wrong so value %v", so)
+		}
+		for index := first; index <= last; index++ {
+			selected[index] = true
+		}
+	}
+	result := make([]int64, 0, len(selected))
+	for index := range selected {
+		result = append(result, index)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result, nil
+}
+
+// GetFilePriority libtorrent set zero priority for all not selected files before the last selected one
+func (m *MagnetLink) GetFilePriority() []int64 {
+	if len(m.SelectOnly) == 0 {
+		return nil
+	}
+	priorities := make([]int64, m.SelectOnly[len(m.SelectOnly)-1]+1)
+	for _, index := range m.SelectOnly {
+		priorities[index] = 1
+	}
+	return priorities
+}
+
+// GetTorrentID qBittorrent use truncated v2 info hash if it exists, otherwise v1 info hash
+func (m *MagnetLink) GetTorrentID() string {
+	if m.InfoHashV2 != nil {
+		return hex.EncodeToString(m.InfoHashV2[:20])
+	}
+	return hex.EncodeToString(m.InfoHash)
+}
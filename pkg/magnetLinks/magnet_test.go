@@ -0,0 +1,117 @@
+package magnetLinks
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/r3labs/diff/v2"
+)
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestParse(t *testing.T) {
+	type Case struct {
+		name     string
+		uri      string
+		expected *MagnetLink
+		mustFail bool
+	}
+	cases := []Case{
+		{
+			name: "001 hex btih with name and trackers",
+			uri:  "magnet:?xt=urn:btih:d95d90a72e0a53e88e6f73a3905ca4fb5973472e&dn=test%20file&tr=udp%3A%2F%2Ftracker1.org%3A80&tr=http%3A%2F%2Ftracker2.org%2Fannounce",
+			expected: &MagnetLink{
+				InfoHash: mustDecodeHex("d95d90a72e0a53e88e6f73a3905ca4fb5973472e"),
+				Name:     "test file",
+				Trackers: [][]string{{"udp://tracker1.org:80"}, {"http://tracker2.org/announce"}},
+			},
+		},
+		{
+			name: "002 lowercase base32 btih",
+			uri:  "magnet:?xt=urn:btih:3fozbjzobjj6rdtpoorzaxfe7nmxgrzo",
+			expected: &MagnetLink{
+				InfoHash: mustDecodeHex("d95d90a72e0a53e88e6f73a3905ca4fb5973472e"),
+			},
+		},
+		{
+			name: "003 hybrid with web seeds, numbered trackers and select only",
+			uri: "magnet:?xt=urn:btih:72ff420a3181be093fe5a2c41b52187946f0ad41" +
+				"&xt=urn:btmh:12208c03b2a5ecd70ce2bc6ffc6a92fff72d44540eb139a56a12c6ad2d51776d841b" +
+				"&tr.2=http%3A%2F%2Ftracker2.org&tr.1=http%3A%2F%2Ftracker1.org&ws=http%3A%2F%2Fseed.org%2Ffile&so=0,2,4-5",
+			expected: &MagnetLink{
+				InfoHash:   mustDecodeHex("72ff420a3181be093fe5a2c41b52187946f0ad41"),
+				InfoHashV2: mustDecodeHex("8c03b2a5ecd70ce2bc6ffc6a92fff72d44540eb139a56a12c6ad2d51776d841b"),
+				Trackers:   [][]string{{"http://tracker1.org"}, {"http://tracker2.org"}},
+				WebSeeds:   []string{"http://seed.org/file"},
+				SelectOnly: []int64{0, 2, 4, 5},
+			},
+		},
+		{
+			name:     "004 without info hash. mustFail",
+			uri:      "magnet:?dn=test",
+			mustFail: true,
+		},
+		{
+			name:     "005 wrong btih. mustFail",
+			uri:      "magnet:?xt=urn:btih:d95d90a72e",
+			mustFail: true,
+		},
+		{
+			name:     "006 wrong so. mustFail",
+			uri:      "magnet:?xt=urn:btih:d95d90a72e0a53e88e6f73a3905ca4fb5973472e&so=5-1",
+			mustFail: true,
+		},
+		{
+			name:     "007 too big so index. mustFail",
+			uri:      "magnet:?xt=urn:btih:d95d90a72e0a53e88e6f73a3905ca4fb5973472e&so=9223372036854775806",
+			mustFail: true,
+		},
+		{
+			name:     "008 not magnet. mustFail",
+			uri:      "test.torrent",
+			mustFail: true,
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			magnetLink, err := Parse(testCase.uri)
+			if err != nil && !testCase.mustFail {
+				t.Fatalf("Unexpected error: %v", err)
+			} else if err == nil && testCase.mustFail {
+				t.Fatalf("Test must fail, but it doesn't")
+			}
+			if err == nil && !reflect.DeepEqual(magnetLink, testCase.expected) {
+				changes, err := diff.Diff(magnetLink, testCase.expected, diff.DiscardComplexOrigin())
+				if err != nil {
+					t.Error(err.Error())
+				}
+				t.Fatalf("Unexpected error: structures aren't equal:\n Got: %#v\n Expect %#v\n Diff: %v\n", magnetLink, testCase.expected, spew.Sdump(changes))
+			}
+		})
+	}
+}
+
+func TestMagnetLink_GetTorrentID(t *testing.T) {
+	v1 := &MagnetLink{InfoHash: mustDecodeHex("72ff420a3181be093fe5a2c41b52187946f0ad41")}
+	if id := v1.GetTorrentID(); id != "72ff420a3181be093fe5a2c41b52187946f0ad41" {
+		t.Fatalf("Unexpected v1 torrent id %v", id)
+	}
+	hybrid := &MagnetLink{
+		InfoHash:   mustDecodeHex("72ff420a3181be093fe5a2c41b52187946f0ad41"),
+		InfoHashV2: mustDecodeHex("8c03b2a5ecd70ce2bc6ffc6a92fff72d44540eb139a56a12c6ad2d51776d841b"),
+	}
+	if id := hybrid.GetTorrentID(); id != "8c03b2a5ecd70ce2bc6ffc6a92fff72d44540eb1" {
+		t.Fatalf("Unexpected hybrid torrent id %v", id)
+	}
+	if priorities := (&MagnetLink{SelectOnly: []int64{1, 3}}).GetFilePriority(); !reflect.DeepEqual(priorities, []int64{0, 1, 0, 1}) {
+		t.Fatalf("Unexpected file priorities %v", priorities)
+	}
+}
@@ -0,0 +1,175 @@
+package partFiles
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// UTorrentPartFilePattern uTorrent keeps pieces of not selected files in ~uTorrentPartFile_XXXX.dat inside torrent directory
+const UTorrentPartFilePattern = `~uTorrentPartFile_*.dat`
+
+// unusedSlot means that piece isn't present in libtorrent part file
+const unusedSlot = 0xffffffff
+
+/*
+UTorrentPartFile describes uTorrent part file.
+Header is array of little endian uint32, one per piece in torrent. Zero means that piece isn't stored,
+otherwise it's number of slot (starting from 1) with piece data. Slots follow the header and have piece length size.
+*/
+type UTorrentPartFile struct {
+	Path        string
+	NumPieces   int64
+	PieceLength int64
+	Slots       map[int64]int64 // piece index -> offset of piece data in part file
+}
+
+/*
+FindUTorrentPartFile return uTorrent part file from directory that match torrent pieces, or nil if there isn't any.
+Part files of other torrents can be in the same directory, so part files that don't match are returned as warnings.
+Error is returned only if part file can't be read
+*/
+func FindUTorrentPartFile(dir string, numPieces int64, pieceLength int64) (*UTorrentPartFile, []string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, UTorrentPartFilePattern))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(matches)
+	var warnings []string
+	for _, match := range matches {
+		partFile, err := ReadUTorrentPartFile(match, numPieces, pieceLength)
+		var mismatch *MismatchError
+		if errors.As(err, &mismatch) {
+			warnings = append(warnings, err.Error())
+			continue
+		} else if err != nil {
+			return nil, warnings, err
+		}
+		return partFile, nil, nil
+	}
+	return nil, warnings, nil
+}
+
+// MismatchError part file doesn't match pieces of torrent
+type MismatchError struct {
+	Path   string
+	Reason string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("part file %v doesn't match torrent: %v", e.Path, e.Reason)
+}
+
+// ReadUTorrentPartFile read header of uTorrent part file and check that it match torrent
+func ReadUTorrentPartFile(path string, numPieces int64, pieceLength int64) (*UTorrentPartFile, error) {
+	if numPieces <= 0 || pieceLength <= 0 {
+		return nil, &MismatchError{Path: path, Reason: "wrong pieces layout"}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	headerSize := numPieces * 4
+	if stat.Size() < headerSize {
+		return nil, &MismatchError{Path: path, Reason: "file is shorter than it's header"}
+	}
+	header := make([]uint32, numPieces)
+	if err = binary.Read(bufio.NewReader(file), binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+
+	// last slot can be shorter than piece length
+	maxSlots := (stat.Size() - headerSize + pieceLength - 1) / pieceLength
+	partFile := &UTorrentPartFile{
+		Path:        path,
+		NumPieces:   numPieces,
+		PieceLength: pieceLength,
+		Slots:       map[int64]int64{},
+	}
+	for pieceIndex, slot := range header {
+		if slot == 0 {
+			continue
+		}
+		if int64(slot) > maxSlots {
+			return nil, &MismatchError{Path: path,
+				Reason: fmt.Sprintf("piece %v point to slot %v, but file contain only %v slots", pieceIndex, slot, maxSlots)}
+		}
+		partFile.Slots[int64(pieceIndex)] = headerSize + (int64(slot)-1)*pieceLength
+	}
+	return partFile, nil
+}
+
+// GetPieces return sorted indexes of pieces that stored in part file
+func (p *UTorrentPartFile) GetPieces() []int64 {
+	pieces := make([]int64, 0, len(p.Slots))
+	for pieceIndex := range p.Slots {
+		pieces = append(pieces, pieceIndex)
+	}
+	sort.Slice(pieces, func(i, j int) bool { return pieces[i] < pieces[j] })
+	return pieces
+}
+
+// LibtorrentHeaderSize piece map (4 bytes per piece) with num pieces and piece size fields, rounded up to 1024 bytes
+func LibtorrentHeaderSize(numPieces int64) int64 {
+	return (numPieces*4 + 8 + 1023) &^ 1023
+}
+
+/*
+WriteLibtorrentPartFile convert uTorrent part file to content of libtorrent .parts file.
+libtorrent header contain big endian uint32 num pieces, piece size and slot index for every piece (0xffffffff if unused).
+Slots with piece data follow the header.
+https://github.com/arvidn/libtorrent/blob/RC_2_0/src/part_file.cpp
+*/
+func (p *UTorrentPartFile) WriteLibtorrentPartFile(dst io.Writer) error {
+	src, err := os.Open(p.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	headerSize := LibtorrentHeaderSize(p.NumPieces)
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header[0:], uint32(p.NumPieces))
+	binary.BigEndian.PutUint32(header[4:], uint32(p.PieceLength))
+	slotIndexes := map[int64]uint32{}
+	for slot, pieceIndex := range p.GetPieces() {
+		slotIndexes[pieceIndex] = uint32(slot)
+	}
+	for pieceIndex := int64(0); pieceIndex < p.NumPieces; pieceIndex++ {
+		slot, ok := slotIndexes[pieceIndex]
+		if !ok {
+			slot = unusedSlot
+		}
+		binary.BigEndian.PutUint32(header[8+pieceIndex*4:], slot)
+	}
+
+	bufferedWriter := bufio.NewWriter(dst)
+	if _, err = bufferedWriter.Write(header); err != nil {
+		return err
+	}
+	piece := make([]byte, p.PieceLength)
+	for _, pieceIndex := range p.GetPieces() {
+		// piece data is always piece length size, tail of last slot filled with zeroes
+		for i := range piece {
+			piece[i] = 0
+		}
+		if _, err = src.ReadAt(piece, p.Slots[pieceIndex]); err != nil && err != io.EOF {
+			return err
+		}
+		if _, err = bufferedWriter.Write(piece); err != nil {
+			return err
+		}
+	}
+	return bufferedWriter.Flush()
+}
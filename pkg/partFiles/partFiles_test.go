@@ -0,0 +1,127 @@
+package partFiles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func createUTorrentPartFile(t *testing.T, dir string, header []uint32, slots [][]byte) string {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+		t.Fatalf("Can't write header: %v", err)
+	}
+	for _, slot := range slots {
+		buf.Write(slot)
+	}
+	path := filepath.Join(dir, "~uTorrentPartFile_1A2B.dat")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Can't write part file: %v", err)
+	}
+	return path
+}
+
+func TestReadUTorrentPartFile(t *testing.T) {
+	type Case struct {
+		name        string
+		header      []uint32
+		slots       [][]byte
+		numPieces   int64
+		pieceLength int64
+		expected    map[int64]int64
+		mustFail    bool
+	}
+	cases := []Case{
+		{
+			name:        "001 two pieces",
+			header:      []uint32{0, 2, 0, 1},
+			slots:       [][]byte{[]byte("aaaa"), []byte("bbbb")},
+			numPieces:   4,
+			pieceLength: 4,
+			expected:    map[int64]int64{1: 20, 3: 16},
+		},
+		{
+			name:        "002 short last slot",
+			header:      []uint32{1, 0, 2},
+			slots:       [][]byte{[]byte("aaaa"), []byte("bb")},
+			numPieces:   3,
+			pieceLength: 4,
+			expected:    map[int64]int64{0: 12, 2: 16},
+		},
+		{
+			name:        "003 slot out of file. mustFail",
+			header:      []uint32{3, 0, 0},
+			slots:       [][]byte{[]byte("aaaa")},
+			numPieces:   3,
+			pieceLength: 4,
+			mustFail:    true,
+		},
+		{
+			name:        "004 header longer than file. mustFail",
+			header:      []uint32{1},
+			numPieces:   3,
+			pieceLength: 4,
+			mustFail:    true,
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := createUTorrentPartFile(t, t.TempDir(), testCase.header, testCase.slots)
+			partFile, err := ReadUTorrentPartFile(path, testCase.numPieces, testCase.pieceLength)
+			if err != nil && !testCase.mustFail {
+				t.Fatalf("Unexpected error: %v", err)
+			} else if err == nil && testCase.mustFail {
+				t.Fatalf("Test must fail, but it doesn't")
+			}
+			if err == nil && !reflect.DeepEqual(partFile.Slots, testCase.expected) {
+				t.Fatalf("Unexpected error: slots aren't equal:\n Got: %#v\n Expect %#v\n", partFile.Slots, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestWriteLibtorrentPartFile(t *testing.T) {
+	dir := t.TempDir()
+	createUTorrentPartFile(t, dir, []uint32{0, 2, 0, 1}, [][]byte{[]byte("aaaa"), []byte("bb")})
+	partFile, _, err := FindUTorrentPartFile(dir, 4, 4)
+	if err != nil || partFile == nil {
+		t.Fatalf("Can't find part file: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if err = partFile.WriteLibtorrentPartFile(buf); err != nil {
+		t.Fatalf("Can't write libtorrent part file: %v", err)
+	}
+	got := buf.Bytes()
+
+	expected := make([]byte, 1024)
+	binary.BigEndian.PutUint32(expected[0:], 4)
+	binary.BigEndian.PutUint32(expected[4:], 4)
+	binary.BigEndian.PutUint32(expected[8:], unusedSlot)
+	binary.BigEndian.PutUint32(expected[12:], 0)
+	binary.BigEndian.PutUint32(expected[16:], unusedSlot)
+	binary.BigEndian.PutUint32(expected[20:], 1)
+	expected = append(expected, []byte("bb\x00\x00aaaa")...)
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("Unexpected error: part files aren't equal:\n Got: %#v\n Expect %#v\n", got, expected)
+	}
+}
+
+func TestFindUTorrentPartFileMissing(t *testing.T) {
+	partFile, warnings, err := FindUTorrentPartFile(t.TempDir(), 4, 4)
+	if err != nil || partFile != nil || len(warnings) != 0 {
+		t.Fatalf("Unexpected result: %v %v %v", partFile, warnings, err)
+	}
+}
+
+func TestFindUTorrentPartFileMismatch(t *testing.T) {
+	dir := t.TempDir()
+	// part file of other torrent with more pieces
+	createUTorrentPartFile(t, dir, []uint32{0, 0, 0, 0, 0, 3}, [][]byte{[]byte("aaaa")})
+	partFile, warnings, err := FindUTorrentPartFile(dir, 6, 4)
+	if err != nil || partFile != nil || len(warnings) != 1 {
+		t.Fatalf("Unexpected result: %v %v %v", partFile, warnings, err)
+	}
+}
@@ -0,0 +1,833 @@
+package pickle
+
+/*
+Decoder of python pickle format (protocols 0-4) without executing anything: classes become Object values with
+constructor arguments and state. It's enough for data files of python applications, for example Deluge torrents.state.
+https://github.com/python/cpython/blob/main/Lib/pickletools.py
+
+Values are decoded as:
+	None                 -> nil
+	bool                 -> bool
+	int                  -> int64 or *big.Int
+	float                -> float64
+	str, unicode         -> string
+	bytes                -> []byte
+	list, tuple, set     -> []interface{}
+	dict                 -> map[interface{}]interface{}
+	class reference      -> Global
+	instance             -> *Object
+*/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Global reference to python class or function
+type Global struct {
+	Module string
+	Name   string
+}
+
+// Object instance of python class
+type Object struct {
+	Class Global
+	Args  []interface{}
+	State interface{} // usually dictionary of attributes
+}
+
+// Get return attribute from object state
+func (o *Object) Get(name string) (interface{}, bool) {
+	state, ok := o.State.(map[interface{}]interface{})
+	if !ok {
+		return nil, false
+	}
+	value, ok := state[name]
+	return value, ok
+}
+
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("pickle error at offset %v: %v", e.Offset, e.Msg)
+}
+
+// list is mutable while decoding, because it can be memoized before items are appended
+type list struct {
+	items []interface{}
+}
+
+type decoder struct {
+	data  []byte
+	pos   int
+	stack []interface{}
+	marks []int
+	memo  map[int64]interface{}
+}
+
+func Decode(data []byte) (interface{}, error) {
+	d := &decoder{data: data, memo: map[int64]interface{}{}}
+	value, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	r := &resolver{visited: map[interface{}]bool{}, lists: map[*list][]interface{}{}}
+	return r.resolve(value), nil
+}
+
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Offset: d.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) readLine() (string, error) {
+	for i := d.pos; i < len(d.data); i++ {
+		if d.data[i] == '\n' {
+			line := string(d.data[d.pos:i])
+			d.pos = i + 1
+			return line, nil
+		}
+	}
+	return "", d.errorf("unterminated line")
+}
+
+func (d *decoder) readUint(n int) (uint64, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, nil
+}
+
+func (d *decoder) push(v interface{}) {
+	d.stack = append(d.stack, v)
+}
+
+// base return index of the first item after last mark. Items below it belong to outer structure
+func (d *decoder) base() int {
+	if len(d.marks) == 0 {
+		return 0
+	}
+	return d.marks[len(d.marks)-1]
+}
+
+func (d *decoder) pop() (interface{}, error) {
+	if len(d.stack) <= d.base() {
+		return nil, d.errorf("stack underflow")
+	}
+	v := d.stack[len(d.stack)-1]
+	d.stack = d.stack[:len(d.stack)-1]
+	return v, nil
+}
+
+func (d *decoder) top() (interface{}, error) {
+	if len(d.stack) <= d.base() {
+		return nil, d.errorf("stack underflow")
+	}
+	return d.stack[len(d.stack)-1], nil
+}
+
+// popMark return items that were pushed after last mark
+func (d *decoder) popMark() ([]interface{}, error) {
+	if len(d.marks) == 0 {
+		return nil, d.errorf("mark not found")
+	}
+	k := d.marks[len(d.marks)-1]
+	d.marks = d.marks[:len(d.marks)-1]
+	if k > len(d.stack) {
+		return nil, d.errorf("mark is above stack")
+	}
+	items := make([]interface{}, len(d.stack)-k)
+	copy(items, d.stack[k:])
+	d.stack = d.stack[:k]
+	return items, nil
+}
+
+func (d *decoder) decode() (interface{}, error) {
+	for {
+		if d.pos >= len(d.data) {
+			return nil, d.errorf("unexpected end of data")
+		}
+		op := d.data[d.pos]
+		d.pos++
+		var err error
+		switch op {
+		case '.': // STOP
+			return d.pop()
+		case 0x80: // PROTO
+			_, err = d.read(1)
+		case 0x95: // FRAME
+			_, err = d.read(8)
+		case '(': // MARK
+			d.marks = append(d.marks, len(d.stack))
+		case '0': // POP
+			if len(d.marks) != 0 && len(d.stack) == d.base() {
+				_, err = d.popMark()
+			} else {
+				_, err = d.pop()
+			}
+		case '1': // POP_MARK
+			_, err = d.popMark()
+		case '2': // DUP
+			var v interface{}
+			if v, err = d.top(); err == nil {
+				d.push(v)
+			}
+		case 'N': // NONE
+			d.push(nil)
+		case 0x88: // NEWTRUE
+			d.push(true)
+		case 0x89: // NEWFALSE
+			d.push(false)
+		case 'I': // INT
+			err = d.opInt()
+		case 'L': // LONG
+			err = d.opLong()
+		case 'J': // BININT
+			var v uint64
+			if v, err = d.readUint(4); err == nil {
+				d.push(int64(int32(uint32(v))))
+			}
+		case 'K': // BININT1
+			var v uint64
+			if v, err = d.readUint(1); err == nil {
+				d.push(int64(v))
+			}
+		case 'M': // BININT2
+			var v uint64
+			if v, err = d.readUint(2); err == nil {
+				d.push(int64(v))
+			}
+		case 0x8a: // LONG1
+			var n uint64
+			if n, err = d.readUint(1); err == nil {
+				err = d.opBinLong(int(n))
+			}
+		case 0x8b: // LONG4
+			var n uint64
+			if n, err = d.readUint(4); err == nil {
+				err = d.opBinLong(int(int32(uint32(n))))
+			}
+		case 'F': // FLOAT
+			var line string
+			if line, err = d.readLine(); err == nil {
+				var f float64
+				if f, err = strconv.ParseFloat(line, 64); err == nil {
+					d.push(f)
+				}
+			}
+		case 'G': // BINFLOAT
+			var b []byte
+			if b, err = d.read(8); err == nil {
+				d.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+			}
+		case 'S': // STRING
+			var line string
+			if line, err = d.readLine(); err == nil {
+				var s string
+				if s, err = unquote(line); err == nil {
+					d.push(s)
+				}
+			}
+		case 'T': // BINSTRING
+			err = d.opBinString(4, false)
+		case 'U': // SHORT_BINSTRING
+			err = d.opBinString(1, false)
+		case 'B': // BINBYTES
+			err = d.opBinString(4, true)
+		case 'C': // SHORT_BINBYTES
+			err = d.opBinString(1, true)
+		case 0x8e: // BINBYTES8
+			err = d.opBinString(8, true)
+		case 'V': // UNICODE
+			var line string
+			if line, err = d.readLine(); err == nil {
+				d.push(decodeRawUnicodeEscape(line))
+			}
+		case 'X': // BINUNICODE
+			err = d.opBinString(4, false)
+		case 0x8c: // SHORT_BINUNICODE
+			err = d.opBinString(1, false)
+		case 0x8d: // BINUNICODE8
+			err = d.opBinString(8, false)
+		case ']': // EMPTY_LIST
+			d.push(&list{})
+		case 'l': // LIST
+			var items []interface{}
+			if items, err = d.popMark(); err == nil {
+				d.push(&list{items: items})
+			}
+		case 'a': // APPEND
+			var v interface{}
+			if v, err = d.pop(); err == nil {
+				err = d.appendItems([]interface{}{v})
+			}
+		case 'e': // APPENDS
+			var items []interface{}
+			if items, err = d.popMark(); err == nil {
+				err = d.appendItems(items)
+			}
+		case ')': // EMPTY_TUPLE
+			d.push([]interface{}{})
+		case 't': // TUPLE
+			var items []interface{}
+			if items, err = d.popMark(); err == nil {
+				d.push(items)
+			}
+		case 0x85, 0x86, 0x87: // TUPLE1, TUPLE2, TUPLE3
+			n := int(op - 0x84)
+			if len(d.stack)-d.base() < n {
+				err = d.errorf("stack underflow")
+			} else {
+				items := make([]interface{}, n)
+				copy(items, d.stack[len(d.stack)-n:])
+				d.stack = d.stack[:len(d.stack)-n]
+				d.push(items)
+			}
+		case '}': // EMPTY_DICT
+			d.push(map[interface{}]interface{}{})
+		case 'd': // DICT
+			var items []interface{}
+			if items, err = d.popMark(); err == nil {
+				dict := map[interface{}]interface{}{}
+				if err = d.setItems(dict, items); err == nil {
+					d.push(dict)
+				}
+			}
+		case 's': // SETITEM
+			var key, value interface{}
+			if value, err = d.pop(); err == nil {
+				if key, err = d.pop(); err == nil {
+					err = d.setItemsOnTop([]interface{}{key, value})
+				}
+			}
+		case 'u': // SETITEMS
+			var items []interface{}
+			if items, err = d.popMark(); err == nil {
+				err = d.setItemsOnTop(items)
+			}
+		case 0x8f: // EMPTY_SET
+			d.push(&list{})
+		case 0x90: // ADDITEMS
+			var items []interface{}
+			if items, err = d.popMark(); err == nil {
+				err = d.appendItems(items)
+			}
+		case 0x91: // FROZENSET
+			var items []interface{}
+			if items, err = d.popMark(); err == nil {
+				d.push(items)
+			}
+		case 'p': // PUT
+			var line string
+			if line, err = d.readLine(); err == nil {
+				var index int64
+				if index, err = strconv.ParseInt(line, 10, 64); err == nil {
+					err = d.put(index)
+				}
+			}
+		case 'q': // BINPUT
+			var index uint64
+			if index, err = d.readUint(1); err == nil {
+				err = d.put(int64(index))
+			}
+		case 'r': // LONG_BINPUT
+			var index uint64
+			if index, err = d.readUint(4); err == nil {
+				err = d.put(int64(index))
+			}
+		case 0x94: // MEMOIZE
+			err = d.put(int64(len(d.memo)))
+		case 'g': // GET
+			var line string
+			if line, err = d.readLine(); err == nil {
+				var index int64
+				if index, err = strconv.ParseInt(line, 10, 64); err == nil {
+					err = d.get(index)
+				}
+			}
+		case 'h': // BINGET
+			var index uint64
+			if index, err = d.readUint(1); err == nil {
+				err = d.get(int64(index))
+			}
+		case 'j': // LONG_BINGET
+			var index uint64
+			if index, err = d.readUint(4); err == nil {
+				err = d.get(int64(index))
+			}
+		case 'c': // GLOBAL
+			var module, name string
+			if module, err = d.readLine(); err == nil {
+				if name, err = d.readLine(); err == nil {
+					d.push(Global{Module: module, Name: name})
+				}
+			}
+		case 0x93: // STACK_GLOBAL
+			err = d.opStackGlobal()
+		case 'i': // INST
+			var module, name string
+			if module, err = d.readLine(); err == nil {
+				if name, err = d.readLine(); err == nil {
+					var args []interface{}
+					if args, err = d.popMark(); err == nil {
+						d.push(&Object{Class: Global{Module: module, Name: name}, Args: args})
+					}
+				}
+			}
+		case 'o': // OBJ
+			var items []interface{}
+			if items, err = d.popMark(); err == nil {
+				if len(items) == 0 {
+					err = d.errorf("OBJ without class")
+				} else {
+					err = d.pushObject(items[0], items[1:])
+				}
+			}
+		case 'R': // REDUCE
+			err = d.opReduce()
+		case 0x81: // NEWOBJ
+			var cls, args interface{}
+			if args, err = d.pop(); err == nil {
+				if cls, err = d.pop(); err == nil {
+					err = d.pushObject(cls, args)
+				}
+			}
+		case 0x92: // NEWOBJ_EX
+			var cls, args interface{}
+			if _, err = d.pop(); err == nil { // keyword arguments
+				if args, err = d.pop(); err == nil {
+					if cls, err = d.pop(); err == nil {
+						err = d.pushObject(cls, args)
+					}
+				}
+			}
+		case 'b': // BUILD
+			err = d.opBuild()
+		default:
+			err = d.errorf("unsupported opcode 0x%x", op)
+		}
+		if err != nil {
+			if _, ok := err.(*SyntaxError); !ok {
+				err = d.errorf("%v", err)
+			}
+			return nil, err
+		}
+	}
+}
+
+func (d *decoder) opInt() error {
+	line, err := d.readLine()
+	if err != nil {
+		return err
+	}
+	// protocol 0 writes booleans as I01 and I00
+	switch line {
+	case "01":
+		d.push(true)
+		return nil
+	case "00":
+		d.push(false)
+		return nil
+	}
+	return d.pushIntString(line)
+}
+
+func (d *decoder) opLong() error {
+	line, err := d.readLine()
+	if err != nil {
+		return err
+	}
+	return d.pushIntString(strings.TrimSuffix(line, "L"))
+}
+
+func (d *decoder) pushIntString(s string) error {
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		d.push(v)
+		return nil
+	}
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return d.errorf("wrong integer %q", s)
+	}
+	d.push(v)
+	return nil
+}
+
+// opBinLong decode little endian two's complement integer
+func (d *decoder) opBinLong(n int) error {
+	b, err := d.read(n)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		d.push(int64(0))
+		return nil
+	}
+	bigEndian := make([]byte, n)
+	for i := range b {
+		bigEndian[n-1-i] = b[i]
+	}
+	v := new(big.Int).SetBytes(bigEndian)
+	if b[n-1]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(n*8)))
+	}
+	if v.IsInt64() {
+		d.push(v.Int64())
+	} else {
+		d.push(v)
+	}
+	return nil
+}
+
+func (d *decoder) opBinString(lengthSize int, isBytes bool) error {
+	length, err := d.readUint(lengthSize)
+	if err != nil {
+		return err
+	}
+	if length > uint64(len(d.data)) {
+		return d.errorf("string is longer than data")
+	}
+	b, err := d.read(int(length))
+	if err != nil {
+		return err
+	}
+	if isBytes {
+		d.push(append([]byte{}, b...))
+	} else {
+		d.push(string(b))
+	}
+	return nil
+}
+
+func (d *decoder) opStackGlobal() error {
+	name, err := d.pop()
+	if err != nil {
+		return err
+	}
+	module, err := d.pop()
+	if err != nil {
+		return err
+	}
+	moduleStr, ok1 := module.(string)
+	nameStr, ok2 := name.(string)
+	if !ok1 || !ok2 {
+		return d.errorf("STACK_GLOBAL requires strings")
+	}
+	d.push(Global{Module: moduleStr, Name: nameStr})
+	return nil
+}
+
+func (d *decoder) opReduce() error {
+	args, err := d.pop()
+	if err != nil {
+		return err
+	}
+	callable, err := d.pop()
+	if err != nil {
+		return err
+	}
+	global, ok := callable.(Global)
+	if !ok {
+		return d.errorf("REDUCE with not class %T", callable)
+	}
+	argsList, _ := args.([]interface{})
+	switch global.Module + "." + global.Name {
+	case "copy_reg._reconstructor", "copyreg._reconstructor":
+		// python 2 objects are pickled as _reconstructor(cls, base, state)
+		if len(argsList) == 0 {
+			return d.errorf("_reconstructor without class")
+		}
+		return d.pushObject(argsList[0], []interface{}{})
+	case "_codecs.encode":
+		// python 3 pickles bytes as _codecs.encode(latin-1 string, "latin1") with protocols 0-2
+		if len(argsList) == 2 {
+			if str, ok := argsList[0].(string); ok {
+				b := make([]byte, 0, len(str))
+				for _, r := range str {
+					b = append(b, byte(r))
+				}
+				d.push(b)
+				return nil
+			}
+		}
+	case "__builtin__.set", "builtins.set", "__builtin__.frozenset", "builtins.frozenset":
+		items := &list{}
+		if len(argsList) != 0 {
+			switch v := argsList[0].(type) {
+			case *list:
+				items.items = append(items.items, v.items...)
+			case []interface{}:
+				items.items = append(items.items, v...)
+			}
+		}
+		d.push(items)
+		return nil
+	}
+	return d.pushObject(global, args)
+}
+
+func (d *decoder) pushObject(cls interface{}, args interface{}) error {
+	global, ok := cls.(Global)
+	if !ok {
+		return d.errorf("object of not class %T", cls)
+	}
+	argsList, ok := args.([]interface{})
+	if !ok {
+		return d.errorf("arguments of %v.%v aren't tuple", global.Module, global.Name)
+	}
+	d.push(&Object{Class: global, Args: argsList})
+	return nil
+}
+
+func (d *decoder) opBuild() error {
+	state, err := d.pop()
+	if err != nil {
+		return err
+	}
+	v, err := d.top()
+	if err != nil {
+		return err
+	}
+	switch target := v.(type) {
+	case *Object:
+		// state can be tuple of dictionary and slots dictionary
+		if tuple, ok := state.([]interface{}); ok && len(tuple) == 2 {
+			merged := map[interface{}]interface{}{}
+			for _, part := range tuple {
+				if dict, ok := part.(map[interface{}]interface{}); ok {
+					for key, value := range dict {
+						merged[key] = value
+					}
+				}
+			}
+			state = merged
+		}
+		target.State = state
+	case map[interface{}]interface{}:
+		dict, ok := state.(map[interface{}]interface{})
+		if !ok {
+			return d.errorf("BUILD dictionary with %T", state)
+		}
+		for key, value := range dict {
+			target[key] = value
+		}
+	default:
+		return d.errorf("BUILD of %T", v)
+	}
+	return nil
+}
+
+func (d *decoder) appendItems(items []interface{}) error {
+	v, err := d.top()
+	if err != nil {
+		return err
+	}
+	l, ok := v.(*list)
+	if !ok {
+		return d.errorf("append to %T", v)
+	}
+	l.items = append(l.items, items...)
+	return nil
+}
+
+func (d *decoder) setItemsOnTop(items []interface{}) error {
+	v, err := d.top()
+	if err != nil {
+		return err
+	}
+	dict, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return d.errorf("set item of %T", v)
+	}
+	return d.setItems(dict, items)
+}
+
+func (d *decoder) setItems(dict map[interface{}]interface{}, items []interface{}) error {
+	if len(items)%2 != 0 {
+		return d.errorf("odd number of dictionary items")
+	}
+	for i := 0; i < len(items); i += 2 {
+		key := items[i]
+		if _, isList := key.(*list); isList || (key != nil && !reflect.TypeOf(key).Comparable()) {
+			return d.errorf("unsupported dictionary key %T", key)
+		}
+		dict[key] = items[i+1]
+	}
+	return nil
+}
+
+func (d *decoder) put(index int64) error {
+	v, err := d.top()
+	if err != nil {
+		return err
+	}
+	d.memo[index] = v
+	return nil
+}
+
+func (d *decoder) get(index int64) error {
+	v, ok := d.memo[index]
+	if !ok {
+		return d.errorf("memo key %v not found", index)
+	}
+	d.push(v)
+	return nil
+}
+
+// resolver replace decoding types with plain values. Values can be shared through memo, so every value is resolved
+// once, otherwise nested shared values take exponential time
+type resolver struct {
+	visited map[interface{}]bool    // dictionaries, objects and tuples that are resolved in place
+	lists   map[*list][]interface{} // resolved lists, nil while list is resolving
+}
+
+func (r *resolver) resolve(v interface{}) interface{} {
+	switch value := v.(type) {
+	case *list:
+		if items, ok := r.lists[value]; ok {
+			// recursive list is replaced with nil
+			return items
+		}
+		r.lists[value] = nil
+		items := make([]interface{}, len(value.items))
+		for i, item := range value.items {
+			items[i] = r.resolve(item)
+		}
+		r.lists[value] = items
+		return items
+	case []interface{}:
+		if len(value) == 0 || r.visited[&value[0]] {
+			return value
+		}
+		r.visited[&value[0]] = true
+		for i, item := range value {
+			value[i] = r.resolve(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		key := reflect.ValueOf(value).Pointer()
+		if r.visited[key] {
+			return value
+		}
+		r.visited[key] = true
+		for k, item := range value {
+			value[k] = r.resolve(item)
+		}
+		return value
+	case *Object:
+		if r.visited[value] {
+			return value
+		}
+		r.visited[value] = true
+		value.Args = r.resolve(value.Args).([]interface{})
+		value.State = r.resolve(value.State)
+		return value
+	}
+	return v
+}
+
+// unquote decode python string literal of protocol 0
+func unquote(s string) (string, error) {
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return "", fmt.Errorf("wrong string literal %q", s)
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("wrong escape in string literal")
+		}
+		switch c = s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			if i+3 > len(s) {
+				return "", fmt.Errorf("wrong hex escape in string literal")
+			}
+			v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("wrong hex escape in string literal")
+			}
+			b.WriteByte(byte(v))
+			i += 2
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := i + 1
+			for end < len(s) && end < i+3 && s[end] >= '0' && s[end] <= '7' {
+				end++
+			}
+			v, _ := strconv.ParseUint(s[i:end], 8, 8)
+			b.WriteByte(byte(v))
+			i = end - 1
+		default:
+			// \\, \', \" and unknown escapes
+			if c != '\\' && c != '\'' && c != '"' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// decodeRawUnicodeEscape decode python raw-unicode-escape: latin-1 text with \uXXXX and \UXXXXXXXX escapes
+func decodeRawUnicodeEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && (s[i+1] == 'u' || s[i+1] == 'U') {
+			length := 4
+			if s[i+1] == 'U' {
+				length = 8
+			}
+			if i+2+length <= len(s) {
+				if v, err := strconv.ParseUint(s[i+2:i+2+length], 16, 32); err == nil && utf8.ValidRune(rune(v)) {
+					b.WriteRune(rune(v))
+					i += 1 + length
+					continue
+				}
+			}
+		}
+		b.WriteRune(rune(c))
+	}
+	return b.String()
+}
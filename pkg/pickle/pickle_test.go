@@ -0,0 +1,145 @@
+package pickle
+
+import (
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	type DecodeCase struct {
+		name string
+		data string
+	}
+	// python 3 pickle.dumps of Deluge like state with different protocols
+	cases := []DecodeCase{
+		{
+			name: "001 protocol 0",
+			data: "ccopy_reg\x0a_reconstructor\x0ap0\x0a(cdeluge.core.torrentmanager\x0aTorrentManagerState\x0ap1\x0ac__builtin__\x0aobject\x0ap2\x0aNtp3\x0aRp4\x0a(dp5\x0aVtorrents\x0ap6\x0a(lp7\x0ag0\x0a(cdeluge.core.torrentmanager\x0aTorrentState\x0ap8\x0ag2\x0aNtp9\x0aRp10\x0a(dp11\x0aVtorrent_id\x0ap12\x0aV3456bac107634970b022677c6bfaa584065e0917\x0ap13\x0asVpaused\x0ap14\x0aI01\x0asVsave_path\x0ap15\x0aV/mnt/\x5cu0444\x5cu0438\x5cu043b\x5cu044c\x5cu043c\x5cu044b\x0ap16\x0asVstop_ratio\x0ap17\x0aF2.5\x0asVbig\x0ap18\x0aL1180591620717411303424L\x0asVneg\x0ap19\x0aI-5\x0asVfile_priorities\x0ap20\x0a(lp21\x0aI0\x0aaI1\x0aaI4\x0aaI7\x0aasVtrackers\x0ap22\x0a(lp23\x0a(dp24\x0aVurl\x0ap25\x0aVhttp://t/ann\x0ap26\x0asVtier\x0ap27\x0aI0\x0asasVmagnet\x0ap28\x0aNsVb\x0ap29\x0ac_codecs\x0aencode\x0ap30\x0a(V\x5cu0000\xff\x0ap31\x0aVlatin1\x0ap32\x0atp33\x0aRp34\x0asVt\x0ap35\x0a(I1\x0aI2\x0atp36\x0asVshared1\x0ap37\x0a(lp38\x0aVa\x0ap39\x0aasVshared2\x0ap40\x0ag38\x0asVs\x0ap41\x0ac__builtin__\x0aset\x0ap42\x0a((lp43\x0aI3\x0aatp44\x0aRp45\x0asbasb.",
+		},
+		{
+			name: "002 protocol 2",
+			data: "\x80\x02cdeluge.core.torrentmanager\x0aTorrentManagerState\x0aq\x00)\x81q\x01}q\x02X\x08\x00\x00\x00torrentsq\x03]q\x04cdeluge.core.torrentmanager\x0aTorrentState\x0aq\x05)\x81q\x06}q\x07(X\x0a\x00\x00\x00torrent_idq\x08X(\x00\x00\x003456bac107634970b022677c6bfaa584065e0917q\x09X\x06\x00\x00\x00pausedq\x0a\x88X\x09\x00\x00\x00save_pathq\x0bX\x11\x00\x00\x00/mnt/\xd1\x84\xd0\xb8\xd0\xbb\xd1\x8c\xd0\xbc\xd1\x8bq\x0cX\x0a\x00\x00\x00stop_ratioq\x0dG@\x04\x00\x00\x00\x00\x00\x00X\x03\x00\x00\x00bigq\x0e\x8a\x09\x00\x00\x00\x00\x00\x00\x00\x00@X\x03\x00\x00\x00negq\x0fJ\xfb\xff\xff\xffX\x0f\x00\x00\x00file_prioritiesq\x10]q\x11(K\x00K\x01K\x04K\x07eX\x08\x00\x00\x00trackersq\x12]q\x13}q\x14(X\x03\x00\x00\x00urlq\x15X\x0c\x00\x00\x00http://t/annq\x16X\x04\x00\x00\x00tierq\x17K\x00uaX\x06\x00\x00\x00magnetq\x18NX\x01\x00\x00\x00bq\x19c_codecs\x0aencode\x0aq\x1aX\x03\x00\x00\x00\x00\xc3\xbfq\x1bX\x06\x00\x00\x00latin1q\x1c\x86q\x1dRq\x1eX\x01\x00\x00\x00tq\x1fK\x01K\x02\x86q X\x07\x00\x00\x00shared1q!]q\x22X\x01\x00\x00\x00aq#aX\x07\x00\x00\x00shared2q$h\x22X\x01\x00\x00\x00sq%c__builtin__\x0aset\x0aq&]q'K\x03a\x85q(Rq)ubasb.",
+		},
+		{
+			name: "003 protocol 4",
+			data: "\x80\x04\x95\x8c\x01\x00\x00\x00\x00\x00\x00\x8c\x1adeluge.core.torrentmanager\x94\x8c\x13TorrentManagerState\x94\x93\x94)\x81\x94}\x94\x8c\x08torrents\x94]\x94h\x00\x8c\x0cTorrentState\x94\x93\x94)\x81\x94}\x94(\x8c\x0atorrent_id\x94\x8c(3456bac107634970b022677c6bfaa584065e0917\x94\x8c\x06paused\x94\x88\x8c\x09save_path\x94\x8c\x11/mnt/\xd1\x84\xd0\xb8\xd0\xbb\xd1\x8c\xd0\xbc\xd1\x8b\x94\x8c\x0astop_ratio\x94G@\x04\x00\x00\x00\x00\x00\x00\x8c\x03big\x94\x8a\x09\x00\x00\x00\x00\x00\x00\x00\x00@\x8c\x03neg\x94J\xfb\xff\xff\xff\x8c\x0ffile_priorities\x94]\x94(K\x00K\x01K\x04K\x07e\x8c\x08trackers\x94]\x94}\x94(\x8c\x03url\x94\x8c\x0chttp://t/ann\x94\x8c\x04tier\x94K\x00ua\x8c\x06magnet\x94N\x8c\x01b\x94C\x02\x00\xff\x94\x8c\x01t\x94K\x01K\x02\x86\x94\x8c\x07shared1\x94]\x94\x8c\x01a\x94a\x8c\x07shared2\x94h!\x8c\x01s\x94\x8f\x94(K\x03\x90ubasb.",
+		},
+	}
+	bigInt, _ := new(big.Int).SetString("1180591620717411303424", 10)
+	expectedState := map[interface{}]interface{}{
+		"torrent_id":      "3456bac107634970b022677c6bfaa584065e0917",
+		"paused":          true,
+		"save_path":       "/mnt/фильмы",
+		"stop_ratio":      2.5,
+		"big":             bigInt,
+		"neg":             int64(-5),
+		"file_priorities": []interface{}{int64(0), int64(1), int64(4), int64(7)},
+		"trackers":        []interface{}{map[interface{}]interface{}{"url": "http://t/ann", "tier": int64(0)}},
+		"magnet":          nil,
+		"b":               []byte{0x00, 0xff},
+		"t":               []interface{}{int64(1), int64(2)},
+		"shared1":         []interface{}{"a"},
+		"shared2":         []interface{}{"a"},
+		"s":               []interface{}{int64(3)},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			decoded, err := Decode([]byte(testCase.data))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			state, ok := decoded.(*Object)
+			if !ok || state.Class != (Global{Module: "deluge.core.torrentmanager", Name: "TorrentManagerState"}) {
+				t.Fatalf("Unexpected decoded value: %#v", decoded)
+			}
+			torrents, ok := state.Get("torrents")
+			if !ok {
+				t.Fatalf("Torrents not found in state: %#v", state.State)
+			}
+			torrentsList, ok := torrents.([]interface{})
+			if !ok || len(torrentsList) != 1 {
+				t.Fatalf("Unexpected torrents: %#v", torrents)
+			}
+			torrent, ok := torrentsList[0].(*Object)
+			if !ok || torrent.Class.Name != "TorrentState" {
+				t.Fatalf("Unexpected torrent: %#v", torrentsList[0])
+			}
+			if !reflect.DeepEqual(torrent.State, expectedState) {
+				t.Fatalf("Unexpected torrent state:\n Got: %#v\n Expect %#v\n", torrent.State, expectedState)
+			}
+		})
+	}
+}
+
+func TestDecodeBroken(t *testing.T) {
+	cases := []string{
+		"",
+		"\x80\x02]q\x00(K\x01",
+		"\x80\x02X\xff\x00\x00\x00abc.",
+		"\x80\x02h\x05.",
+		"\x80\x02}]K\x01s.",
+		"\x80\x02Pabc\n.",
+		")JuJ\x80g(\x86\x80ad",
+	}
+	for _, data := range cases {
+		if _, err := Decode([]byte(data)); err == nil {
+			t.Fatalf("Decode of %q must fail, but it doesn't", data)
+		}
+	}
+}
+
+func TestDecodeSharedNested(t *testing.T) {
+	// every list contains previous list twice through memo, so it's 2^40 lists without sharing
+	data := "\x80\x04]q\x00"
+	for i := 0; i < 40; i++ {
+		data += "(h\x00h\x00lq\x00"
+	}
+	value, err := Decode([]byte(data + "."))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if items, ok := value.([]interface{}); !ok || len(items) != 2 {
+		t.Fatalf("Unexpected value %#v", value)
+	}
+}
+
+// TestDecodeMutated decode randomly mutated valid pickles, decoder must return error instead of panic
+func TestDecodeMutated(t *testing.T) {
+	var samples [][]byte
+	for _, data := range []string{
+		"\x80\x02}q\x00(X\x01\x00\x00\x00aq\x01]q\x02(K\x01K\x02\x86q\x03eX\x01\x00\x00\x00bq\x04N\x85q\x05u.",
+		"(dp0\nVa\np1\n(lp2\nI1\naI2\natp3\nsVb\np4\n(I1\nI2\nI3\ntp5\ns.",
+		")JuJ\x80g(\x86\x80ad",
+	} {
+		samples = append(samples, []byte(data))
+	}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		sample := samples[random.Intn(len(samples))]
+		data := append([]byte{}, sample...)
+		for n := random.Intn(4) + 1; n > 0; n-- {
+			position := random.Intn(len(data))
+			switch random.Intn(3) {
+			case 0:
+				data[position] = byte(random.Intn(256))
+			case 1:
+				data = append(data[:position], data[position+1:]...)
+			default:
+				data = append(data[:position], append([]byte{byte(random.Intn(256))}, data[position:]...)...)
+			}
+			if len(data) == 0 {
+				break
+			}
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("Decode of %q panics: %v", data, r)
+				}
+			}()
+			_, _ = Decode(data)
+		}()
+	}
+}
@@ -14,6 +14,11 @@ func (t *Torrent) IsV2OrHybryd() bool {
 	return false
 }
 
+// IsV2Only v2 torrents without v1 pieces, so they don't have v1 info hash
+func (t *Torrent) IsV2Only() bool {
+	return t.IsV2OrHybryd() && len(t.Info.Pieces) == 0
+}
+
 func (t *Torrent) IsSingle() bool {
 	if t.Single != nil {
 		return *t.Single
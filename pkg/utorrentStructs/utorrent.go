@@ -1,21 +1,35 @@
 package utorrentStructs
 
 type ResumeItem struct {
-	AddedOn          int64           `bencode:"added_on"`
-	Caption          string          `bencode:"caption,omitempty"`
-	CompletedOn      int64           `bencode:"completed_on"`
-	Downloaded       int64           `bencode:"downloaded"`
-	Info             string          `bencode:"info"`
-	Label            string          `bencode:"label,omitempty"`
-	Labels           []string        `bencode:"labels,omitempty"`
-	LastSeenComplete int64           `bencode:"last_seen_complete"`
-	Path             string          `bencode:"path"`
-	Prio             []byte          `bencode:"prio"`
-	Runtime          int64           `bencode:"runtime"`
-	Started          int64           `bencode:"started"`
-	Targets          [][]interface{} `bencode:"targets,omitempty"`
-	Time             int64           `bencode:"time"`
-	Trackers         interface{}     `bencode:"trackers,omitempty"`
-	UpSpeed          int64           `bencode:"upspeed"`
-	Uploaded         int64           `bencode:"uploaded"`
+	AddedOn              int64           `bencode:"added_on"`
+	Blocks               interface{}     `bencode:"blocks,omitempty"` // downloaded blocks of not completed pieces
+	Caption              string          `bencode:"caption,omitempty"`
+	CompletedOn          int64           `bencode:"completed_on"`
+	DHT                  *int64          `bencode:"dht,omitempty"` // 0 if DHT is disabled for torrent, it's enabled if key is missing
+	DownSpeed            int64           `bencode:"downspeed"`     // download rate limit in bytes per second, 0 is unlimited
+	Downloaded           int64           `bencode:"downloaded"`
+	Have                 []byte          `bencode:"have,omitempty"` // bitfield of completed pieces
+	Info                 string          `bencode:"info"`
+	Label                string          `bencode:"label,omitempty"`
+	Labels               []string        `bencode:"labels,omitempty"`
+	LastActive           int64           `bencode:"last_active"` // time of last upload or download
+	LastSeenComplete     int64           `bencode:"last_seen_complete"`
+	Order                *int64          `bencode:"order,omitempty"` // position in download queue from 0, -1 if torrent isn't queued
+	OverrideSeedSettings int64           `bencode:"override_seedsettings"`
+	Path                 string          `bencode:"path"`
+	PEX                  *int64          `bencode:"pex,omitempty"` // 0 if peer exchange is disabled for torrent, it's enabled if key is missing
+	Prio                 []byte          `bencode:"prio"`
+	Runtime              int64           `bencode:"runtime"`  // seconds of activity
+	SeedTime             int64           `bencode:"seedtime"` // seconds of seeding
+	Started              int64           `bencode:"started"`
+	SuperSeed            int64           `bencode:"superseed"`
+	Targets              [][]interface{} `bencode:"targets,omitempty"`
+	Time                 int64           `bencode:"time"`
+	Trackers             interface{}     `bencode:"trackers,omitempty"`
+	TrackerMode          int64           `bencode:"trackermode"` // 0 is default, other modes have no equivalent in libtorrent
+	ULSlots              int64           `bencode:"ulslots"`     // number of upload slots, 0 is default of client
+	UpSpeed              int64           `bencode:"upspeed"`
+	Uploaded             int64           `bencode:"uploaded"`
+	WantedRatio          int64           `bencode:"wanted_ratio"`    // ratio multiplied by 1000, used with override_seedsettings
+	WantedSeedtime       int64           `bencode:"wanted_seedtime"` // seeding time in seconds, used with override_seedsettings. 0 is without limit
 }
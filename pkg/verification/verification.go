@@ -0,0 +1,327 @@
+package verification
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/rumanzo/bt2qbt/pkg/torrentStructures"
+)
+
+// BlockSize v2 merkle trees are built from 16KiB blocks
+// http://www.bittorrent.org/beps/bep_0052.html
+const BlockSize = 16 * 1024
+
+// Verifier hash pieces of torrents. Bound is shared between all torrents, so it limits total number of parallel reads
+type Verifier struct {
+	Bound chan bool
+}
+
+type segment struct {
+	file   int
+	offset int64
+	length int64
+}
+
+type piece struct {
+	index    int64
+	segments []segment
+	expected []byte
+	leaves   int64 // number of merkle tree leaves for v2 pieces, zero for v1 pieces
+}
+
+type fileRoot struct {
+	length int64
+	root   string
+}
+
+func NewVerifier(threads int) *Verifier {
+	if threads <= 0 {
+		threads = runtime.GOMAXPROCS(0)
+	}
+	return &Verifier{Bound: make(chan bool, threads)}
+}
+
+// Verify return libtorrent pieces field: 1 for every piece that match hash from torrent, 0 otherwise.
+// filePaths must have the same order as torrent file list
+func (v *Verifier) Verify(torrent *torrentStructures.Torrent, filePaths []string) ([]byte, error) {
+	var pieces []piece
+	var numPieces int64
+	var err error
+	if torrent.IsV2OrHybryd() {
+		pieces, numPieces, err = getPiecesV2(torrent)
+	} else {
+		pieces, numPieces, err = getPiecesV1(torrent)
+	}
+	if err != nil {
+		return nil, err
+	}
+	files, err := getFileLengths(torrent)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != len(filePaths) {
+		return nil, fmt.Errorf("torrent has %v files, but got %v paths", len(files), len(filePaths))
+	}
+
+	// files are opened for every piece segment, so number of opened files is limited by number of parallel reads
+	result := make([]byte, numPieces)
+	jobs := make(chan piece)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for i := 0; i < cap(v.Bound); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				mu.Lock()
+				failed := firstErr != nil
+				mu.Unlock()
+				if failed {
+					continue
+				}
+				v.Bound <- true
+				ok, err := checkPiece(filePaths, job)
+				<-v.Bound
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				} else if ok {
+					result[job.index] = 1
+				}
+			}
+		}()
+	}
+	for _, job := range pieces {
+		jobs <- job
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+// checkPiece return false if piece doesn't match hash. Missing and short files aren't errors, their pieces just aren't
+// downloaded, but any other error means that result can't be trusted
+func checkPiece(filePaths []string, job piece) (bool, error) {
+	buffer := new(bytes.Buffer)
+	for _, seg := range job.segments {
+		n, err := readSegment(filePaths[seg.file], seg, buffer)
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if n != seg.length {
+			return false, nil
+		}
+	}
+	if job.leaves == 0 {
+		hash := sha1.Sum(buffer.Bytes())
+		return bytes.Equal(hash[:], job.expected), nil
+	}
+	return bytes.Equal(merkleRoot(buffer.Bytes(), job.leaves), job.expected), nil
+}
+
+func readSegment(filePath string, seg segment, buffer *bytes.Buffer) (int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(buffer, io.NewSectionReader(file, seg.offset, seg.length))
+}
+
+// merkleRoot compute root of 16KiB blocks tree. Leaves beyond the end of data are zero hashes
+func merkleRoot(data []byte, leaves int64) []byte {
+	layer := make([][]byte, leaves)
+	for i := int64(0); i < leaves; i++ {
+		start := i * BlockSize
+		if start < int64(len(data)) {
+			end := start + BlockSize
+			if end > int64(len(data)) {
+				end = int64(len(data))
+			}
+			hash := sha256.Sum256(data[start:end])
+			layer[i] = hash[:]
+		} else {
+			layer[i] = make([]byte, sha256.Size)
+		}
+	}
+	for len(layer) > 1 {
+		nextLayer := make([][]byte, 0, len(layer)/2)
+		for i := 0; i < len(layer); i += 2 {
+			hash := sha256.Sum256(append(append([]byte{}, layer[i]...), layer[i+1]...))
+			nextLayer = append(nextLayer, hash[:])
+		}
+		layer = nextLayer
+	}
+	return layer[0]
+}
+
+func getFileLengths(torrent *torrentStructures.Torrent) ([]int64, error) {
+	if torrent.IsV2OrHybryd() {
+		roots, err := getFileRoots(torrent.Info.FileTree)
+		if err != nil {
+			return nil, err
+		}
+		lengths := make([]int64, 0, len(roots))
+		for _, root := range roots {
+			lengths = append(lengths, root.length)
+		}
+		return lengths, nil
+	}
+	if torrent.IsSingle() {
+		return []int64{torrent.Info.Length}, nil
+	}
+	lengths := make([]int64, 0, len(torrent.Info.Files))
+	for _, file := range torrent.Info.Files {
+		lengths = append(lengths, file.Length)
+	}
+	return lengths, nil
+}
+
+// getPiecesV1 split concatenated files into pieces. Pieces can cross file boundaries
+func getPiecesV1(torrent *torrentStructures.Torrent) ([]piece, int64, error) {
+	pieceLength := torrent.Info.PieceLength
+	if pieceLength <= 0 {
+		return nil, 0, fmt.Errorf("wrong piece length %v", pieceLength)
+	}
+	numPieces := int64(len(torrent.Info.Pieces)) / sha1.Size
+	lengths, err := getFileLengths(torrent)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var pieces []piece
+	current := piece{index: 0}
+	var currentLength int64
+	for fileIndex, fileLength := range lengths {
+		var fileOffset int64
+		for fileOffset < fileLength {
+			length := fileLength - fileOffset
+			if left := pieceLength - currentLength; length > left {
+				length = left
+			}
+			current.segments = append(current.segments, segment{file: fileIndex, offset: fileOffset, length: length})
+			fileOffset += length
+			currentLength += length
+			if currentLength == pieceLength {
+				pieces = append(pieces, current)
+				current = piece{index: current.index + 1}
+				currentLength = 0
+			}
+		}
+	}
+	if currentLength > 0 {
+		pieces = append(pieces, current)
+	}
+	if int64(len(pieces)) != numPieces {
+		return nil, 0, fmt.Errorf("torrent has %v piece hashes, but files contain %v pieces", numPieces, len(pieces))
+	}
+	for index := range pieces {
+		pieces[index].expected = torrent.Info.Pieces[index*sha1.Size : (index+1)*sha1.Size]
+	}
+	return pieces, numPieces, nil
+}
+
+// getPiecesV2 every file starts from piece boundary. Files larger than piece have hashes in piece layers,
+// smaller files are verified with pieces root
+func getPiecesV2(torrent *torrentStructures.Torrent) ([]piece, int64, error) {
+	pieceLength := torrent.Info.PieceLength
+	if pieceLength < BlockSize {
+		return nil, 0, fmt.Errorf("wrong piece length %v", pieceLength)
+	}
+	roots, err := getFileRoots(torrent.Info.FileTree)
+	if err != nil {
+		return nil, 0, err
+	}
+	var pieces []piece
+	var index int64
+	for fileIndex, root := range roots {
+		if root.length == 0 {
+			continue
+		}
+		if root.length <= pieceLength {
+			blocks := (root.length + BlockSize - 1) / BlockSize
+			leaves := int64(1)
+			for leaves < blocks {
+				leaves *= 2
+			}
+			pieces = append(pieces, piece{
+				index:    index,
+				segments: []segment{{file: fileIndex, offset: 0, length: root.length}},
+				expected: []byte(root.root),
+				leaves:   leaves,
+			})
+			index++
+			continue
+		}
+		var layer string
+		if torrent.PieceLayers != nil {
+			layer, _ = (*torrent.PieceLayers)[root.root].(string)
+		}
+		filePieces := (root.length + pieceLength - 1) / pieceLength
+		if int64(len(layer)) != filePieces*sha256.Size {
+			return nil, 0, fmt.Errorf("piece layer for file %v has wrong length %v", fileIndex, len(layer))
+		}
+		for i := int64(0); i < filePieces; i++ {
+			length := pieceLength
+			if rest := root.length - i*pieceLength; rest < length {
+				length = rest
+			}
+			pieces = append(pieces, piece{
+				index:    index,
+				segments: []segment{{file: fileIndex, offset: i * pieceLength, length: length}},
+				expected: []byte(layer[i*sha256.Size : (i+1)*sha256.Size]),
+				leaves:   pieceLength / BlockSize,
+			})
+			index++
+		}
+	}
+	return pieces, index, nil
+}
+
+// getFileRoots walk over file tree in the same order as torrentStructures file list
+func getFileRoots(fileTree map[string]interface{}) ([]fileRoot, error) {
+	keys := make([]string, 0, len(fileTree))
+	for k := range fileTree {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var roots []fileRoot
+	for _, k := range keys {
+		v, ok := fileTree[k].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("wrong file tree entry %v", k)
+		}
+		if len(k) == 0 {
+			length, _ := v["length"].(int64)
+			root, _ := v["pieces root"].(string)
+			if length > 0 && len(root) != sha256.Size {
+				return nil, fmt.Errorf("wrong pieces root")
+			}
+			return append(roots, fileRoot{length: length, root: root}), nil
+		}
+		subRoots, err := getFileRoots(v)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, subRoots...)
+	}
+	return roots, nil
+}
@@ -0,0 +1,134 @@
+package verification
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rumanzo/bt2qbt/pkg/fileHelpers"
+	"github.com/rumanzo/bt2qbt/pkg/helpers"
+	"github.com/rumanzo/bt2qbt/pkg/torrentStructures"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	type Case struct {
+		name     string
+		path     string
+		dir      string
+		single   bool
+		remove   int // index of file that will be replaced with not existing path, -1 for none
+		expected []byte
+		mustFail bool
+	}
+	cases := []Case{
+		{
+			name:     "001 testdir v1",
+			path:     "../../test/data/testdir_v1.torrent",
+			dir:      "../../test/data/testdir",
+			remove:   -1,
+			expected: []byte{1},
+		},
+		{
+			name:     "002 testdir v2",
+			path:     "../../test/data/testdir_v2.torrent",
+			dir:      "../../test/data/testdir",
+			remove:   -1,
+			expected: []byte{1, 1, 1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:     "003 testdir hybrid",
+			path:     "../../test/data/testdir_hybrid.torrent",
+			dir:      "../../test/data/testdir",
+			remove:   -1,
+			expected: []byte{1, 1, 1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:     "004 testdir hybrid with missing file",
+			path:     "../../test/data/testdir_hybrid.torrent",
+			dir:      "../../test/data/testdir",
+			remove:   2,
+			expected: []byte{1, 1, 0, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:     "005 testdir v1 with missing file",
+			path:     "../../test/data/testdir_v1.torrent",
+			dir:      "../../test/data/testdir",
+			remove:   0,
+			expected: []byte{0},
+		},
+		{
+			name:     "006 single v1",
+			path:     "../../test/data/testfile1_single_v1.torrent",
+			dir:      "../../test/data/testdir",
+			single:   true,
+			remove:   -1,
+			expected: []byte{1},
+		},
+		{
+			name:     "007 single v2",
+			path:     "../../test/data/testfile1_single_v2.torrent",
+			dir:      "../../test/data/testdir",
+			single:   true,
+			remove:   -1,
+			expected: []byte{1},
+		},
+		{
+			name:     "008 single hybrid",
+			path:     "../../test/data/testfile1_single_hybrid.torrent",
+			dir:      "../../test/data/testdir",
+			single:   true,
+			remove:   -1,
+			expected: []byte{1},
+		},
+		{
+			name:     "009 single v1 mustFail",
+			path:     "../../test/data/testfile1_single_v1.torrent",
+			dir:      "../../test/data/testdir",
+			single:   true,
+			remove:   0,
+			expected: []byte{1},
+			mustFail: true,
+		},
+	}
+	verifier := NewVerifier(2)
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			torrent := &torrentStructures.Torrent{}
+			if err := helpers.DecodeTorrentFile(testCase.path, torrent); err != nil {
+				t.Fatalf("Can't decode torrent file: %v", err)
+			}
+			var filePaths []string
+			if testCase.single {
+				filePaths = []string{fileHelpers.Join([]string{testCase.dir, torrent.GetTorrentName()}, `/`)}
+			} else {
+				fileList, _ := torrent.GetFileList()
+				for _, file := range fileList {
+					filePaths = append(filePaths, fileHelpers.Join([]string{testCase.dir, file}, `/`))
+				}
+			}
+			if testCase.remove >= 0 {
+				filePaths[testCase.remove] = "not_existing_file"
+			}
+			pieces, err := verifier.Verify(torrent, filePaths)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			equal := reflect.DeepEqual(pieces, testCase.expected)
+			if !equal && !testCase.mustFail {
+				t.Fatalf("Unexpected error: pieces aren't equal:\n Got: %#v\n Expect %#v\n", pieces, testCase.expected)
+			} else if equal && testCase.mustFail {
+				t.Fatalf("Unexpected error: pieces are equal, but they shouldn't\n Got: %#v\n", pieces)
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyUnreadable(t *testing.T) {
+	torrent := &torrentStructures.Torrent{}
+	if err := helpers.DecodeTorrentFile("../../test/data/testfile1_single_v1.torrent", torrent); err != nil {
+		t.Fatalf("Can't decode torrent file: %v", err)
+	}
+	// directory exists, but it can't be read as file, so pieces are unknown instead of missing
+	if pieces, err := NewVerifier(2).Verify(torrent, []string{t.TempDir()}); err == nil {
+		t.Fatalf("Unreadable file must fail verification, but got pieces %v", pieces)
+	}
+}